@@ -0,0 +1,133 @@
+package pot
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ethersphere/proximity-order-trie/pkg/elements"
+)
+
+var (
+	ErrBatchClosed = errors.New("batch closed")
+)
+
+// Batch collects updates to the pot that are committed together as a single new root
+// a Batch is only valid within the function passed to Index.Batch
+type Batch struct {
+	ctx     context.Context
+	mode    elements.Mode // mode of the index with packing deferred to commit
+	root    elements.Node // root with the updates applied so far
+	changes []Change      // changes applied so far
+	watched bool          // changes are recorded for watchers
+	closed  bool
+}
+
+// unpacked is a Mode that defers packing nodes so that a batch is packed once on commit
+type unpacked struct {
+	elements.Mode
+}
+
+// Pack NOOP
+func (unpacked) Pack(context.Context, elements.Node) error {
+	return nil
+}
+
+// Updating registers updates of the batch with the mode, see elements.Updating
+func (u unpacked) Updating() func() {
+	return elements.Updating(u.Mode)
+}
+
+// committer is implemented by modes keeping the root of the pot, such as SwarmPot, which packs a root built
+// outside Update and makes it the root of the pot, other modes are only asked to pack it
+type committer interface {
+	Commit(context.Context, elements.Node) error
+}
+
+// Batch applies the updates made by f on a batch atomically: they are either all committed
+// as one new root or, if f returns an error or the context is cancelled, none of them is.
+// The write lock is held while f runs. Updates use the generic update function with the
+// insertion and promotion policies of the mode, and the new root is packed once and handed
+// to the mode by its Commit if it has one.
+func (idx *Index) Batch(ctx context.Context, f func(b *Batch) error) (err error) {
+	var root elements.Node
+
+	// get the pot root and capture the write lock
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case root = <-idx.write:
+	}
+	// release the write lock with the new root or the old one to roll back
+	c := commit{root: root}
+	defer func() { idx.root <- c }()
+	defer elements.Traverse(idx.mode)()
+
+	b := &Batch{ctx: ctx, mode: unpacked{idx.mode}, root: root, watched: idx.watched.Load()}
+	defer func() { b.closed = true }()
+	if err := f(b); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if b.root == root {
+		return nil
+	}
+	pack := idx.mode.Pack
+	if cm, ok := idx.mode.(committer); ok {
+		pack = cm.Commit
+	}
+	if err := pack(ctx, b.root); err != nil {
+		return err
+	}
+	c = commit{root: b.root, changes: b.changes}
+	return nil
+}
+
+// Put inserts or replaces an entry
+func (b *Batch) Put(e elements.Entry) error {
+	return b.Update(e.Key(), &e)
+}
+
+// Delete removes the entry at the given key
+func (b *Batch) Delete(k []byte) error {
+	return b.Update(k, nil)
+}
+
+// Update sets the entry at the given key, a nil entry deletes it
+func (b *Batch) Update(k []byte, e *elements.Entry) error {
+	if b.closed {
+		return ErrBatchClosed
+	}
+	if err := b.ctx.Err(); err != nil {
+		return err
+	}
+	var ch Change
+	var changed bool
+	if b.watched {
+		var err error
+		if ch, changed, err = change(b.ctx, b.root, k, e, b.mode); err != nil {
+			return err
+		}
+	}
+	update, err := elements.Update(b.ctx, b.mode.New(), elements.NewAt(0, b.root), k, e, b.mode)
+	if err != nil {
+		return err
+	}
+	if update != nil {
+		b.root = update
+		if changed {
+			b.changes = append(b.changes, ch)
+		}
+	}
+	return nil
+}
+
+// Find retrieves the entry at the given key including the updates of the batch so far
+// or gives elements.ErrNotFound
+func (b *Batch) Find(k []byte) (elements.Entry, error) {
+	if b.closed {
+		return nil, ErrBatchClosed
+	}
+	return elements.Find(b.ctx, b.root, k, b.mode)
+}
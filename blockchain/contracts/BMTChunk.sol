@@ -71,7 +71,84 @@ library BMTChunk {
         uint64 _chunkSpan
     ) internal pure returns (bytes32) {
         bytes32 rootHash = rootHashFromInclusionProof(_proofSegments, _proveSegment, _proveSegmentIndex);
-        return keccak256(abi.encodePacked(reverseUint64(_chunkSpan), rootHash));
+        return chunkAddress(rootHash, _chunkSpan);
+    }
+
+    /** Calculates the root hash from the provided proof of consecutive segments
+     * The proof segments are the sister hashes that cannot be calculated from the segments,
+     * level by level from the bottom of the tree with the left sister before the right one.
+     * @param _proofSegments Proof segments.
+     * @param _segments Consecutive segments to prove.
+     * @param _firstSegmentIndex Index of the first segment to prove
+     * @return _calculatedHash chunk hash
+     */
+    function rootHashFromSegmentsProof(
+        bytes32[] memory _proofSegments,
+        bytes32[] memory _segments,
+        uint256 _firstSegmentIndex
+    ) internal pure returns (bytes32 _calculatedHash) {
+        uint256 count = _segments.length;
+        require(count > 0 && _firstSegmentIndex + count <= MAX_CHUNK_PAYLOAD_SIZE / SEGMENT_SIZE, "Segments out of the chunk");
+        // the hashes of each level overwrite the ones of the level below
+        bytes32[] memory level = new bytes32[](count);
+        for (uint256 i = 0; i < count; i++) {
+            level[i] = _segments[i];
+        }
+        uint256 sisterIndex = 0;
+        uint256 index = _firstSegmentIndex;
+        for (uint256 width = MAX_CHUNK_PAYLOAD_SIZE / SEGMENT_SIZE; width > 1; width >>= 1) {
+            uint256 n = 0;
+            uint256 i = 0;
+            if (index % 2 == 1) {
+                // the first segment is a right child
+                level[0] = mergeSegment(level[0], _proofSegments[sisterIndex], false);
+                sisterIndex++;
+                n = 1;
+                i = 1;
+            }
+            for (; i + 1 < count; i += 2) {
+                level[n] = mergeSegment(level[i], level[i + 1], true);
+                n++;
+            }
+            if (i < count) {
+                // the last segment is a left child
+                level[n] = mergeSegment(level[i], _proofSegments[sisterIndex], true);
+                sisterIndex++;
+                n++;
+            }
+            count = n;
+            index >>= 1;
+        }
+        require(sisterIndex == _proofSegments.length, "Too many proof segments");
+        return level[0];
+    }
+
+    /**
+     * Calculate the chunk address from the proof of consecutive segments of the chunk data
+     * @param _proofSegments Proof segments.
+     * @param _segments Consecutive segments to prove.
+     * @param _firstSegmentIndex Index of the first segment to prove
+     * @param _chunkSpan chunk bytes length
+     * @return _chunkHash chunk hash
+     */
+    function chunkAddressFromSegmentsProof(
+        bytes32[] memory _proofSegments,
+        bytes32[] memory _segments,
+        uint256 _firstSegmentIndex,
+        uint64 _chunkSpan
+    ) internal pure returns (bytes32) {
+        bytes32 rootHash = rootHashFromSegmentsProof(_proofSegments, _segments, _firstSegmentIndex);
+        return chunkAddress(rootHash, _chunkSpan);
+    }
+
+    /**
+     * Calculate the chunk address from the root hash of the Binary Merkle Tree of the chunk data
+     * @param _rootHash BMT root hash.
+     * @param _chunkSpan chunk bytes length
+     * @return chunk address
+     */
+    function chunkAddress(bytes32 _rootHash, uint64 _chunkSpan) internal pure returns (bytes32) {
+        return keccak256(abi.encodePacked(reverseUint64(_chunkSpan), _rootHash));
     }
 
     function mergeSegment(
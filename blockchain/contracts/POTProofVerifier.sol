@@ -17,6 +17,18 @@ import "./BMTChunk.sol";
  *   - The `currentNodeHash` is updated to the hash of the child node (the `proveSegment` of the `forkReferenceProof`) for the next iteration or for the final entry proof verification.
  * Verifies Final Entry: After processing all intermediate forks, it calls `assertEntryProof` using the final `currentNodeHash`.
  *   - `assertEntryProof` validates the BMT proof for the bitvector of the node containing the entry and the BMT proof for the entry itself. The `entrySegmentIndex` is calculated based on the number of forks in this final node's bitvector.
+ * `assertForkPathValueProof` additionally proves the whole entry value with the segments from the entry segment
+ * until the end of the node and returns the value rebuilt from them.
+ * Nodes longer than a chunk are stored as Swarm files: each proof of a segment in such a node carries the chunk proofs
+ * of the chunk references from the data chunk holding the segment up to the root chunk of the node.
+ * `assertAbsenceProof` proves that a key is not in the trie. It traverses the fork references the same way,
+ * then checks that the node the path ends at has no fork at the PO of its key and the `targetKey` by validating the BMT proof of its bitvector.
+ * `assertSizeProof` proves the number of entries in the trie from the descendant counts of the forks of the root node.
+ * `assertRankProof` proves the number of entries with keys lower than the `targetKey` from the descendant counts
+ * of the forks of each node on its fork path.
+ * `assertMultiProof` proves multiple keys at once: the nodes on their paths are proven once each with all the segments
+ * the paths need, sharing the sister hashes within the BMTs of their chunks, then the path of each key is followed
+ * from the root through the proven fork references to the entry segment of the node holding the key.
  */
 library POTProofVerifier {
     // Maximum depth of the POT trie (256 bits)
@@ -30,11 +42,29 @@ library POTProofVerifier {
         bytes32[] proofSegments;
         bytes32 proveSegment; // value that needs to be proved
         uint64 chunkSpan;
+        ChunkProof[] chunkProofs; // empty if the node fits in a single chunk
+    }
+
+    // Proof of a chunk reference in its parent chunk for nodes stored in multiple chunks.
+    // The proven reference is the address of the chunk below and its index is known from the spans of the chunks.
+    // Chunk proofs are ordered from the parent of the chunk holding the proven segment up to the root chunk of the node.
+    struct ChunkProof {
+        bytes32[] proofSegments;
+        uint64 chunkSpan;
     }
 
     struct EntryProof {
         Proof bitVectorProof;
-        Proof entryProof; // TODO: make arbitrary length bytes
+        Proof entryProof; // first segment of the entry value
+    }
+
+    // Proof of the segments of the entry value held by a chunk of the node
+    // the value takes the segments from the entry segment until the end of the node
+    struct ValueProof {
+        bytes32[] segments;
+        bytes32[] proofSegments; // sisters not calculable from the segments, level by level from the bottom
+        uint64 chunkSpan;
+        ChunkProof[] chunkProofs; // empty if the node fits in a single chunk
     }
 
     struct ForkRefProof {
@@ -50,41 +80,520 @@ library POTProofVerifier {
         EntryProof entryProof;
     }
 
+    // Proof that the target key is not in the trie:
+    // the fork path towards the key ends at a node that has no fork at the PO of its key and the target key
+    struct AbsenceProof {
+        bytes32 rootReference;
+        bytes32 targetKey;
+        ForkRefProof[] forkRefProofs;
+        Proof bitVectorProof; // bitvector of the last node with its key as the first proof segment
+    }
+
+    // Proof of the number of entries in the trie: the entry of the root node and the descendant counts of its forks
+    struct SizeProof {
+        bytes32 rootReference;
+        Proof bitVectorProof; // bitvector of the root node
+        ValueProof[] forkSizeProofs; // segments of the descendant counts of the forks
+    }
+
+    // Proof of the number of entries with keys lower than the target key
+    struct RankProof {
+        ForkPathProof forkPathProof;
+        ValueProof[][] forkSizeProofs; // segments of the descendant counts of the forks of each node on the path, root first
+    }
+
+    // Proof of the segments of a node at the given indexes.
+    // The proof segments are the sister hashes that cannot be calculated from the segments: for each chunk of the node
+    // in depth first order, after the ones of its children, level by level from the bottom in ascending order on each level.
+    // The spans of the chunks follow from the span of the node as the Swarm file tree of the node data.
+    struct NodeProof {
+        uint64 span; // length of the node data
+        uint16[] indexes; // in ascending order
+        bytes32[] segments;
+        bytes32[] proofSegments;
+    }
+
+    // Proof of multiple keys in the trie: each node on the paths to the keys is proven once
+    // with all the segments the paths need, so upper level nodes and shared sisters are not repeated
+    struct MultiProof {
+        bytes32 rootReference;
+        bytes32[] keys;
+        NodeProof[] nodes;
+    }
+
+    // Position in the segments and the proof segments of a node proof while calculating the node address
+    struct NodeProofCursor {
+        uint256 segment;
+        uint256 proofSegment;
+    }
+
     /**
      * @notice Asserts a proof for a specific entry in the trie
      * @param proof The fork path proof containing all necessary proof segments
+     * @return entryNodeHash The hash of the node holding the entry
+     * @return entrySegmentIndex The index of the first segment of the entry value in the node
      * @dev Reverts if the proof is invalid
      */
-    function assertForkPathProof(ForkPathProof calldata proof) internal pure {
+    function assertForkPathProof(
+        ForkPathProof calldata proof
+    ) internal pure returns (bytes32 entryNodeHash, uint16 entrySegmentIndex) {
         if (proof.entryProof.bitVectorProof.proofSegments[0] != proof.targetKey) {
             revert("Entry key does not match target key");
         }
 
+        (bytes32 currentNodeHash, ) = assertForkRefProofs(proof.rootReference, proof.targetKey, proof.forkRefProofs);
+
+        entrySegmentIndex = entrySegmentIndexOf(proof.entryProof.bitVectorProof.proveSegment);
+        assertEntryProof(currentNodeHash, entrySegmentIndex, proof.entryProof);
+        return (currentNodeHash, entrySegmentIndex);
+    }
+
+    /**
+     * @notice Calculates the index of the first segment of the entry value in a node
+     * @param bitVector The bitvector of the node
+     * @return entrySegmentIndex The segment index after the fork references and the fork descendant counts
+     */
+    function entrySegmentIndexOf(bytes32 bitVector) internal pure returns (uint16 entrySegmentIndex) {
+        uint16 forkCount = countOnesInBitVectorUntil(bitVector, MAX_DEPTH);
+        uint16 forkDescendantsByteLength = forkCount * 4;
+        entrySegmentIndex = (64 + forkCount * BMT_SEGMENT_SIZE + forkDescendantsByteLength) / 32;
+        // padding after fork descendants' counts
+        if (forkDescendantsByteLength%BMT_SEGMENT_SIZE != 0) {
+            entrySegmentIndex++;
+        }
+    }
+
+    /**
+     * @notice Asserts a proof for a specific entry in the trie along with its whole value
+     * @param proof The fork path proof containing all necessary proof segments
+     * @param valueProofs The proofs of the segments of the entry value, one for each chunk holding them
+     * @return value The entry value rebuilt from the segments, trimmed to the span of the node
+     * @dev Reverts if any of the proofs is invalid
+     */
+    function assertForkPathValueProof(
+        ForkPathProof calldata proof,
+        ValueProof[] calldata valueProofs
+    ) internal pure returns (bytes memory value) {
+        (bytes32 entryNodeHash, uint16 entrySegmentIndex) = assertForkPathProof(proof);
+
+        // the span of the node is proven along with the entry
+        uint256 nodeSpan = proof.entryProof.entryProof.chunkSpan;
+        if (proof.entryProof.entryProof.chunkProofs.length > 0) {
+            ChunkProof[] calldata chunkProofs = proof.entryProof.entryProof.chunkProofs;
+            nodeSpan = chunkProofs[chunkProofs.length - 1].chunkSpan;
+        }
+        uint256 valueOffset = uint256(entrySegmentIndex) * BMT_SEGMENT_SIZE;
+        if (nodeSpan <= valueOffset) {
+            revert("Entry value is out of the node");
+        }
+
+        uint256 segmentIndex = entrySegmentIndex;
+        for (uint256 i = 0; i < valueProofs.length; i++) {
+            assertValueProof(entryNodeHash, segmentIndex, valueProofs[i]);
+            value = bytes.concat(value, abi.encodePacked(valueProofs[i].segments));
+            segmentIndex += valueProofs[i].segments.length;
+        }
+        if (segmentIndex != (nodeSpan + BMT_SEGMENT_SIZE - 1) / BMT_SEGMENT_SIZE) {
+            revert("Invalid number of value segments");
+        }
+
+        uint256 valueLength = nodeSpan - valueOffset;
+        // drop the padding of the last segment
+        assembly {
+            mstore(value, valueLength)
+        }
+    }
+
+    /**
+     * @notice Asserts a proof of consecutive segments of the entry value held by a chunk of the node
+     * @param nodeHash The hash of the node containing the entry
+     * @param segmentIndex The index of the first segment in the node data
+     * @param valueProof The proof of the segments
+     * @dev Reverts if the proof is invalid
+     */
+    function assertValueProof(bytes32 nodeHash, uint256 segmentIndex, ValueProof calldata valueProof) internal pure {
+        if (nodeAddressFromSegmentsProof(valueProof, segmentIndex) != nodeHash) {
+            revert("Invalid value proof");
+        }
+    }
+
+    /**
+     * @notice Asserts a proof of the number of entries in the trie
+     * @param proof The size proof: the bitvector of the root node and the descendant counts of its forks
+     * @return size The number of entries in the trie
+     * @dev Reverts if the proof is invalid
+     */
+    function assertSizeProof(SizeProof calldata proof) internal pure returns (uint256 size) {
+        if (nodeAddressFromInclusionProof(proof.bitVectorProof, 1) != proof.rootReference) {
+            revert("Invalid bit vector proof at assertSizeProof");
+        }
+        uint256[] memory sizes = assertForkSizesProof(
+            proof.rootReference,
+            proof.bitVectorProof.proveSegment,
+            proof.forkSizeProofs
+        );
+        return 1 + sumOf(sizes);
+    }
+
+    /**
+     * @notice Asserts a proof of the rank of a key, i.e. the number of entries with lower keys
+     * @param proof The rank proof: the fork path proof of the key and the descendant counts of the forks on the path
+     * @return rank The number of entries with keys lower than the target key
+     * @return size The number of entries in the trie
+     * @dev Reverts if the proof is invalid
+     */
+    function assertRankProof(RankProof calldata proof) internal pure returns (uint256 rank, uint256 size) {
+        ForkPathProof calldata path = proof.forkPathProof;
+        assertForkPathProof(path);
+        if (proof.forkSizeProofs.length != path.forkRefProofs.length + 1) {
+            revert("Invalid number of fork size proofs");
+        }
+
+        bytes32 currentNodeHash = path.rootReference;
+        uint16 from = 0; // the root node is viewed with all its forks
+        uint16 calculatedPO = 0;
+        for (uint256 i = 0; i < proof.forkSizeProofs.length; i++) {
+            bytes32 bitVector;
+            if (i < path.forkRefProofs.length) {
+                bitVector = path.forkRefProofs[i].bitVectorProof.proveSegment;
+                calculatedPO = calculatePO(path.forkRefProofs[i].bitVectorProof.proofSegments[0], path.targetKey, uint8(calculatedPO));
+            } else {
+                bitVector = path.entryProof.bitVectorProof.proveSegment;
+                calculatedPO = MAX_DEPTH;
+            }
+            uint256[] memory sizes = assertForkSizesProof(currentNodeHash, bitVector, proof.forkSizeProofs[i]);
+            if (i == 0) {
+                size = 1 + sumOf(sizes);
+            }
+            rank += lowerCount(path.targetKey, bitVector, sizes, from, calculatedPO);
+            if (i < path.forkRefProofs.length) {
+                currentNodeHash = path.forkRefProofs[i].forkReferenceProof.proveSegment;
+            }
+            from = calculatedPO + 1;
+        }
+    }
+
+    /**
+     * @notice Asserts a proof of the descendant counts of the forks of a node
+     * @param nodeHash The hash of the node
+     * @param bitVector The bitvector of the node
+     * @param forkSizeProofs The proofs of the segments holding the counts, following the fork references
+     * @return sizes The descendant count of each fork in the order of the forks
+     * @dev Reverts if the proofs are invalid
+     */
+    function assertForkSizesProof(
+        bytes32 nodeHash,
+        bytes32 bitVector,
+        ValueProof[] calldata forkSizeProofs
+    ) internal pure returns (uint256[] memory sizes) {
+        uint16 forkCount = countOnesInBitVectorUntil(bitVector, MAX_DEPTH);
+        uint256 segmentIndex = 2 + uint256(forkCount);
+        uint256 end = segmentIndex + (uint256(forkCount) * 4 + BMT_SEGMENT_SIZE - 1) / BMT_SEGMENT_SIZE;
+        bytes memory data;
+        for (uint256 i = 0; i < forkSizeProofs.length; i++) {
+            if (nodeAddressFromSegmentsProof(forkSizeProofs[i], segmentIndex) != nodeHash) {
+                revert("Invalid fork size proof");
+            }
+            data = bytes.concat(data, abi.encodePacked(forkSizeProofs[i].segments));
+            segmentIndex += forkSizeProofs[i].segments.length;
+        }
+        if (segmentIndex != end) {
+            revert("Invalid number of fork size segments");
+        }
+
+        sizes = new uint256[](forkCount);
+        for (uint256 i = 0; i < forkCount; i++) {
+            uint256 word;
+            assembly {
+                word := mload(add(add(data, 32), mul(i, 4)))
+            }
+            sizes[i] = word >> 224; // 4 byte big endian count
+        }
+    }
+
+    /**
+     * @notice Sums up descendant counts
+     * @param sizes The descendant counts
+     * @return total The sum of the counts
+     */
+    function sumOf(uint256[] memory sizes) internal pure returns (uint256 total) {
+        for (uint256 i = 0; i < sizes.length; i++) {
+            total += sizes[i];
+        }
+    }
+
+    /**
+     * @notice Counts the entries with keys lower than the target key under a node on the path to it
+     * @dev The entry of the node and its forks after `calculatedPO` share the first `calculatedPO` bits with the target key
+     * and are lower if the target key has its bit at `calculatedPO` set, the forks before are lower if the target key has their bit set.
+     * @param targetKey The key the path leads to
+     * @param bitVector The bitvector of the node
+     * @param sizes The descendant counts of the forks of the node
+     * @param from The first PO of the forks under the node as viewed from its parent
+     * @param calculatedPO The PO of the node key and the target key, MAX_DEPTH for the node of the target key
+     * @return count The number of lower entries
+     */
+    function lowerCount(
+        bytes32 targetKey,
+        bytes32 bitVector,
+        uint256[] memory sizes,
+        uint16 from,
+        uint16 calculatedPO
+    ) internal pure returns (uint256 count) {
+        if (isBitSet(targetKey, calculatedPO)) {
+            count++;
+        }
+        uint256 forkIndex = 0;
+        for (uint16 po = 0; po < MAX_DEPTH; po++) {
+            if (!isBitSet(bitVector, po)) {
+                continue;
+            }
+            // the fork at calculatedPO is on the path
+            if (po >= from && po != calculatedPO && isBitSet(targetKey, po < calculatedPO ? po : calculatedPO)) {
+                count += sizes[forkIndex];
+            }
+            forkIndex++;
+        }
+    }
+
+    /**
+     * @notice Asserts a proof that a key is not in the trie
+     * @param proof The absence proof: the fork path towards the target key and the bitvector of the node it ends at
+     * @dev Reverts if the proof is invalid or the target key is the key of the last node
+     */
+    function assertAbsenceProof(AbsenceProof calldata proof) internal pure {
+        (bytes32 currentNodeHash, uint16 calculatedPO) = assertForkRefProofs(proof.rootReference, proof.targetKey, proof.forkRefProofs);
+
+        bytes32 nodeKey = proof.bitVectorProof.proofSegments[0];
+        bytes32 bitVector = proof.bitVectorProof.proveSegment;
+        calculatedPO = calculatePO(nodeKey, proof.targetKey, uint8(calculatedPO));
+        if (calculatedPO == MAX_DEPTH) {
+            revert("Target key is in the trie");
+        }
+        if (isBitSet(bitVector, calculatedPO)) {
+            revert("Fork is set in the node's bitvector");
+        }
+        bytes32 bitVectorHash = nodeAddressFromInclusionProof(proof.bitVectorProof, 1);
+        if (bitVectorHash != currentNodeHash) {
+            revert("Invalid bit vector proof at assertAbsenceProof");
+        }
+    }
+
+    /**
+     * @notice Asserts a proof of multiple keys in the trie
+     * @param proof The multi proof: the keys and the proofs of the nodes on their paths
+     * @dev Reverts if the proof is invalid
+     */
+    function assertMultiProof(MultiProof calldata proof) internal pure {
+        bytes32[] memory nodeHashes = new bytes32[](proof.nodes.length);
+        for (uint256 i = 0; i < proof.nodes.length; i++) {
+            nodeHashes[i] = nodeAddressFromNodeProof(proof.nodes[i]);
+        }
+        for (uint256 i = 0; i < proof.keys.length; i++) {
+            assertMultiProofPath(proof, nodeHashes, proof.keys[i]);
+        }
+    }
+
+    /**
+     * @notice Asserts the path of a key of a multi proof from the root through the proven nodes
+     * @param proof The multi proof
+     * @param nodeHashes The hashes of the nodes of the proof
+     * @param targetKey The key the path leads to
+     * @dev Reverts if a node on the path or a segment needed from it is not in the proof
+     */
+    function assertMultiProofPath(
+        MultiProof calldata proof,
+        bytes32[] memory nodeHashes,
+        bytes32 targetKey
+    ) internal pure {
         bytes32 currentNodeHash = proof.rootReference;
         uint16 calculatedPO = 0;
-        
-        for (uint i = 0; i < proof.forkRefProofs.length; i++) {
-            bytes32 nodeKey = proof.forkRefProofs[i].bitVectorProof.proofSegments[0];
-            bytes32 bitVector = proof.forkRefProofs[i].bitVectorProof.proveSegment;
-            calculatedPO = calculatePO(nodeKey, proof.targetKey, uint8(calculatedPO));
+        while (true) {
+            NodeProof calldata node = proof.nodes[nodeIndexOf(nodeHashes, currentNodeHash)];
+            bytes32 bitVector = segmentOf(node, 1);
+            calculatedPO = calculatePO(segmentOf(node, 0), targetKey, uint8(calculatedPO));
+            if (calculatedPO == MAX_DEPTH) {
+                // the entry is proven by its first segment
+                segmentOf(node, entrySegmentIndexOf(bitVector));
+                return;
+            }
+            if (!isBitSet(bitVector, calculatedPO)) {
+                revert("Fork is not set in the parent's bitvector");
+            }
+            currentNodeHash = segmentOf(node, 2 + countOnesInBitVectorUntil(bitVector, calculatedPO));
+        }
+    }
+
+    /**
+     * @notice Finds a node of a multi proof by its hash
+     * @param nodeHashes The hashes of the nodes of the proof
+     * @param nodeHash The hash of the node
+     * @return The index of the node in the proof
+     * @dev Reverts if the node is not in the proof
+     */
+    function nodeIndexOf(bytes32[] memory nodeHashes, bytes32 nodeHash) internal pure returns (uint256) {
+        for (uint256 i = 0; i < nodeHashes.length; i++) {
+            if (nodeHashes[i] == nodeHash) {
+                return i;
+            }
+        }
+        revert("Node is not in the proof");
+    }
+
+    /**
+     * @notice Returns a proven segment of a node
+     * @param node The proof of the node
+     * @param index The index of the segment in the node data
+     * @return The segment
+     * @dev Reverts if the segment is not in the proof
+     */
+    function segmentOf(NodeProof calldata node, uint16 index) internal pure returns (bytes32) {
+        for (uint256 i = 0; i < node.indexes.length; i++) {
+            if (node.indexes[i] == index) {
+                return node.segments[i];
+            }
+        }
+        revert("Segment is not in the proof");
+    }
+
+    /**
+     * @notice Calculates the address of a node from the proof of some of its segments
+     * @param node The proof of the node
+     * @return nodeAddress The address of the node
+     * @dev Reverts if the proof is malformed
+     */
+    function nodeAddressFromNodeProof(NodeProof calldata node) internal pure returns (bytes32 nodeAddress) {
+        if (node.indexes.length == 0 || node.indexes.length != node.segments.length) {
+            revert("Invalid number of segments");
+        }
+        for (uint256 i = 1; i < node.indexes.length; i++) {
+            if (node.indexes[i] <= node.indexes[i - 1]) {
+                revert("Indexes are not ascending");
+            }
+        }
+        NodeProofCursor memory cursor = NodeProofCursor(0, 0);
+        nodeAddress = chunkAddressFromNodeProof(node, cursor, node.span, 0);
+        if (cursor.segment != node.indexes.length) {
+            revert("Segment is out of the node");
+        }
+        if (cursor.proofSegment != node.proofSegments.length) {
+            revert("Too many proof segments");
+        }
+    }
+
+    /**
+     * @notice Calculates the address of a chunk of a node from the proof of the node
+     * @dev The segments under the chunk are taken from the cursor on, the ones of intermediate chunks
+     * are the addresses of their children calculated recursively.
+     * @param node The proof of the node
+     * @param cursor The next segment and proof segment to use
+     * @param span The span of the chunk
+     * @param offset The offset of the data under the chunk in the node data
+     * @return The address of the chunk
+     */
+    function chunkAddressFromNodeProof(
+        NodeProof calldata node,
+        NodeProofCursor memory cursor,
+        uint256 span,
+        uint256 offset
+    ) internal pure returns (bytes32) {
+        uint256[] memory positions = new uint256[](node.indexes.length - cursor.segment);
+        bytes32[] memory level = new bytes32[](node.indexes.length - cursor.segment);
+        uint256 count = 0;
+        if (span <= BMTChunk.MAX_CHUNK_PAYLOAD_SIZE) {
+            while (cursor.segment < node.indexes.length && uint256(node.indexes[cursor.segment]) * BMT_SEGMENT_SIZE < offset + span) {
+                positions[count] = uint256(node.indexes[cursor.segment]) - offset / BMT_SEGMENT_SIZE;
+                level[count] = node.segments[cursor.segment];
+                count++;
+                cursor.segment++;
+            }
+        } else {
+            uint256 capacity = childCapacity(span);
+            while (cursor.segment < node.indexes.length && uint256(node.indexes[cursor.segment]) * BMT_SEGMENT_SIZE < offset + span) {
+                uint256 childIndex = (uint256(node.indexes[cursor.segment]) * BMT_SEGMENT_SIZE - offset) / capacity;
+                // only the last child may hold less than the capacity
+                uint256 childSpan = span - childIndex * capacity < capacity ? span - childIndex * capacity : capacity;
+                positions[count] = childIndex;
+                level[count] = chunkAddressFromNodeProof(node, cursor, childSpan, offset + childIndex * capacity);
+                count++;
+            }
+        }
+        if (count == 0) {
+            revert("Segment is out of the node");
+        }
+        bytes32 rootHash = rootHashFromNodeProof(node.proofSegments, cursor, positions, level, count);
+        return BMTChunk.chunkAddress(rootHash, uint64(span));
+    }
+
+    /**
+     * @notice Calculates the BMT root hash of a chunk from some of its segments
+     * @dev The hashes of each level overwrite the ones of the level below.
+     * @param proofSegments The sister hashes of the node proof
+     * @param cursor The next proof segment to use
+     * @param positions The ascending positions of the segments in the chunk
+     * @param level The segments
+     * @param count The number of segments
+     * @return The BMT root hash
+     */
+    function rootHashFromNodeProof(
+        bytes32[] calldata proofSegments,
+        NodeProofCursor memory cursor,
+        uint256[] memory positions,
+        bytes32[] memory level,
+        uint256 count
+    ) internal pure returns (bytes32) {
+        for (uint256 width = BMTChunk.MAX_CHUNK_PAYLOAD_SIZE / BMTChunk.SEGMENT_SIZE; width > 1; width >>= 1) {
+            uint256 n = 0;
+            for (uint256 i = 0; i < count; i++) {
+                uint256 position = positions[i];
+                if (position % 2 == 0 && i + 1 < count && positions[i + 1] == position + 1) {
+                    // the sister is proven too
+                    level[n] = BMTChunk.mergeSegment(level[i], level[i + 1], true);
+                    i++;
+                } else {
+                    if (cursor.proofSegment == proofSegments.length) {
+                        revert("Missing proof segments");
+                    }
+                    level[n] = BMTChunk.mergeSegment(level[i], proofSegments[cursor.proofSegment], position % 2 == 0);
+                    cursor.proofSegment++;
+                }
+                positions[n] = position / 2;
+                n++;
+            }
+            count = n;
+        }
+        return level[0];
+    }
+
+    /**
+     * @notice Asserts the fork reference proofs of a path from the root towards the target key
+     * @param rootReference The hash of the root node
+     * @param targetKey The key the path leads to
+     * @param forkRefProofs The fork reference proofs along the path
+     * @return currentNodeHash The hash of the node the path ends at
+     * @return calculatedPO The proximity order of the last fork on the path
+     * @dev Reverts if any of the proofs is invalid
+     */
+    function assertForkRefProofs(
+        bytes32 rootReference,
+        bytes32 targetKey,
+        ForkRefProof[] calldata forkRefProofs
+    ) internal pure returns (bytes32 currentNodeHash, uint16 calculatedPO) {
+        currentNodeHash = rootReference;
+
+        for (uint i = 0; i < forkRefProofs.length; i++) {
+            bytes32 nodeKey = forkRefProofs[i].bitVectorProof.proofSegments[0];
+            bytes32 bitVector = forkRefProofs[i].bitVectorProof.proveSegment;
+            calculatedPO = calculatePO(nodeKey, targetKey, uint8(calculatedPO));
             if(!isBitSet(bitVector, calculatedPO)) {
                 revert("Fork is not set in the parent's bitvector");
             }
             uint16 forkIndex = countOnesInBitVectorUntil(bitVector, calculatedPO); // forks before
             uint16 forkRefSegmentIndex = 2 + forkIndex;
-            assertForkRefProof(currentNodeHash, proof.forkRefProofs[i], forkRefSegmentIndex);
+            assertForkRefProof(currentNodeHash, forkRefProofs[i], forkRefSegmentIndex);
 
-            currentNodeHash = proof.forkRefProofs[i].forkReferenceProof.proveSegment;
-        }
-
-        uint16 forkCount = countOnesInBitVectorUntil(proof.entryProof.bitVectorProof.proveSegment, MAX_DEPTH);
-        uint16 forkDescendantsByteLength = forkCount * 4;
-        uint16 entrySegmentIndex = (64 + forkCount * BMT_SEGMENT_SIZE + forkDescendantsByteLength) / 32;
-        // padding after fork descendants' counts
-        if (forkDescendantsByteLength%BMT_SEGMENT_SIZE != 0) {
-            entrySegmentIndex++;
+            currentNodeHash = forkRefProofs[i].forkReferenceProof.proveSegment;
         }
-        assertEntryProof(currentNodeHash, entrySegmentIndex, proof.entryProof);
     }
 
     /**
@@ -99,22 +608,12 @@ library POTProofVerifier {
         ForkRefProof calldata proof,
         uint16 forkRefSegmentIndex
     ) internal pure {
-        bytes32 bitVectorHash = BMTChunk.chunkAddressFromInclusionProof(
-            proof.bitVectorProof.proofSegments, 
-            proof.bitVectorProof.proveSegment, 
-            1, 
-            proof.bitVectorProof.chunkSpan
-        );
+        bytes32 bitVectorHash = nodeAddressFromInclusionProof(proof.bitVectorProof, 1);
         if (bitVectorHash != nodeHash) {
             revert("Invalid bit vector proof at assertForkRefProof");
         }
 
-        bytes32 forkRefHash = BMTChunk.chunkAddressFromInclusionProof(
-            proof.forkReferenceProof.proofSegments,
-            proof.forkReferenceProof.proveSegment,
-            forkRefSegmentIndex,
-            proof.forkReferenceProof.chunkSpan
-        );
+        bytes32 forkRefHash = nodeAddressFromInclusionProof(proof.forkReferenceProof, forkRefSegmentIndex);
         if (forkRefHash != nodeHash) {
             revert("Invalid fork reference proof");
         }
@@ -128,27 +627,132 @@ library POTProofVerifier {
      * @dev Reverts if the proof is invalid
      */
     function assertEntryProof(bytes32 nodeHash, uint16 entrySegmentIndex, EntryProof calldata proof) internal pure {
-        bytes32 bitVectorHash = BMTChunk.chunkAddressFromInclusionProof(
-            proof.bitVectorProof.proofSegments, 
-            proof.bitVectorProof.proveSegment, 
-            1, 
-            proof.bitVectorProof.chunkSpan
-        );
+        bytes32 bitVectorHash = nodeAddressFromInclusionProof(proof.bitVectorProof, 1);
         if (bitVectorHash != nodeHash) {
             revert("Invalid bit vector proof at assertEntryProof");
         }
         
-        bytes32 entryHash = BMTChunk.chunkAddressFromInclusionProof(
-            proof.entryProof.proofSegments,
-            proof.entryProof.proveSegment,
-            entrySegmentIndex,
-            proof.entryProof.chunkSpan
-        );
+        bytes32 entryHash = nodeAddressFromInclusionProof(proof.entryProof, entrySegmentIndex);
         if (entryHash != nodeHash) {
             revert("Invalid entry proof");
         }
     }
 
+    /**
+     * @notice Calculates the address of a node from the proof of one of its segments
+     * @param proof The proof of the segment along with the chunk proofs if the node is stored in multiple chunks
+     * @param segmentIndex The index of the segment in the node data
+     * @return The address of the node
+     */
+    function nodeAddressFromInclusionProof(Proof calldata proof, uint256 segmentIndex) internal pure returns (bytes32) {
+        (uint256 offset, uint256[] memory childIndexes) = locateSegment(
+            segmentIndex * BMT_SEGMENT_SIZE,
+            proof.chunkSpan,
+            proof.chunkProofs
+        );
+        bytes32 chunkAddress = BMTChunk.chunkAddressFromInclusionProof(
+            proof.proofSegments,
+            proof.proveSegment,
+            offset / BMT_SEGMENT_SIZE,
+            proof.chunkSpan
+        );
+        return nodeAddressFromChunkAddress(chunkAddress, proof.chunkProofs, childIndexes);
+    }
+
+    /**
+     * @notice Calculates the address of a node from the proof of consecutive segments of one of its chunks
+     * @param proof The proof of the segments along with the chunk proofs if the node is stored in multiple chunks
+     * @param segmentIndex The index of the first segment in the node data
+     * @return The address of the node
+     */
+    function nodeAddressFromSegmentsProof(ValueProof calldata proof, uint256 segmentIndex) internal pure returns (bytes32) {
+        (uint256 offset, uint256[] memory childIndexes) = locateSegment(
+            segmentIndex * BMT_SEGMENT_SIZE,
+            proof.chunkSpan,
+            proof.chunkProofs
+        );
+        bytes32 chunkAddress = BMTChunk.chunkAddressFromSegmentsProof(
+            proof.proofSegments,
+            proof.segments,
+            offset / BMT_SEGMENT_SIZE,
+            proof.chunkSpan
+        );
+        return nodeAddressFromChunkAddress(chunkAddress, proof.chunkProofs, childIndexes);
+    }
+
+    /**
+     * @notice Locates a byte of the node data in the Swarm file tree of a node stored in multiple chunks
+     * @param offset The offset of the byte in the node data
+     * @param dataSpan The span of the data chunk holding the byte
+     * @param chunkProofs The chunk proofs from the parent of the data chunk up to the root chunk of the node
+     * @return The offset of the byte in the data chunk
+     * @return childIndexes The index of the chunk reference proven by each chunk proof
+     * @dev Reverts if the spans of the chunks do not match the ones of the Swarm file tree
+     */
+    function locateSegment(
+        uint256 offset,
+        uint64 dataSpan,
+        ChunkProof[] calldata chunkProofs
+    ) internal pure returns (uint256, uint256[] memory childIndexes) {
+        childIndexes = new uint256[](chunkProofs.length);
+        for (uint256 k = chunkProofs.length; k > 0; k--) {
+            uint256 span = chunkProofs[k - 1].chunkSpan;
+            if (span <= BMTChunk.MAX_CHUNK_PAYLOAD_SIZE) {
+                revert("Invalid chunk span");
+            }
+            uint256 capacity = childCapacity(span);
+            uint256 childIndex = offset / capacity;
+            if (childIndex * capacity >= span) {
+                revert("Segment is out of the node");
+            }
+            offset %= capacity;
+
+            // only the last child may hold less than the capacity
+            uint256 childSpan = k > 1 ? chunkProofs[k - 2].chunkSpan : dataSpan;
+            if (childSpan != (span - childIndex * capacity < capacity ? span - childIndex * capacity : capacity)) {
+                revert("Invalid chunk span");
+            }
+            childIndexes[k - 1] = childIndex;
+        }
+        return (offset, childIndexes);
+    }
+
+    /**
+     * @notice Calculates the length of data the children of an intermediate chunk can hold
+     * @param span The span of the intermediate chunk
+     * @return capacity The length of data under each child but the last one
+     */
+    function childCapacity(uint256 span) internal pure returns (uint256 capacity) {
+        uint256 branches = BMTChunk.MAX_CHUNK_PAYLOAD_SIZE / BMTChunk.SEGMENT_SIZE;
+        capacity = BMTChunk.MAX_CHUNK_PAYLOAD_SIZE;
+        while (capacity * branches < span) {
+            capacity *= branches;
+        }
+    }
+
+    /**
+     * @notice Calculates the address of a node from the address of one of its chunks
+     * @param chunkAddress The address of the chunk
+     * @param chunkProofs The chunk proofs from the parent of the chunk up to the root chunk of the node
+     * @param childIndexes The index of the chunk reference proven by each chunk proof
+     * @return The address of the node
+     */
+    function nodeAddressFromChunkAddress(
+        bytes32 chunkAddress,
+        ChunkProof[] calldata chunkProofs,
+        uint256[] memory childIndexes
+    ) internal pure returns (bytes32) {
+        for (uint256 k = 0; k < chunkProofs.length; k++) {
+            chunkAddress = BMTChunk.chunkAddressFromInclusionProof(
+                chunkProofs[k].proofSegments,
+                chunkAddress,
+                childIndexes[k],
+                chunkProofs[k].chunkSpan
+            );
+        }
+        return chunkAddress;
+    }
+
     /**
      * @notice Calculates the proximity order (PO) between nodeKey and targetKey
      * @param nodeKey The key of the node
@@ -15,6 +15,44 @@ contract POTProofVerifierTester {
         POTProofVerifier.assertForkPathProof(proof);
     }
 
+    /**
+     * @notice Public wrapper for the library's assertForkPathValueProof function
+     */
+    function assertForkPathValueProof(
+        POTProofVerifier.ForkPathProof calldata proof,
+        POTProofVerifier.ValueProof[] calldata valueProofs
+    ) public pure returns (bytes memory) {
+        return POTProofVerifier.assertForkPathValueProof(proof, valueProofs);
+    }
+
+    /**
+     * @notice Public wrapper for the library's assertAbsenceProof function
+     */
+    function assertAbsenceProof(POTProofVerifier.AbsenceProof calldata proof) public pure {
+        POTProofVerifier.assertAbsenceProof(proof);
+    }
+
+    /**
+     * @notice Public wrapper for the library's assertSizeProof function
+     */
+    function assertSizeProof(POTProofVerifier.SizeProof calldata proof) public pure returns (uint256) {
+        return POTProofVerifier.assertSizeProof(proof);
+    }
+
+    /**
+     * @notice Public wrapper for the library's assertRankProof function
+     */
+    function assertRankProof(POTProofVerifier.RankProof calldata proof) public pure returns (uint256, uint256) {
+        return POTProofVerifier.assertRankProof(proof);
+    }
+
+    /**
+     * @notice Public wrapper for the library's assertMultiProof function
+     */
+    function assertMultiProof(POTProofVerifier.MultiProof calldata proof) public pure {
+        POTProofVerifier.assertMultiProof(proof);
+    }
+
     /**
      * @notice Public wrapper for the library's calculatePO function
      */
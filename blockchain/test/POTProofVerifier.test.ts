@@ -8,12 +8,23 @@ interface POTProofVerifierTesterContract extends BaseContract {
   countOnesInBitVectorUntilPublic(bitVector: string, index: number): Promise<number>;
   calculatePOPublic(key1: string, key2: string, startPosition: number): Promise<number>;
   assertForkPathProof(proof: any): Promise<void>;
+  assertForkPathValueProof(proof: any, valueProofs: any): Promise<string>;
+  assertAbsenceProof(proof: any): Promise<void>;
+  assertSizeProof(proof: any): Promise<bigint>;
+  assertRankProof(proof: any): Promise<[bigint, bigint]>;
+  assertMultiProof(proof: any): Promise<void>;
 }
 
 interface Proof {
   proofSegments: string[];
   proveSegment: string;
   chunkSpan: number;
+  chunkProofs: ChunkProof[];
+}
+
+interface ChunkProof {
+  proofSegments: string[];
+  chunkSpan: number;
 }
 
 interface EntryProof {
@@ -33,6 +44,44 @@ interface ForkPathProof {
   entryProof: EntryProof;
 }
 
+interface ValueProof {
+  segments: string[];
+  proofSegments: string[];
+  chunkSpan: number;
+  chunkProofs: ChunkProof[];
+}
+
+interface AbsenceProof {
+  rootReference: string;
+  targetKey: string;
+  forkRefProofs: ForkRefProof[];
+  bitVectorProof: Proof;
+}
+
+interface SizeProof {
+  rootReference: string;
+  bitVectorProof: Proof;
+  forkSizeProofs: ValueProof[];
+}
+
+interface RankProof {
+  forkPathProof: ForkPathProof;
+  forkSizeProofs: ValueProof[][];
+}
+
+interface NodeProof {
+  span: number;
+  indexes: number[];
+  segments: string[];
+  proofSegments: string[];
+}
+
+interface MultiProof {
+  rootReference: string;
+  keys: string[];
+  nodes: NodeProof[];
+}
+
 describe("POTProofVerifier", function () {
   let potProofVerifierTester: POTProofVerifierTesterContract;
 
@@ -156,4 +205,352 @@ describe("POTProofVerifier", function () {
     });
 
   });
+
+  describe("Value Proof Verification", function () {
+    // this proof is generated from the pkg/proof/forkpath_test.go entry with a 200 bytes long value.
+    const sample: { forkPathProof: ForkPathProof; valueProofs: ValueProof[]; value: string } = require("./valueProofSample.json");
+
+    it("should return the whole entry value", async function () {
+      expect(await potProofVerifierTester.assertForkPathValueProof(sample.forkPathProof, sample.valueProofs)).to.equal(sample.value);
+    });
+
+    it("should revert for invalid value segment", async function () {
+      const wrongProofs = JSON.parse(JSON.stringify(sample.valueProofs));
+      wrongProofs[0].segments[1] = "0x0000000000000000000000000000000000000000000000000000000000000000";
+      await expect(potProofVerifierTester.assertForkPathValueProof(sample.forkPathProof, wrongProofs)).to.be.revertedWith("Invalid value proof");
+    });
+
+    it("should revert for invalid proof segment", async function () {
+      const wrongProofs = JSON.parse(JSON.stringify(sample.valueProofs));
+      wrongProofs[0].proofSegments[0] = "0xFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF";
+      await expect(potProofVerifierTester.assertForkPathValueProof(sample.forkPathProof, wrongProofs)).to.be.revertedWith("Invalid value proof");
+    });
+
+    it("should revert for missing value segments", async function () {
+      await expect(potProofVerifierTester.assertForkPathValueProof(sample.forkPathProof, [])).to.be.revertedWith("Invalid number of value segments");
+    });
+
+    it("should revert for reordered value segments", async function () {
+      const wrongProofs = JSON.parse(JSON.stringify(sample.valueProofs));
+      const segments = wrongProofs[0].segments;
+      [segments[0], segments[1]] = [segments[1], segments[0]];
+      await expect(potProofVerifierTester.assertForkPathValueProof(sample.forkPathProof, wrongProofs)).to.be.revertedWith("Invalid value proof");
+    });
+
+    it("should revert for truncated value segments", async function () {
+      const wrongProofs = JSON.parse(JSON.stringify(sample.valueProofs));
+      wrongProofs[0].segments.pop();
+      await expect(potProofVerifierTester.assertForkPathValueProof(sample.forkPathProof, wrongProofs)).to.be.revertedWith("Invalid value proof");
+    });
+
+    it("should revert for extra proof segment", async function () {
+      const wrongProofs = JSON.parse(JSON.stringify(sample.valueProofs));
+      wrongProofs[0].proofSegments.push(wrongProofs[0].proofSegments[0]);
+      await expect(potProofVerifierTester.assertForkPathValueProof(sample.forkPathProof, wrongProofs)).to.be.revertedWith("Too many proof segments");
+    });
+
+    it("should revert for invalid entry proof", async function () {
+      const wrongProof = JSON.parse(JSON.stringify(sample.forkPathProof));
+      wrongProof.entryProof.entryProof.proveSegment = "0x0000000000000000000000000000000000000000000000000000000000000000";
+      await expect(potProofVerifierTester.assertForkPathValueProof(wrongProof, sample.valueProofs)).to.be.revertedWith("Invalid entry proof");
+    });
+  });
+
+  describe("Multi-chunk Proof Verification", function () {
+    // this proof is generated from a pot whose nodes on the path have more than 4096 bytes of data,
+    // with a 10000 bytes long value at the target key.
+    const sample: { forkPathProof: ForkPathProof; valueProofs: ValueProof[]; value: string } = require("./multiChunkProofSample.json");
+
+    it("should accept multi-chunk entry proof", async function () {
+      expect(await potProofVerifierTester.assertForkPathProof(sample.forkPathProof)).not.to.be.reverted;
+    });
+
+    it("should return the whole entry value", async function () {
+      expect(await potProofVerifierTester.assertForkPathValueProof(sample.forkPathProof, sample.valueProofs)).to.equal(sample.value);
+    });
+
+    it("should revert for invalid chunk proof", async function () {
+      const wrongProof = JSON.parse(JSON.stringify(sample.forkPathProof));
+      wrongProof.forkRefProofs[0].forkReferenceProof.chunkProofs[0].proofSegments[0] = "0xFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF";
+      await expect(potProofVerifierTester.assertForkPathProof(wrongProof)).to.be.revertedWith("Invalid fork reference proof");
+    });
+
+    it("should revert for missing chunk proofs", async function () {
+      const wrongProof = JSON.parse(JSON.stringify(sample.forkPathProof));
+      wrongProof.forkRefProofs[0].forkReferenceProof.chunkProofs = [];
+      await expect(potProofVerifierTester.assertForkPathProof(wrongProof)).to.be.revertedWith("Invalid fork reference proof");
+    });
+
+    it("should revert for invalid chunk span", async function () {
+      const wrongProof = JSON.parse(JSON.stringify(sample.forkPathProof));
+      wrongProof.forkRefProofs[0].forkReferenceProof.chunkProofs[0].chunkSpan += 32;
+      await expect(potProofVerifierTester.assertForkPathProof(wrongProof)).to.be.revertedWith("Invalid chunk span");
+    });
+
+    it("should revert for invalid value chunk proof", async function () {
+      const wrongProofs = JSON.parse(JSON.stringify(sample.valueProofs));
+      wrongProofs[1].chunkProofs[0].proofSegments[0] = "0xFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF";
+      await expect(potProofVerifierTester.assertForkPathValueProof(sample.forkPathProof, wrongProofs)).to.be.revertedWith("Invalid value proof");
+    });
+
+    it("should revert for invalid value chunk span", async function () {
+      const wrongProofs = JSON.parse(JSON.stringify(sample.valueProofs));
+      // the last chunk of the node is shorter than the others
+      wrongProofs[wrongProofs.length - 1].chunkSpan = 4096;
+      await expect(potProofVerifierTester.assertForkPathValueProof(sample.forkPathProof, wrongProofs)).to.be.revertedWith("Invalid chunk span");
+    });
+
+    it("should revert for reordered value chunks", async function () {
+      const wrongProofs = JSON.parse(JSON.stringify(sample.valueProofs));
+      [wrongProofs[1], wrongProofs[2]] = [wrongProofs[2], wrongProofs[1]];
+      await expect(potProofVerifierTester.assertForkPathValueProof(sample.forkPathProof, wrongProofs)).to.be.revertedWith("Invalid value proof");
+    });
+
+    it("should revert for missing value proof", async function () {
+      const wrongProofs = JSON.parse(JSON.stringify(sample.valueProofs));
+      wrongProofs.pop();
+      await expect(potProofVerifierTester.assertForkPathValueProof(sample.forkPathProof, wrongProofs)).to.be.revertedWith("Invalid number of value segments");
+    });
+  });
+
+  describe("Absence Proof Verification", function () {
+    // this proof is generated from the pkg/proof/absence_test.go 2 level case.
+    const proof: AbsenceProof = require("./absenceProofSample.json");
+
+    it("should accept absence proof", async function () {
+      expect(await potProofVerifierTester.assertAbsenceProof(proof)).not.to.be.reverted;
+    });
+
+    it("should revert for target key in the trie", async function () {
+      const wrongProof = JSON.parse(JSON.stringify(proof));
+      wrongProof.targetKey = "0x8080000000000000000000000000000000000000000000000000000000000000";
+      await expect(potProofVerifierTester.assertAbsenceProof(wrongProof)).to.be.revertedWith("Target key is in the trie");
+    });
+
+    it("should revert for fork set in the node's bitvector", async function () {
+      const wrongProof = JSON.parse(JSON.stringify(proof));
+      wrongProof.bitVectorProof.proveSegment = "0xFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF";
+      await expect(potProofVerifierTester.assertAbsenceProof(wrongProof)).to.be.revertedWith("Fork is set in the node's bitvector");
+    });
+
+    it("should revert for invalid bit vector proof at assertAbsenceProof", async function () {
+      const wrongProof = JSON.parse(JSON.stringify(proof));
+      wrongProof.bitVectorProof.proveSegment = "0x0100000000000000000000000000000000000000000000000000000000000000";
+      await expect(potProofVerifierTester.assertAbsenceProof(wrongProof)).to.be.revertedWith("Invalid bit vector proof at assertAbsenceProof");
+    });
+
+    it("should revert for invalid fork reference proof", async function () {
+      const wrongProof = JSON.parse(JSON.stringify(proof));
+      wrongProof.forkRefProofs[0].forkReferenceProof.proveSegment = "0x0000000000000000000000000000000000000000000000000000000000000000";
+      await expect(potProofVerifierTester.assertAbsenceProof(wrongProof)).to.be.revertedWith("Invalid fork reference proof");
+    });
+
+    describe("multi-chunk", function () {
+      // this proof is generated from the multi-chunk index of pkg/proof/encoding_test.go for a key
+      // that has no fork at the root, whose bitvector is in the first of its chunks.
+      const multiChunkProof: AbsenceProof = require("./multiChunkAbsenceProofSample.json");
+
+      it("should accept absence proof", async function () {
+        expect(await potProofVerifierTester.assertAbsenceProof(multiChunkProof)).not.to.be.reverted;
+      });
+
+      it("should revert for invalid chunk proof", async function () {
+        const wrongProof = JSON.parse(JSON.stringify(multiChunkProof));
+        wrongProof.bitVectorProof.chunkProofs[0].proofSegments[0] = "0xFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF";
+        await expect(potProofVerifierTester.assertAbsenceProof(wrongProof)).to.be.revertedWith("Invalid bit vector proof at assertAbsenceProof");
+      });
+
+      it("should revert for missing chunk proofs", async function () {
+        const wrongProof = JSON.parse(JSON.stringify(multiChunkProof));
+        wrongProof.bitVectorProof.chunkProofs = [];
+        await expect(potProofVerifierTester.assertAbsenceProof(wrongProof)).to.be.revertedWith("Invalid bit vector proof at assertAbsenceProof");
+      });
+
+      it("should revert for invalid chunk span", async function () {
+        const wrongProof = JSON.parse(JSON.stringify(multiChunkProof));
+        wrongProof.bitVectorProof.chunkSpan += 32;
+        await expect(potProofVerifierTester.assertAbsenceProof(wrongProof)).to.be.revertedWith("Invalid chunk span");
+      });
+    });
+  });
+
+  describe("Size Proof Verification", function () {
+    // this proof is generated from the pkg/proof/size_test.go single order index with 20 entries.
+    const proof: SizeProof = require("./sizeProofSample.json");
+
+    it("should return the number of entries", async function () {
+      expect(await potProofVerifierTester.assertSizeProof(proof)).to.equal(20n);
+    });
+
+    it("should revert for invalid fork size", async function () {
+      const wrongProof = JSON.parse(JSON.stringify(proof));
+      wrongProof.forkSizeProofs[0].segments[0] = "0xFFFFFFFF00000000000000000000000000000000000000000000000000000000";
+      await expect(potProofVerifierTester.assertSizeProof(wrongProof)).to.be.revertedWith("Invalid fork size proof");
+    });
+
+    it("should revert for missing fork size segments", async function () {
+      const wrongProof = JSON.parse(JSON.stringify(proof));
+      wrongProof.forkSizeProofs = [];
+      await expect(potProofVerifierTester.assertSizeProof(wrongProof)).to.be.revertedWith("Invalid number of fork size segments");
+    });
+
+    it("should revert for invalid bit vector proof at assertSizeProof", async function () {
+      const wrongProof = JSON.parse(JSON.stringify(proof));
+      wrongProof.bitVectorProof.proveSegment = "0xFF00000000000000000000000000000000000000000000000000000000000000";
+      await expect(potProofVerifierTester.assertSizeProof(wrongProof)).to.be.revertedWith("Invalid bit vector proof at assertSizeProof");
+    });
+
+    describe("multi-chunk", function () {
+      // this proof is generated from the multi-chunk index of pkg/proof/encoding_test.go with 201 entries,
+      // whose root node is stored in multiple chunks.
+      const multiChunkProof: SizeProof = require("./multiChunkSizeProofSample.json");
+
+      it("should return the number of entries", async function () {
+        expect(await potProofVerifierTester.assertSizeProof(multiChunkProof)).to.equal(201n);
+      });
+
+      it("should revert for invalid bit vector chunk proof", async function () {
+        const wrongProof = JSON.parse(JSON.stringify(multiChunkProof));
+        wrongProof.bitVectorProof.chunkProofs[0].proofSegments[0] = "0xFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF";
+        await expect(potProofVerifierTester.assertSizeProof(wrongProof)).to.be.revertedWith("Invalid bit vector proof at assertSizeProof");
+      });
+
+      it("should revert for invalid fork size chunk proof", async function () {
+        const wrongProof = JSON.parse(JSON.stringify(multiChunkProof));
+        wrongProof.forkSizeProofs[0].chunkProofs[0].proofSegments[0] = "0xFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF";
+        await expect(potProofVerifierTester.assertSizeProof(wrongProof)).to.be.revertedWith("Invalid fork size proof");
+      });
+    });
+  });
+
+  describe("Rank Proof Verification", function () {
+    // this proof is generated from the same index for the first key, which is on a path of 4 nodes.
+    const proof: RankProof = require("./rankProofSample.json");
+
+    it("should return the rank of the key and the number of entries", async function () {
+      const [rank, size] = await potProofVerifierTester.assertRankProof(proof);
+      expect(rank).to.equal(17n);
+      expect(size).to.equal(20n);
+    });
+
+    it("should revert for invalid fork size below the root", async function () {
+      const wrongProof = JSON.parse(JSON.stringify(proof));
+      wrongProof.forkSizeProofs[1][0].segments[0] = "0xFFFFFFFF00000000000000000000000000000000000000000000000000000000";
+      await expect(potProofVerifierTester.assertRankProof(wrongProof)).to.be.revertedWith("Invalid fork size proof");
+    });
+
+    it("should revert for missing node", async function () {
+      const wrongProof = JSON.parse(JSON.stringify(proof));
+      wrongProof.forkSizeProofs.pop();
+      await expect(potProofVerifierTester.assertRankProof(wrongProof)).to.be.revertedWith("Invalid number of fork size proofs");
+    });
+
+    it("should revert for invalid fork path", async function () {
+      const wrongProof = JSON.parse(JSON.stringify(proof));
+      wrongProof.forkPathProof.entryProof.entryProof.proveSegment = "0x0000000000000000000000000000000000000000000000000000000000000000";
+      await expect(potProofVerifierTester.assertRankProof(wrongProof)).to.be.revertedWith("Invalid entry proof");
+    });
+
+    describe("multi-chunk", function () {
+      // this proof is generated from the multi-chunk index of pkg/proof/encoding_test.go for a key
+      // forking off its root, which is stored in multiple chunks.
+      const multiChunkProof: RankProof = require("./multiChunkRankProofSample.json");
+
+      it("should return the rank of the key and the number of entries", async function () {
+        const [rank, size] = await potProofVerifierTester.assertRankProof(multiChunkProof);
+        expect(rank).to.equal(195n);
+        expect(size).to.equal(201n);
+      });
+
+      it("should revert for invalid fork size chunk proof of the root", async function () {
+        const wrongProof = JSON.parse(JSON.stringify(multiChunkProof));
+        wrongProof.forkSizeProofs[0][0].chunkProofs[0].proofSegments[0] = "0xFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF";
+        await expect(potProofVerifierTester.assertRankProof(wrongProof)).to.be.revertedWith("Invalid fork size proof");
+      });
+
+      it("should revert for invalid fork reference chunk proof", async function () {
+        const wrongProof = JSON.parse(JSON.stringify(multiChunkProof));
+        wrongProof.forkPathProof.forkRefProofs[0].forkReferenceProof.chunkProofs[0].proofSegments[0] = "0xFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF";
+        await expect(potProofVerifierTester.assertRankProof(wrongProof)).to.be.revertedWith("Invalid fork reference proof");
+      });
+    });
+  });
+
+  describe("Multi Proof Verification", function () {
+    // this proof is generated from the same index for its first 5 keys, whose paths share the root and upper nodes.
+    const proof: MultiProof = require("./multiProofSample.json");
+    // this proof is generated from a pot whose root node has more than 4096 bytes of data,
+    // with keys forking off the root in both of its chunks.
+    const multiChunkProof: MultiProof = require("./multiChunkMultiProofSample.json");
+
+    it("should accept multi proof", async function () {
+      expect(await potProofVerifierTester.assertMultiProof(proof)).not.to.be.reverted;
+    });
+
+    it("should accept multi-chunk multi proof", async function () {
+      expect(await potProofVerifierTester.assertMultiProof(multiChunkProof)).not.to.be.reverted;
+    });
+
+    it("should revert for invalid segment", async function () {
+      const wrongProof = JSON.parse(JSON.stringify(proof));
+      wrongProof.nodes[1].segments[0] = "0x0000000000000000000000000000000000000000000000000000000000000000";
+      await expect(potProofVerifierTester.assertMultiProof(wrongProof)).to.be.revertedWith("Node is not in the proof");
+    });
+
+    it("should revert for invalid proof segment", async function () {
+      const wrongProof = JSON.parse(JSON.stringify(proof));
+      wrongProof.nodes[0].proofSegments[0] = "0xFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF";
+      await expect(potProofVerifierTester.assertMultiProof(wrongProof)).to.be.revertedWith("Node is not in the proof");
+    });
+
+    it("should revert for missing proof segment", async function () {
+      const wrongProof = JSON.parse(JSON.stringify(proof));
+      wrongProof.nodes[0].proofSegments.pop();
+      await expect(potProofVerifierTester.assertMultiProof(wrongProof)).to.be.revertedWith("Missing proof segments");
+    });
+
+    it("should revert for extra proof segment", async function () {
+      const wrongProof = JSON.parse(JSON.stringify(proof));
+      wrongProof.nodes[0].proofSegments.push("0x0000000000000000000000000000000000000000000000000000000000000000");
+      await expect(potProofVerifierTester.assertMultiProof(wrongProof)).to.be.revertedWith("Too many proof segments");
+    });
+
+    it("should revert for unordered indexes", async function () {
+      const wrongProof = JSON.parse(JSON.stringify(proof));
+      wrongProof.nodes[0].indexes = [1, 0, 2, 3, 5];
+      await expect(potProofVerifierTester.assertMultiProof(wrongProof)).to.be.revertedWith("Indexes are not ascending");
+    });
+
+    it("should revert for missing node", async function () {
+      const wrongProof = JSON.parse(JSON.stringify(proof));
+      wrongProof.nodes.pop();
+      await expect(potProofVerifierTester.assertMultiProof(wrongProof)).to.be.revertedWith("Node is not in the proof");
+    });
+
+    it("should revert for fork not set in the parent's bitvector", async function () {
+      const wrongProof = JSON.parse(JSON.stringify(proof));
+      wrongProof.keys[0] = "0x0000000000000000000000000000000000000000000000000000000000000000";
+      await expect(potProofVerifierTester.assertMultiProof(wrongProof)).to.be.revertedWith("Fork is not set in the parent's bitvector");
+    });
+
+    it("should revert for fork reference not in the proof", async function () {
+      const wrongProof = JSON.parse(JSON.stringify(proof));
+      // the root key with its bit at PO 6 flipped forks off the root at a fork no proven key goes through
+      wrongProof.keys[0] = "0x16236fe421fe9ddb8377a19b60ce71dd655704bcefc225c9287fb11127ba6569";
+      await expect(potProofVerifierTester.assertMultiProof(wrongProof)).to.be.revertedWith("Segment is not in the proof");
+    });
+
+    it("should revert for segment out of the node", async function () {
+      const wrongProof = JSON.parse(JSON.stringify(multiChunkProof));
+      const indexes = wrongProof.nodes[0].indexes;
+      indexes[indexes.length - 1] = 300;
+      await expect(potProofVerifierTester.assertMultiProof(wrongProof)).to.be.revertedWith("Segment is out of the node");
+    });
+
+    it("should revert for invalid node span", async function () {
+      const wrongProof = JSON.parse(JSON.stringify(multiChunkProof));
+      wrongProof.nodes[0].span += 32;
+      await expect(potProofVerifierTester.assertMultiProof(wrongProof)).to.be.revertedWith("Node is not in the proof");
+    });
+  });
 });
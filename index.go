@@ -2,28 +2,40 @@ package pot
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync/atomic"
 
 	"github.com/ethersphere/proximity-order-trie/pkg/elements"
 )
 
+var (
+	ErrClosed = errors.New("index closed")
+)
+
 // Index represents a mutable pot
 type Index struct {
-	mode  elements.Mode      // mode
-	read  chan elements.Node // hands out current root for reads
-	write chan elements.Node // hands out current root for writes and locks
-	root  chan elements.Node // channel for new roots
-	quit  chan struct{}      // closing this channel signals quit
+	mode    elements.Mode      // mode
+	read    chan elements.Node // hands out current root for reads
+	write   chan elements.Node // hands out current root for writes and locks
+	root    chan commit        // channel for new roots
+	watch   chan *watcher      // channel for subscribing to changes
+	unwatch chan *watcher      // channel for unsubscribing
+	quit    chan struct{}      // closing this channel signals quit
+
+	watched atomic.Bool // there are active watchers, so updates record their changes
 }
 
 // New constructs a new mutable pot
 func New(mode elements.Mode) (*Index, error) {
 	idx := &Index{
-		mode:  mode,
-		read:  make(chan elements.Node),
-		write: make(chan elements.Node),
-		root:  make(chan elements.Node),
-		quit:  make(chan struct{}),
+		mode:    mode,
+		read:    make(chan elements.Node),
+		write:   make(chan elements.Node),
+		root:    make(chan commit),
+		watch:   make(chan *watcher),
+		unwatch: make(chan *watcher),
+		quit:    make(chan struct{}),
 	}
 
 	root := idx.mode.New()
@@ -34,11 +46,13 @@ func New(mode elements.Mode) (*Index, error) {
 // NewReference constructs a new mutable pot from a reference
 func NewReference(ctx context.Context, mode elements.Mode, ref []byte) (*Index, error) {
 	idx := &Index{
-		mode:  mode,
-		read:  make(chan elements.Node),
-		write: make(chan elements.Node),
-		root:  make(chan elements.Node),
-		quit:  make(chan struct{}),
+		mode:    mode,
+		read:    make(chan elements.Node),
+		write:   make(chan elements.Node),
+		root:    make(chan commit),
+		watch:   make(chan *watcher),
+		unwatch: make(chan *watcher),
+		quit:    make(chan struct{}),
 	}
 
 	root, loaded, err := idx.mode.Load(ctx, ref)
@@ -57,6 +71,7 @@ func NewReference(ctx context.Context, mode elements.Mode, ref []byte) (*Index,
 func (idx *Index) muxProcess(root elements.Node) {
 	write := idx.write
 	quit := idx.quit
+	var watchers []*watcher
 	for {
 		select {
 		case <-quit:
@@ -65,10 +80,17 @@ func (idx *Index) muxProcess(root elements.Node) {
 		case write <- root: // write locks the pot for writes
 			write = nil // locks the pot until root updated
 			quit = nil  // disallow quit until write finish
-		case root = <-idx.root:
+		case c := <-idx.root:
+			root = c.root
+			watchers = notify(watchers, c)
 			write = idx.write
 			quit = idx.quit
+		case w := <-idx.watch:
+			watchers = append(watchers, w)
+		case w := <-idx.unwatch:
+			watchers = unwatch(watchers, w)
 		}
+		idx.watched.Store(len(watchers) > 0)
 	}
 }
 
@@ -92,46 +114,80 @@ func (idx *Index) Update(ctx context.Context, k []byte, e *elements.Entry) error
 		return ctx.Err()
 	case root = <-idx.write:
 	}
-
-	update, err := idx.mode.Update(ctx, root, k, e)
-	if err != nil {
-		return err
+	done := elements.Traverse(idx.mode)
+
+	c := commit{root: root}
+	var ch Change
+	var changed bool
+	var err error
+	// the entry before the update is only looked up for watchers
+	if idx.watched.Load() {
+		ch, changed, err = change(ctx, root, k, e, idx.mode)
 	}
-	if update != nil {
-		root = update
+	if err == nil {
+		var update elements.Node
+		update, err = idx.mode.Update(ctx, root, k, e)
+		if update != nil && err == nil {
+			c.root = update
+			if changed {
+				c.changes = []Change{ch}
+			}
+		}
 	}
 
-	// update with new pot root and release the write lock
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case idx.root <- root:
-	}
-	return nil
+	// update with new pot root (or the old one if failed) and release the write lock
+	// muxProcess always receives the root while the pot is locked
+	done()
+	idx.root <- c
+	return err
 }
 
 // Find retrieves the entry at the given key from the mutable pot or gives elements.ErrNotFound
 func (idx *Index) Find(ctx context.Context, k []byte) (elements.Entry, error) {
-	select {
-	case <-ctx.Done():
-		return nil, ctx.Err()
-	case root := <-idx.read:
-		return elements.Find(ctx, root, k, idx.mode)
+	s, err := idx.snapshot(ctx)
+	if err != nil {
+		return nil, err
 	}
+	return s.Find(ctx, k)
 }
 
 // Iterate wraps the underlying pot's iterator
 func (idx *Index) Iterate(ctx context.Context, p, k []byte, f func(elements.Entry) (stop bool, err error)) error {
-	return elements.Iterate(ctx, elements.NewAt(0, <-idx.read), p, k, idx.mode, f)
+	s, err := idx.snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	return s.Iterate(ctx, p, k, f)
+}
+
+// Range calls f on the entries with keys in [start, end) in ascending order of keys
+// until f returns true or an error. A nil start or end leaves the range unbounded on that side.
+func (idx *Index) Range(ctx context.Context, start, end []byte, f func(elements.Entry) (stop bool, err error)) error {
+	s, err := idx.snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	return s.Range(ctx, start, end, f)
+}
+
+// Nearest returns the k entries closest to the given key along with their proximity order to it
+func (idx *Index) Nearest(ctx context.Context, key []byte, k int) ([]elements.Neighbour, error) {
+	return idx.NearestWithin(ctx, key, k, 0)
+}
+
+// NearestWithin is Nearest with a maximum distance: entries with proximity order less than po
+// to the key are left out without loading the forks holding them
+func (idx *Index) NearestWithin(ctx context.Context, key []byte, k, po int) ([]elements.Neighbour, error) {
+	s, err := idx.snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.NearestWithin(ctx, key, k, po)
 }
 
 // Size returns the size (number of entries) of the pot
 func (idx *Index) Size() int {
-	root := <-idx.read
-	if root == nil {
-		return 0
-	}
-	return root.Size()
+	return idx.Snapshot().Size()
 }
 
 // Save calls the mode specific save method for the root node
@@ -140,6 +196,7 @@ func (idx *Index) Save(ctx context.Context) ([]byte, error) {
 	if root.Empty() {
 		return nil, fmt.Errorf("root node is nil")
 	}
+	defer elements.Traverse(idx.mode)()
 	return idx.mode.Save(ctx)
 }
 
@@ -8,6 +8,10 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -350,6 +354,123 @@ func TestPersistence(t *testing.T) {
 	}
 }
 
+func TestDurability(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "log")
+	ls, err := persister.NewLogLoadSaver(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ls.Close()
+	newf := func(key []byte) elements.Entry { return &mockEntry{key: key} }
+	idx, err := pot.New(elements.NewSwarmPot(basePotMode, ls, newf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+	c, err := idx.Watch(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// root returns the reference of the root notified with the next change
+	root := func(t *testing.T) []byte {
+		t.Helper()
+		select {
+		case ch := <-c:
+			return ch.Root
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for change")
+		}
+		return nil
+	}
+	// check simulates a crash right after the reference is handed out by opening the log again
+	// without closing it, which loses the records not synced, and checks the pot of the reference
+	check := func(t *testing.T, ref []byte, size int) {
+		t.Helper()
+		reopened, err := persister.NewLogLoadSaver(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer reopened.Close()
+		idx, err := pot.NewReference(ctx, elements.NewSwarmPotReference(basePotMode, reopened, ref, newf), ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		n := 0
+		err = idx.Range(ctx, nil, nil, func(elements.Entry) (bool, error) {
+			n++
+			return false, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != size {
+			t.Fatalf("incorrect number of entries. want %d, got %d", size, n)
+		}
+	}
+
+	var snapshotRef, updateRef, batchRef []byte
+	t.Run("update", func(t *testing.T) {
+		for i := 0; i < 20; i++ {
+			if err := idx.Add(ctx, newDetMockEntry(t, i)); err != nil {
+				t.Fatal(err)
+			}
+			updateRef = root(t)
+		}
+		check(t, updateRef, 20)
+	})
+	t.Run("snapshot", func(t *testing.T) {
+		if err := idx.Delete(ctx, newDetMockEntry(t, 0).key); err != nil {
+			t.Fatal(err)
+		}
+		root(t)
+		snapshotRef, err = idx.Snapshot().Save(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, snapshotRef, 19)
+	})
+	t.Run("batch", func(t *testing.T) {
+		err := idx.Batch(ctx, func(b *pot.Batch) error {
+			for i := 0; i < 30; i++ {
+				if err := b.Put(newDetMockEntry(t, i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the changes of the batch, adding back the deleted entry and 10 new ones, all have the root of the batch
+		batchRef = root(t)
+		for i := 0; i < 10; i++ {
+			if !bytes.Equal(batchRef, root(t)) {
+				t.Fatal("changes of the batch have different roots")
+			}
+		}
+		check(t, batchRef, 30)
+	})
+	t.Run("merge", func(t *testing.T) {
+		// the entries added by the batch are removed from the pot of the first update
+		mode := elements.NewSwarmPotReference(basePotMode, ls, updateRef, newf)
+		var roots []elements.Node
+		for _, ref := range [][]byte{updateRef, batchRef, snapshotRef} {
+			r, _, err := mode.Load(ctx, ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			roots = append(roots, r)
+		}
+		merged, err := elements.Merge(ctx, roots[0], roots[1], roots[2], mode, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, merged.(persister.TreeNode).Reference(), 29)
+	})
+}
+
 func TestConcurrency(t *testing.T) {
 	test := func(t *testing.T, idx *pot.Index) {
 		workers := 4
@@ -445,6 +566,1832 @@ func TestConcurrency(t *testing.T) {
 	})
 }
 
+// closestMode promotes the entry closest to a reference key after deletion
+type closestMode struct {
+	*elements.SingleOrder
+	ref []byte
+}
+
+func (m closestMode) Up() func(elements.CNode) bool {
+	return elements.Closest(m.ref)
+}
+
+func (m closestMode) Update(ctx context.Context, root elements.Node, k []byte, e *elements.Entry) (elements.Node, error) {
+	return elements.Update(ctx, m.New(), elements.NewAt(0, root), k, e, m)
+}
+
+// firstMode promotes the entry of the first fork after deletion and counts the forks the policy is asked about
+type firstMode struct {
+	*elements.SingleOrder
+	asked *atomic.Int64
+}
+
+func (m firstMode) Up() func(elements.CNode) bool {
+	return func(elements.CNode) bool {
+		m.asked.Add(1)
+		return true
+	}
+}
+
+// rootMode records the root of the latest update
+type rootMode struct {
+	closestMode
+	root *elements.Node
+}
+
+func (m rootMode) Update(ctx context.Context, root elements.Node, k []byte, e *elements.Entry) (elements.Node, error) {
+	update, err := m.closestMode.Update(ctx, root, k, e)
+	if update != nil && err == nil {
+		*m.root = update
+	}
+	return update, err
+}
+
+func TestClosestShortKey(t *testing.T) {
+	n := &elements.MemNode{}
+	n.Pin(&mockEntry{key: []byte{0xff}})
+	up := elements.Closest([]byte{0xff, 0xff})
+	if !up(elements.NewAt(7, n)) {
+		t.Fatal("expected the fork matching the key on its branching bit")
+	}
+	// proximity orders beyond the key of the node or the reference key do not match
+	if up(elements.NewAt(8, n)) || elements.Closest([]byte{0xff})(elements.NewAt(12, n)) {
+		t.Fatal("expected no match beyond the keys")
+	}
+}
+
+func TestDeletePromotion(t *testing.T) {
+	count := 200
+	ref := newDetMockEntry(t, -1).key
+	test := func(t *testing.T, idx *pot.Index, root func() elements.Entry) {
+		ctx := context.Background()
+		ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+		defer cancel()
+		for i := 0; i < count; i++ {
+			idx.Add(ctx, newDetMockEntry(t, i))
+		}
+		deleted := make(map[int]bool)
+		for n, i := range rand.Perm(count) {
+			atRoot := false
+			if n%2 == 0 {
+				// modify the entry to bring it to the root so its slot is refilled by promotion
+				e := newDetMockEntry(t, i)
+				idx.Add(ctx, &mockEntry{e.key, e.val + count})
+				atRoot = root != nil && bytes.Equal(root().Key(), e.key)
+			}
+			if err := idx.Delete(ctx, newDetMockEntry(t, i).key); err != nil {
+				t.Fatal(err)
+			}
+			deleted[i] = true
+			if size := idx.Size(); size != count-n-1 {
+				t.Fatalf("incorrect number of items. want %d, got %d", count-n-1, size)
+			}
+			if atRoot && n < count-1 {
+				var closest elements.Entry
+				if err := idx.Iterate(ctx, nil, ref, func(e elements.Entry) (bool, error) {
+					closest = e
+					return true, nil
+				}); err != nil {
+					t.Fatal(err)
+				}
+				if got := root(); !eq(closest.(*mockEntry), got.(*mockEntry)) {
+					t.Fatalf("incorrect entry promoted. want %v, got %v", closest, got)
+				}
+			}
+			if n%20 == 0 {
+				for j := 0; j < count; j++ {
+					if deleted[j] {
+						checkNotFound(t, ctx, idx, newDetMockEntry(t, j))
+					} else {
+						checkFound(t, ctx, idx, newDetMockEntry(t, j))
+					}
+				}
+			}
+		}
+	}
+	t.Run("in memory", func(t *testing.T) {
+		var last elements.Node
+		idx, err := pot.New(rootMode{closestMode{basePotMode, ref}, &last})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		test(t, idx, func() elements.Entry { return last.Entry() })
+	})
+	t.Run("persisted", func(t *testing.T) {
+		ls := persister.NewInmemLoadSaver()
+		newf := func(key []byte) elements.Entry { return &mockEntry{key: key} }
+		mode := elements.NewSwarmPot(closestMode{basePotMode, ref}, ls, newf)
+		idx, err := pot.New(mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		root := func() elements.Entry {
+			ref, err := idx.Save(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			n, _, err := elements.NewSwarmPotReference(basePotMode, ls, ref, newf).Load(context.Background(), ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return n.Entry()
+		}
+		test(t, idx, root)
+	})
+	t.Run("reloaded", func(t *testing.T) {
+		ctx := context.Background()
+		ls := persister.NewInmemLoadSaver()
+		newf := func(key []byte) elements.Entry { return &mockEntry{key: key} }
+		idx, err := pot.New(elements.NewSwarmPot(closestMode{basePotMode, ref}, ls, newf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < count; i++ {
+			idx.Add(ctx, newDetMockEntry(t, i))
+		}
+		potRef, err := idx.Save(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		idx.Close()
+		mode := elements.NewSwarmPotReference(closestMode{basePotMode, ref}, ls, potRef, newf)
+		idx, err = pot.NewReference(ctx, mode, potRef)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		for i := 0; i < count; i += 2 {
+			if err := idx.Delete(ctx, newDetMockEntry(t, i).key); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for i := 0; i < count; i++ {
+			if i%2 == 0 {
+				checkNotFound(t, ctx, idx, newDetMockEntry(t, i))
+			} else {
+				checkFound(t, ctx, idx, newDetMockEntry(t, i))
+			}
+		}
+	})
+	t.Run("loads", func(t *testing.T) {
+		// the policy is asked about the forks one by one, each fork being loaded only when it is asked about
+		ctx := context.Background()
+		ls := &countingLoadSaver{LoadSaver: persister.NewInmemLoadSaver()}
+		newf := func(key []byte) elements.Entry { return &mockEntry{key: key} }
+		mode := firstMode{basePotMode, &atomic.Int64{}}
+		idx, err := pot.New(elements.NewSwarmPot(mode, ls, newf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < count; i++ {
+			idx.Add(ctx, newDetMockEntry(t, i))
+		}
+		potRef, err := idx.Save(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		idx.Close()
+		idx, err = pot.NewReference(ctx, elements.NewSwarmPotReference(mode, ls, potRef, newf), potRef)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		for i := 0; i < count; i += 2 {
+			// the path to the entry is loaded beforehand so that the deletion only loads the forks it promotes from
+			checkFound(t, ctx, idx, newDetMockEntry(t, i))
+			loads, asked := ls.loads.Load(), mode.asked.Load()
+			if err := idx.Delete(ctx, newDetMockEntry(t, i).key); err != nil {
+				t.Fatal(err)
+			}
+			if loaded, asked := ls.loads.Load()-loads, mode.asked.Load()-asked; loaded > asked {
+				t.Fatalf("deleting entry %d loaded %d nodes, more than the %d forks asked about", i, loaded, asked)
+			}
+		}
+	})
+}
+
+func TestBalanced(t *testing.T) {
+	count := 200
+	newf := func(key []byte) elements.Entry { return &mockEntry{key: key} }
+	// build populates a pot in random order, also adding and deleting surplus entries
+	build := func(t *testing.T, idx *pot.Index) {
+		ctx := context.Background()
+		ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+		defer cancel()
+		for _, i := range rand.Perm(count + count/2) {
+			idx.Add(ctx, newDetMockEntry(t, i))
+		}
+		for _, i := range rand.Perm(count / 2) {
+			if err := idx.Delete(ctx, newDetMockEntry(t, count+i).key); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if size := idx.Size(); size != count {
+			t.Fatalf("incorrect number of items. want %d, got %d", count, size)
+		}
+		for i := 0; i < count+count/2; i++ {
+			if i < count {
+				checkFound(t, ctx, idx, newDetMockEntry(t, i))
+			} else {
+				checkNotFound(t, ctx, idx, newDetMockEntry(t, i))
+			}
+		}
+	}
+	t.Run("in memory", func(t *testing.T) {
+		idx, err := pot.New(elements.NewBalanced(256))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		build(t, idx)
+	})
+	t.Run("independent of order", func(t *testing.T) {
+		ls := persister.NewInmemLoadSaver()
+		var refs [][]byte
+		for i := 0; i < 3; i++ {
+			idx, err := pot.New(elements.NewSwarmPot(elements.NewBalanced(256), ls, newf))
+			if err != nil {
+				t.Fatal(err)
+			}
+			build(t, idx)
+			ref, err := idx.Save(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			idx.Close()
+			refs = append(refs, ref)
+		}
+		for _, ref := range refs[1:] {
+			if !bytes.Equal(refs[0], ref) {
+				t.Fatalf("pots with the same entries differ. want %x, got %x", refs[0], ref)
+			}
+		}
+	})
+	t.Run("loads", func(t *testing.T) {
+		// a deletion loads the last fork of the removed node, which is promoted,
+		// and the last fork of the promoted node, to tell that it is kept down
+		ctx := context.Background()
+		ls := &countingLoadSaver{LoadSaver: persister.NewInmemLoadSaver()}
+		idx, err := pot.New(elements.NewSwarmPot(elements.NewBalanced(256), ls, newf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		build(t, idx)
+		ref, err := idx.Save(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		idx.Close()
+		idx, err = pot.NewReference(ctx, elements.NewSwarmPotReference(elements.NewBalanced(256), ls, ref, newf), ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		for i := 0; i < count; i += 2 {
+			// the path to the entry is loaded beforehand so that the deletion only loads the forks it promotes from
+			checkFound(t, ctx, idx, newDetMockEntry(t, i))
+			loads := ls.loads.Load()
+			if err := idx.Delete(ctx, newDetMockEntry(t, i).key); err != nil {
+				t.Fatal(err)
+			}
+			if loaded := ls.loads.Load() - loads; loaded > 2 {
+				t.Fatalf("deleting entry %d loaded %d nodes, want at most 2", i, loaded)
+			}
+		}
+	})
+}
+
+// countingLoadSaver counts the nodes loaded
+type countingLoadSaver struct {
+	persister.LoadSaver
+	loads atomic.Int64
+	saves atomic.Int64
+}
+
+func (ls *countingLoadSaver) Load(ctx context.Context, ref []byte) ([]byte, error) {
+	ls.loads.Add(1)
+	return ls.LoadSaver.Load(ctx, ref)
+}
+
+func (ls *countingLoadSaver) Save(ctx context.Context, data []byte) ([]byte, error) {
+	ls.saves.Add(1)
+	return ls.LoadSaver.Save(ctx, data)
+}
+
+func TestNearest(t *testing.T) {
+	count := 200
+	k := 16
+	key := newDetMockEntry(t, -1).key
+	// closest sorts the entries by distance from key
+	closest := func(t *testing.T, po int) []*mockEntry {
+		var es []*mockEntry
+		for i := 0; i < count; i++ {
+			if e := newDetMockEntry(t, i); elements.PO(e.key, key, 0) >= po {
+				es = append(es, e)
+			}
+		}
+		sort.Slice(es, func(i, j int) bool {
+			for b := range key {
+				if d, e := es[i].key[b]^key[b], es[j].key[b]^key[b]; d != e {
+					return d < e
+				}
+			}
+			return false
+		})
+		return es
+	}
+	check := func(t *testing.T, want []*mockEntry, got []elements.Neighbour) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Fatalf("incorrect number of items. want %d, got %d", len(want), len(got))
+		}
+		for i, n := range got {
+			if !eq(want[i], n.Entry.(*mockEntry)) {
+				t.Fatalf("incorrect item at %d. want %v, got %v", i, want[i], n.Entry)
+			}
+			if po := elements.PO(want[i].key, key, 0); n.PO != po {
+				t.Fatalf("incorrect proximity order at %d. want %d, got %d", i, po, n.PO)
+			}
+		}
+	}
+	test := func(t *testing.T, idx *pot.Index) {
+		ctx := context.Background()
+		t.Run("k nearest", func(t *testing.T) {
+			got, err := idx.Nearest(ctx, key, k)
+			if err != nil {
+				t.Fatal(err)
+			}
+			check(t, closest(t, 0)[:k], got)
+		})
+		t.Run("all", func(t *testing.T) {
+			got, err := idx.Nearest(ctx, key, count+1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			check(t, closest(t, 0), got)
+		})
+		t.Run("within", func(t *testing.T) {
+			got, err := idx.NearestWithin(ctx, key, count, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			check(t, closest(t, 3), got)
+		})
+		t.Run("cancelled", func(t *testing.T) {
+			ctx, cancel := context.WithCancel(ctx)
+			cancel()
+			if _, err := idx.Nearest(ctx, key, k); !errors.Is(err, context.Canceled) {
+				t.Fatalf("incorrect error. want %v, got %v", context.Canceled, err)
+			}
+		})
+	}
+	t.Run("in memory", func(t *testing.T) {
+		idx, err := pot.New(basePotMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		for i := 0; i < count; i++ {
+			idx.Add(context.Background(), newDetMockEntry(t, i))
+		}
+		test(t, idx)
+	})
+	t.Run("persisted", func(t *testing.T) {
+		ctx := context.Background()
+		ls := &countingLoadSaver{LoadSaver: persister.NewInmemLoadSaver()}
+		newf := func(key []byte) elements.Entry { return &mockEntry{key: key} }
+		idx, err := pot.New(elements.NewSwarmPot(basePotMode, ls, newf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < count; i++ {
+			idx.Add(ctx, newDetMockEntry(t, i))
+		}
+		ref, err := idx.Save(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		idx.Close()
+		load := func(t *testing.T) *pot.Index {
+			idx, err := pot.NewReference(ctx, elements.NewSwarmPotReference(basePotMode, ls, ref, newf), ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return idx
+		}
+		idx = load(t)
+		defer idx.Close()
+		test(t, idx)
+
+		t.Run("prune", func(t *testing.T) {
+			idx := load(t)
+			defer idx.Close()
+			ls.loads.Store(0)
+			got, err := idx.NearestWithin(ctx, key, count, 4)
+			if err != nil {
+				t.Fatal(err)
+			}
+			check(t, closest(t, 4), got)
+			if loads := ls.loads.Load(); loads > int64(count/4) {
+				t.Fatalf("too many nodes loaded. want at most %d, got %d", count/4, loads)
+			}
+		})
+	})
+}
+
+func TestRange(t *testing.T) {
+	count := 200
+	var all []*mockEntry
+	for i := 0; i < count; i++ {
+		all = append(all, newDetMockEntry(t, i))
+	}
+	sort.Slice(all, func(i, j int) bool { return bytes.Compare(all[i].key, all[j].key) < 0 })
+	// within filters the sorted entries with keys in [start, end)
+	within := func(start, end []byte) []*mockEntry {
+		var es []*mockEntry
+		for _, e := range all {
+			if bytes.Compare(e.key, start) >= 0 && (end == nil || bytes.Compare(e.key, end) < 0) {
+				es = append(es, e)
+			}
+		}
+		return es
+	}
+	collect := func(t *testing.T, idx *pot.Index, start, end []byte, max int) []*mockEntry {
+		t.Helper()
+		var got []*mockEntry
+		err := idx.Range(context.Background(), start, end, func(e elements.Entry) (bool, error) {
+			got = append(got, e.(*mockEntry))
+			return len(got) == max, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	check := func(t *testing.T, want, got []*mockEntry) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Fatalf("incorrect number of items. want %d, got %d", len(want), len(got))
+		}
+		for i := range got {
+			if !eq(want[i], got[i]) {
+				t.Fatalf("incorrect item at %d. want %v, got %v", i, want[i], got[i])
+			}
+		}
+	}
+	start, end := all[count/4].key, all[count/2].key
+	test := func(t *testing.T, idx *pot.Index) {
+		t.Run("all", func(t *testing.T) {
+			check(t, all, collect(t, idx, nil, nil, 0))
+		})
+		t.Run("bounded", func(t *testing.T) {
+			check(t, within(start, end), collect(t, idx, start, end, 0))
+		})
+		t.Run("from", func(t *testing.T) {
+			check(t, within(start, nil), collect(t, idx, start, nil, 0))
+		})
+		t.Run("until", func(t *testing.T) {
+			check(t, within(nil, end), collect(t, idx, nil, end, 0))
+		})
+		t.Run("empty", func(t *testing.T) {
+			check(t, nil, collect(t, idx, end, start, 0))
+			check(t, nil, collect(t, idx, start, start, 0))
+		})
+		t.Run("stop", func(t *testing.T) {
+			check(t, within(start, end)[:10], collect(t, idx, start, end, 10))
+		})
+		t.Run("cancelled", func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			err := idx.Range(ctx, nil, nil, func(elements.Entry) (bool, error) { return false, nil })
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("incorrect error. want %v, got %v", context.Canceled, err)
+			}
+		})
+	}
+	t.Run("in memory", func(t *testing.T) {
+		idx, err := pot.New(basePotMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		for i := 0; i < count; i++ {
+			idx.Add(context.Background(), newDetMockEntry(t, i))
+		}
+		test(t, idx)
+	})
+	t.Run("persisted", func(t *testing.T) {
+		ctx := context.Background()
+		ls := &countingLoadSaver{LoadSaver: persister.NewInmemLoadSaver()}
+		newf := func(key []byte) elements.Entry { return &mockEntry{key: key} }
+		idx, err := pot.New(elements.NewSwarmPot(basePotMode, ls, newf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < count; i++ {
+			idx.Add(ctx, newDetMockEntry(t, i))
+		}
+		ref, err := idx.Save(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		idx.Close()
+		load := func(t *testing.T) *pot.Index {
+			idx, err := pot.NewReference(ctx, elements.NewSwarmPotReference(basePotMode, ls, ref, newf), ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return idx
+		}
+		idx = load(t)
+		defer idx.Close()
+		test(t, idx)
+
+		t.Run("prune", func(t *testing.T) {
+			idx := load(t)
+			defer idx.Close()
+			ls.loads.Store(0)
+			start, end := all[count/2].key, all[count/2+count/16].key
+			check(t, within(start, end), collect(t, idx, start, end, 0))
+			if loads := ls.loads.Load(); loads > int64(count/4) {
+				t.Fatalf("too many nodes loaded. want at most %d, got %d", count/4, loads)
+			}
+		})
+	})
+}
+
+func TestSnapshot(t *testing.T) {
+	count := 100
+	ctx := context.Background()
+	// mutate deletes the first half of the entries, changes the rest and adds new ones
+	mutate := func(t *testing.T, idx *pot.Index) {
+		t.Helper()
+		for i := 0; i < count/2; i++ {
+			if err := idx.Delete(ctx, newDetMockEntry(t, i).key); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for i := count / 2; i < 2*count; i++ {
+			e := newDetMockEntry(t, i)
+			e.val = -i
+			if err := idx.Add(ctx, e); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	check := func(t *testing.T, s *pot.Snapshot) {
+		t.Helper()
+		if s.Size() != count {
+			t.Fatalf("incorrect size. want %d, got %d", count, s.Size())
+		}
+		for i := 0; i < count; i++ {
+			want := newDetMockEntry(t, i)
+			e, err := s.Find(ctx, want.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !eq(want, e.(*mockEntry)) {
+				t.Fatalf("mismatch. want %v, got %v", want, e)
+			}
+		}
+		if _, err := s.Find(ctx, newDetMockEntry(t, count).key); !errors.Is(err, elements.ErrNotFound) {
+			t.Fatalf("incorrect error. want %v, got %v", elements.ErrNotFound, err)
+		}
+		n := 0
+		err := s.Iterate(ctx, nil, newDetMockEntry(t, -1).key, func(elements.Entry) (bool, error) {
+			n++
+			return false, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != count {
+			t.Fatalf("incorrect number of items iterated. want %d, got %d", count, n)
+		}
+	}
+	t.Run("in memory", func(t *testing.T) {
+		idx, err := pot.New(basePotMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		for i := 0; i < count; i++ {
+			idx.Add(ctx, newDetMockEntry(t, i))
+		}
+		s := idx.Snapshot()
+		mutate(t, idx)
+		check(t, s)
+		if want := 2*count - count/2; idx.Size() != want {
+			t.Fatalf("incorrect index size. want %d, got %d", want, idx.Size())
+		}
+	})
+	t.Run("concurrent writes", func(t *testing.T) {
+		idx, err := pot.New(basePotMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		for i := 0; i < count; i++ {
+			idx.Add(ctx, newDetMockEntry(t, i))
+		}
+		s := idx.Snapshot()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := count; i < 4*count; i++ {
+				idx.Add(ctx, newDetMockEntry(t, i))
+			}
+		}()
+		for i := 0; i < 5; i++ {
+			check(t, s)
+		}
+		<-done
+	})
+	t.Run("persisted", func(t *testing.T) {
+		ls := persister.NewInmemLoadSaver()
+		newf := func(key []byte) elements.Entry { return &mockEntry{key: key} }
+		idx, err := pot.New(elements.NewSwarmPot(basePotMode, ls, newf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		for i := 0; i < count; i++ {
+			idx.Add(ctx, newDetMockEntry(t, i))
+		}
+		s := idx.Snapshot()
+		mutate(t, idx)
+		ref, err := s.Save(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.Save(ctx); err != nil {
+			t.Fatal(err)
+		}
+		check(t, s)
+		loaded, err := pot.NewReference(ctx, elements.NewSwarmPotReference(basePotMode, ls, ref, newf), ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer loaded.Close()
+		check(t, loaded.Snapshot())
+	})
+}
+
+func TestBatch(t *testing.T) {
+	count := 100
+	ctx := context.Background()
+	errTest := errors.New("test")
+	// apply adds the first count entries, deletes every third and changes every fifth
+	apply := func(t *testing.T, b *pot.Batch) {
+		t.Helper()
+		for i := 0; i < count; i++ {
+			if err := b.Put(newDetMockEntry(t, i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for i := 0; i < count; i += 3 {
+			if err := b.Delete(newDetMockEntry(t, i).key); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for i := 1; i < count; i += 5 {
+			var e elements.Entry = &mockEntry{newDetMockEntry(t, i).key, count + i}
+			if err := b.Update(e.Key(), &e); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	check := func(t *testing.T, idx *pot.Index) {
+		t.Helper()
+		size := 0
+		for i := 0; i < count; i++ {
+			want := newDetMockEntry(t, i)
+			switch {
+			case i%5 == 1:
+				want.val = count + i
+			case i%3 == 0:
+				checkNotFound(t, ctx, idx, want)
+				continue
+			}
+			checkFound(t, ctx, idx, want)
+			size++
+		}
+		if idx.Size() != size {
+			t.Fatalf("incorrect size. want %d, got %d", size, idx.Size())
+		}
+	}
+	t.Run("commit", func(t *testing.T) {
+		idx, err := pot.New(basePotMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		err = idx.Batch(ctx, func(b *pot.Batch) error {
+			apply(t, b)
+			e, err := b.Find(newDetMockEntry(t, 1).key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := e.(*mockEntry).val; got != count+1 {
+				t.Fatalf("batch does not see its own updates. want %d, got %d", count+1, got)
+			}
+			if idx.Size() != 0 {
+				t.Fatalf("uncommitted updates visible. want size 0, got %d", idx.Size())
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, idx)
+	})
+	t.Run("roll back", func(t *testing.T) {
+		idx, err := pot.New(basePotMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		if err := idx.Batch(ctx, func(b *pot.Batch) error { apply(t, b); return nil }); err != nil {
+			t.Fatal(err)
+		}
+		t.Run("error", func(t *testing.T) {
+			var batch *pot.Batch
+			err := idx.Batch(ctx, func(b *pot.Batch) error {
+				batch = b
+				for i := 0; i < 2*count; i++ {
+					if err := b.Delete(newDetMockEntry(t, i).key); err != nil {
+						t.Fatal(err)
+					}
+				}
+				return errTest
+			})
+			if !errors.Is(err, errTest) {
+				t.Fatalf("incorrect error. want %v, got %v", errTest, err)
+			}
+			check(t, idx)
+			if err := batch.Delete(newDetMockEntry(t, 1).key); !errors.Is(err, pot.ErrBatchClosed) {
+				t.Fatalf("incorrect error. want %v, got %v", pot.ErrBatchClosed, err)
+			}
+		})
+		t.Run("cancelled", func(t *testing.T) {
+			ctx, cancel := context.WithCancel(ctx)
+			err := idx.Batch(ctx, func(b *pot.Batch) error {
+				if err := b.Delete(newDetMockEntry(t, 1).key); err != nil {
+					t.Fatal(err)
+				}
+				cancel()
+				return nil
+			})
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("incorrect error. want %v, got %v", context.Canceled, err)
+			}
+			check(t, idx)
+		})
+		// the index remains writable after roll backs
+		if err := idx.Add(ctx, newDetMockEntry(t, count)); err != nil {
+			t.Fatal(err)
+		}
+		checkFound(t, ctx, idx, newDetMockEntry(t, count))
+	})
+	t.Run("persisted", func(t *testing.T) {
+		newf := func(key []byte) elements.Entry { return &mockEntry{key: key} }
+		save := func(t *testing.T, batch bool) (ls *countingLoadSaver, ref []byte) {
+			ls = &countingLoadSaver{LoadSaver: persister.NewInmemLoadSaver()}
+			idx, err := pot.New(elements.NewSwarmPot(basePotMode, ls, newf))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer idx.Close()
+			if batch {
+				err = idx.Batch(ctx, func(b *pot.Batch) error { apply(t, b); return nil })
+			} else {
+				for i := 0; err == nil && i < count; i++ {
+					err = idx.Add(ctx, newDetMockEntry(t, i))
+				}
+				for i := 0; err == nil && i < count; i += 3 {
+					err = idx.Delete(ctx, newDetMockEntry(t, i).key)
+				}
+				for i := 1; err == nil && i < count; i += 5 {
+					err = idx.Add(ctx, &mockEntry{newDetMockEntry(t, i).key, count + i})
+				}
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			check(t, idx)
+			ref, err = idx.Save(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return ls, ref
+		}
+		batchLs, batchRef := save(t, true)
+		ls, ref := save(t, false)
+		if !bytes.Equal(ref, batchRef) {
+			t.Fatalf("batch persisted to a different reference. want %x, got %x", ref, batchRef)
+		}
+		if batchLs.saves.Load() >= ls.saves.Load() {
+			t.Fatalf("batch saved no fewer nodes than individual updates: %d >= %d", batchLs.saves.Load(), ls.saves.Load())
+		}
+		idx, err := pot.NewReference(ctx, elements.NewSwarmPotReference(basePotMode, batchLs, batchRef, newf), batchRef)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		check(t, idx)
+
+		t.Run("wrapped mode", func(t *testing.T) {
+			ls := persister.NewInmemLoadSaver()
+			idx, err := pot.New(wrappedPot{elements.NewSwarmPot(basePotMode, ls, newf)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer idx.Close()
+			if err := idx.Batch(ctx, func(b *pot.Batch) error { apply(t, b); return nil }); err != nil {
+				t.Fatal(err)
+			}
+			// the root committed by the batch is the one saved
+			wrappedRef, err := idx.Save(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(ref, wrappedRef) {
+				t.Fatalf("batch persisted to a different reference. want %x, got %x", ref, wrappedRef)
+			}
+		})
+	})
+}
+
+// wrappedPot is a custom persisted mode built on SwarmPot
+type wrappedPot struct {
+	*elements.SwarmPot
+}
+
+func TestWatch(t *testing.T) {
+	ctx := context.Background()
+	next := func(t *testing.T, c <-chan pot.Change) pot.Change {
+		t.Helper()
+		select {
+		case ch, ok := <-c:
+			if !ok {
+				t.Fatal("channel closed")
+			}
+			return ch
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for change")
+		}
+		return pot.Change{}
+	}
+	// expect checks the change against the entries before and after it
+	expect := func(t *testing.T, c <-chan pot.Change, old, updated *mockEntry) {
+		t.Helper()
+		ch := next(t, c)
+		want := old
+		if want == nil {
+			want = updated
+		}
+		if !bytes.Equal(ch.Key, want.key) {
+			t.Fatalf("incorrect key. want %x, got %x", want.key, ch.Key)
+		}
+		for _, e := range []struct {
+			want *mockEntry
+			got  elements.Entry
+		}{{old, ch.Old}, {updated, ch.New}} {
+			if e.want == nil {
+				if e.got != nil {
+					t.Fatalf("incorrect entry. want nil, got %v", e.got)
+				}
+			} else if e.got == nil || !eq(e.want, e.got.(*mockEntry)) {
+				t.Fatalf("incorrect entry. want %v, got %v", e.want, e.got)
+			}
+		}
+	}
+	expectNone := func(t *testing.T, c <-chan pot.Change) {
+		t.Helper()
+		select {
+		case ch := <-c:
+			t.Fatalf("unexpected change %v", ch)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	// two entries with a common first byte and one with a different one
+	var es []*mockEntry
+	for i := 0; len(es) < 2; i++ {
+		if e := newDetMockEntry(t, i); len(es) == 0 || e.key[0] == es[0].key[0] {
+			es = append(es, e)
+		}
+	}
+	for i := 0; len(es) < 3; i++ {
+		if e := newDetMockEntry(t, i); e.key[0] != es[0].key[0] {
+			es = append(es, e)
+		}
+	}
+	prefix := es[0].key[:1]
+	changed := &mockEntry{es[0].key, 1000}
+
+	t.Run("changes", func(t *testing.T) {
+		idx, err := pot.New(basePotMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		all, err := idx.Watch(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filtered, err := idx.Watch(ctx, prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range es {
+			if err := idx.Add(ctx, e); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, e := range es {
+			expect(t, all, nil, e)
+		}
+		expect(t, filtered, nil, es[0])
+		expect(t, filtered, nil, es[1])
+
+		// no change events for updates that do not change anything
+		if err := idx.Add(ctx, es[0]); err != nil {
+			t.Fatal(err)
+		}
+		if err := idx.Delete(ctx, newDetMockEntry(t, -1).key); err != nil {
+			t.Fatal(err)
+		}
+		expectNone(t, all)
+
+		if err := idx.Add(ctx, changed); err != nil {
+			t.Fatal(err)
+		}
+		expect(t, all, es[0], changed)
+		expect(t, filtered, es[0], changed)
+		err = idx.Batch(ctx, func(b *pot.Batch) error {
+			if err := b.Delete(es[1].key); err != nil {
+				return err
+			}
+			return b.Delete(es[2].key)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect(t, all, es[1], nil)
+		expect(t, all, es[2], nil)
+		expect(t, filtered, es[1], nil)
+		expectNone(t, filtered)
+	})
+	t.Run("persisted", func(t *testing.T) {
+		ls := persister.NewInmemLoadSaver()
+		newf := func(key []byte) elements.Entry { return &mockEntry{key: key} }
+		idx, err := pot.New(elements.NewSwarmPot(basePotMode, ls, newf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		c, err := idx.Watch(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range es {
+			if err := idx.Add(ctx, e); err != nil {
+				t.Fatal(err)
+			}
+			ch := next(t, c)
+			ref, err := idx.Save(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(ch.Root, ref) {
+				t.Fatalf("incorrect root reference. want %x, got %x", ref, ch.Root)
+			}
+		}
+	})
+	t.Run("unsubscribe", func(t *testing.T) {
+		idx, err := pot.New(basePotMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cctx, cancel := context.WithCancel(ctx)
+		cancelled, err := idx.Watch(cctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		closed, err := idx.Watch(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cancel()
+		if _, ok := <-cancelled; ok {
+			t.Fatal("channel not closed after context cancelled")
+		}
+		// updates are not blocked by the unsubscribed watcher or one that is not read
+		for _, e := range es {
+			if err := idx.Add(ctx, e); err != nil {
+				t.Fatal(err)
+			}
+		}
+		idx.Close()
+		for range closed {
+		}
+		if _, err := idx.Watch(ctx, nil); !errors.Is(err, pot.ErrClosed) {
+			t.Fatalf("incorrect error. want %v, got %v", pot.ErrClosed, err)
+		}
+	})
+	t.Run("lookups", func(t *testing.T) {
+		mode := &unpackCountingMode{Mode: basePotMode}
+		idx, err := pot.New(mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		for i := 0; i < 20; i++ {
+			if err := idx.Add(ctx, newDetMockEntry(t, i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// update changes all the entries and returns the nodes unpacked by looking up the entries before the updates
+		update := func(t *testing.T, val int) int64 {
+			t.Helper()
+			before := mode.unpacks.Load()
+			for i := 0; i < 20; i++ {
+				if err := idx.Add(ctx, &mockEntry{newDetMockEntry(t, i).key, val + i}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			return mode.unpacks.Load() - before
+		}
+		if n := update(t, 100); n != 0 {
+			t.Fatalf("entry looked up without watchers: %d nodes unpacked", n)
+		}
+		cctx, cancel := context.WithCancel(ctx)
+		c, err := idx.Watch(cctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := update(t, 200); n == 0 {
+			t.Fatal("entry not looked up for a watcher")
+		}
+		cancel()
+		for range c {
+		}
+		// the cancelled watcher is removed before the next update
+		if n := update(t, 300); n != 0 {
+			t.Fatalf("entry looked up after the watcher is cancelled: %d nodes unpacked", n)
+		}
+	})
+	t.Run("slow", func(t *testing.T) {
+		idx, err := pot.New(basePotMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		slow, err := idx.Watch(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// more changes than a watcher queues
+		count := 2000
+		err = idx.Batch(ctx, func(b *pot.Batch) error {
+			for i := 0; i < count; i++ {
+				if err := b.Put(newDetMockEntry(t, i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the watcher is dropped: its channel is closed without delivering all the changes
+		received := 0
+		timeout := time.After(time.Second)
+		for closed := false; !closed; {
+			select {
+			case _, ok := <-slow:
+				if ok {
+					received++
+				}
+				closed = !ok
+			case <-timeout:
+				t.Fatal("slow watcher not dropped")
+			}
+		}
+		if received == count {
+			t.Fatal("all changes delivered to a dropped watcher")
+		}
+		if err := idx.Add(ctx, newDetMockEntry(t, count)); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// unpackCountingMode counts the nodes unpacked through the mode
+type unpackCountingMode struct {
+	elements.Mode
+	unpacks atomic.Int64
+}
+
+func (m *unpackCountingMode) Unpack(ctx context.Context, n elements.Node) error {
+	m.unpacks.Add(1)
+	return m.Mode.Unpack(ctx, n)
+}
+
+func TestDiff(t *testing.T) {
+	count := 200
+	ctx := context.Background()
+	// modify deletes, changes and adds a few entries and returns the expected changes in key order
+	modify := func(t *testing.T, idx *pot.Index) []pot.Change {
+		t.Helper()
+		var want []pot.Change
+		for i := 0; i < count; i += count / 4 {
+			old := newDetMockEntry(t, i)
+			if err := idx.Delete(ctx, old.key); err != nil {
+				t.Fatal(err)
+			}
+			want = append(want, pot.Change{Key: old.key, Old: old})
+		}
+		for i := 1; i < count; i += count / 4 {
+			old, updated := newDetMockEntry(t, i), &mockEntry{newDetMockEntry(t, i).key, count + i}
+			if err := idx.Add(ctx, updated); err != nil {
+				t.Fatal(err)
+			}
+			want = append(want, pot.Change{Key: old.key, Old: old, New: updated})
+		}
+		for i := count; i < count+4; i++ {
+			updated := newDetMockEntry(t, i)
+			if err := idx.Add(ctx, updated); err != nil {
+				t.Fatal(err)
+			}
+			want = append(want, pot.Change{Key: updated.key, New: updated})
+		}
+		sort.Slice(want, func(i, j int) bool { return bytes.Compare(want[i].Key, want[j].Key) < 0 })
+		return want
+	}
+	diff := func(t *testing.T, from, to *pot.Snapshot) []pot.Change {
+		t.Helper()
+		var got []pot.Change
+		err := from.Diff(ctx, to, func(c pot.Change) (bool, error) {
+			got = append(got, c)
+			return false, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	check := func(t *testing.T, want, got []pot.Change) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Fatalf("incorrect number of changes. want %d, got %d", len(want), len(got))
+		}
+		entryEq := func(want, got elements.Entry) bool {
+			if want == nil || got == nil {
+				return want == nil && got == nil
+			}
+			return eq(want.(*mockEntry), got.(*mockEntry))
+		}
+		for i := range got {
+			if !bytes.Equal(want[i].Key, got[i].Key) || !entryEq(want[i].Old, got[i].Old) || !entryEq(want[i].New, got[i].New) {
+				t.Fatalf("incorrect change at %d. want %v, got %v", i, want[i], got[i])
+			}
+		}
+	}
+	// reverse swaps old and new entries of the changes
+	reverse := func(cs []pot.Change) []pot.Change {
+		rs := make([]pot.Change, len(cs))
+		for i, c := range cs {
+			rs[i] = pot.Change{Key: c.Key, Old: c.New, New: c.Old}
+		}
+		return rs
+	}
+	t.Run("in memory", func(t *testing.T) {
+		idx, err := pot.New(basePotMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		for i := 0; i < count; i++ {
+			idx.Add(ctx, newDetMockEntry(t, i))
+		}
+		base := idx.Snapshot()
+		want := modify(t, idx)
+		check(t, want, diff(t, base, idx.Snapshot()))
+		check(t, reverse(want), diff(t, idx.Snapshot(), base))
+		check(t, nil, diff(t, base, base))
+	})
+	t.Run("persisted", func(t *testing.T) {
+		ls := &countingLoadSaver{LoadSaver: persister.NewInmemLoadSaver()}
+		newf := func(key []byte) elements.Entry { return &mockEntry{key: key} }
+		idx, err := pot.New(elements.NewSwarmPot(basePotMode, ls, newf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		for i := 0; i < count; i++ {
+			idx.Add(ctx, newDetMockEntry(t, i))
+		}
+		baseRef, err := idx.Save(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := modify(t, idx)
+		ref, err := idx.Save(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		load := func(t *testing.T, ref []byte) *pot.Snapshot {
+			idx, err := pot.NewReference(ctx, elements.NewSwarmPotReference(basePotMode, ls, ref, newf), ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { idx.Close() })
+			return idx.Snapshot()
+		}
+		base, current := load(t, baseRef), load(t, ref)
+		ls.loads.Store(0)
+		got := diff(t, base, current)
+		check(t, want, got)
+		for _, c := range got {
+			if !bytes.Equal(c.Root, ref) {
+				t.Fatalf("incorrect root reference. want %x, got %x", ref, c.Root)
+			}
+		}
+		if loads := ls.loads.Load(); loads > int64(count/2) {
+			t.Fatalf("too many nodes loaded. want at most %d, got %d", count/2, loads)
+		}
+		ls.loads.Store(0)
+		check(t, nil, diff(t, load(t, ref), load(t, ref)))
+		if loads := ls.loads.Load(); loads > 2 {
+			t.Fatalf("too many nodes loaded. want at most 2, got %d", loads)
+		}
+	})
+}
+
+func TestMerge(t *testing.T) {
+	count := 100
+	ctx := context.Background()
+	ls := &countingLoadSaver{LoadSaver: persister.NewInmemLoadSaver()}
+	newf := func(key []byte) elements.Entry { return &mockEntry{key: key} }
+	entry := func(i, val int) *mockEntry {
+		return &mockEntry{newDetMockEntry(t, i).key, val}
+	}
+	// save applies the updates to a new index and returns its reference
+	save := func(t *testing.T, ref []byte, updates ...*mockEntry) []byte {
+		t.Helper()
+		mode := elements.NewSwarmPot(basePotMode, ls, newf)
+		idx, err := pot.New(mode)
+		if ref != nil {
+			idx, err = pot.NewReference(ctx, elements.NewSwarmPotReference(basePotMode, ls, ref, newf), ref)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		for _, e := range updates {
+			if e.val < 0 {
+				err = idx.Delete(ctx, e.key)
+			} else {
+				err = idx.Add(ctx, e)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		ref, err = idx.Save(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ref
+	}
+	// updates with negative values delete
+	var base, ours, theirs []*mockEntry
+	for i := 0; i < count; i++ {
+		base = append(base, entry(i, i))
+	}
+	for i := 0; i < 5; i++ {
+		ours = append(ours, entry(i, -1), entry(5+i, 1000+i), entry(count+i, count+i))
+		theirs = append(theirs, entry(10+i, -1), entry(15+i, 1000+i), entry(count+5+i, count+i))
+	}
+	// changes to the same entries, the same change to 21 is no conflict
+	ours = append(ours, entry(20, 2000), entry(21, 4000), entry(22, -1))
+	theirs = append(theirs, entry(20, 3000), entry(21, 4000), entry(22, 5000))
+	want := map[string]int{}
+	for _, e := range base {
+		want[string(e.key)] = e.val
+	}
+	for _, e := range append(append([]*mockEntry{}, theirs...), ours...) {
+		want[string(e.key)] = e.val
+	}
+	// conflicts are resolved taking ours unless it deleted the entry
+	want[string(entry(20, 0).key)] = 2000
+	want[string(entry(22, 0).key)] = 5000
+	for k, v := range want {
+		if v < 0 {
+			delete(want, k)
+		}
+	}
+
+	baseRef := save(t, nil, base...)
+	oursRef := save(t, baseRef, ours...)
+	theirsRef := save(t, baseRef, theirs...)
+	mode := elements.NewSwarmPotReference(basePotMode, ls, baseRef, newf)
+	load := func(t *testing.T, ref []byte) elements.Node {
+		t.Helper()
+		root, _, err := mode.Load(ctx, ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return root
+	}
+
+	t.Run("merge", func(t *testing.T) {
+		var conflicts []string
+		resolve := func(o, th elements.Entry) (elements.Entry, error) {
+			if o == nil {
+				conflicts = append(conflicts, fmt.Sprintf("nil %d", th.(*mockEntry).val))
+				return th, nil
+			}
+			conflicts = append(conflicts, fmt.Sprintf("%d %d", o.(*mockEntry).val, th.(*mockEntry).val))
+			return o, nil
+		}
+		ls.saves.Store(0)
+		merged, err := elements.Merge(ctx, load(t, baseRef), load(t, oursRef), load(t, theirsRef), mode, resolve)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if saves := ls.saves.Load(); saves > int64(count/2) {
+			t.Fatalf("too many nodes saved. want at most %d, got %d", count/2, saves)
+		}
+		sort.Strings(conflicts)
+		if got, want := strings.Join(conflicts, ","), "2000 3000,nil 5000"; got != want {
+			t.Fatalf("incorrect conflicts. want %s, got %s", want, got)
+		}
+		ref := merged.(*elements.SwarmNode).Reference()
+		idx, err := pot.NewReference(ctx, elements.NewSwarmPotReference(basePotMode, ls, ref, newf), ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		if idx.Size() != len(want) {
+			t.Fatalf("incorrect size. want %d, got %d", len(want), idx.Size())
+		}
+		for k, v := range want {
+			checkFound(t, ctx, idx, &mockEntry{[]byte(k), v})
+		}
+		for i := 0; i < 5; i++ {
+			checkNotFound(t, ctx, idx, entry(i, 0))
+			checkNotFound(t, ctx, idx, entry(10+i, 0))
+		}
+	})
+	t.Run("unchanged", func(t *testing.T) {
+		merged, err := elements.Merge(ctx, load(t, baseRef), load(t, oursRef), load(t, baseRef), mode, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ref := merged.(*elements.SwarmNode).Reference(); !bytes.Equal(ref, oursRef) {
+			t.Fatalf("incorrect reference. want %x, got %x", oursRef, ref)
+		}
+	})
+	t.Run("no resolve", func(t *testing.T) {
+		_, err := elements.Merge(ctx, load(t, baseRef), load(t, oursRef), load(t, theirsRef), mode, nil)
+		if !errors.Is(err, elements.ErrConflict) {
+			t.Fatalf("incorrect error. want %v, got %v", elements.ErrConflict, err)
+		}
+	})
+	t.Run("resolve error", func(t *testing.T) {
+		errTest := errors.New("test")
+		_, err := elements.Merge(ctx, load(t, baseRef), load(t, oursRef), load(t, theirsRef), mode, func(_, _ elements.Entry) (elements.Entry, error) {
+			return nil, errTest
+		})
+		if !errors.Is(err, errTest) {
+			t.Fatalf("incorrect error. want %v, got %v", errTest, err)
+		}
+	})
+}
+
+func TestMemoryLimit(t *testing.T) {
+	count := 1000
+	limit := 8000
+	ctx := context.Background()
+	ls := &countingLoadSaver{LoadSaver: persister.NewInmemLoadSaver()}
+	newf := func(key []byte) elements.Entry { return &mockEntry{key: key} }
+	idx, err := pot.New(elements.NewSwarmPot(basePotMode, ls, newf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var all []*mockEntry
+	for i := 0; i < count; i++ {
+		e := newDetMockEntry(t, i)
+		all = append(all, e)
+		idx.Add(ctx, e)
+	}
+	ref, err := idx.Save(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx.Close()
+	sort.Slice(all, func(i, j int) bool { return bytes.Compare(all[i].key, all[j].key) < 0 })
+
+	mode := elements.NewSwarmPotReference(basePotMode, ls, ref, newf)
+	mode.SetMemoryLimit(limit)
+	idx, err = pot.NewReference(ctx, mode, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+
+	t.Run("iterate", func(t *testing.T) {
+		ls.loads.Store(0)
+		for round := 0; round < 2; round++ {
+			i := 0
+			err := idx.Range(ctx, nil, nil, func(e elements.Entry) (bool, error) {
+				if !eq(all[i], e.(*mockEntry)) {
+					return true, fmt.Errorf("incorrect item at %d. want %v, got %v", i, all[i], e)
+				}
+				// only the path to the node unpacked last is kept beyond the limit
+				if usage := mode.MemoryUsage(); usage > 2*limit {
+					return true, fmt.Errorf("memory limit exceeded. want at most %d, got %d", 2*limit, usage)
+				}
+				i++
+				return false, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if i != count {
+				t.Fatalf("incorrect number of items. want %d, got %d", count, i)
+			}
+		}
+		// the nodes packed in the first round are loaded again in the second one
+		if loads := ls.loads.Load(); loads <= int64(count) {
+			t.Fatalf("nodes not packed. want more than %d loads, got %d", count, loads)
+		}
+	})
+
+	t.Run("find", func(t *testing.T) {
+		for _, e := range all {
+			checkFound(t, ctx, idx, e)
+		}
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		mode := elements.NewSwarmPotReference(basePotMode, ls, ref, newf)
+		mode.SetMemoryLimit(1)
+		idx, err := pot.NewReference(ctx, mode, ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		ls.loads.Store(0)
+		var eg errgroup.Group
+		for r := 0; r < 4; r++ {
+			eg.Go(func() error {
+				for i := r; i < count; i += 4 {
+					e, err := idx.Find(ctx, all[i].key)
+					if err != nil {
+						return err
+					}
+					if !eq(all[i], e.(*mockEntry)) {
+						return fmt.Errorf("incorrect item. want %v, got %v", all[i], e)
+					}
+				}
+				return nil
+			})
+			eg.Go(func() error {
+				for round := 0; round < 2; round++ {
+					i := 0
+					err := idx.Iterate(ctx, nil, all[r].key, func(elements.Entry) (bool, error) {
+						i++
+						return false, nil
+					})
+					if err != nil {
+						return err
+					}
+					if i != count {
+						return fmt.Errorf("incorrect number of items. want %d, got %d", count, i)
+					}
+				}
+				return nil
+			})
+		}
+		if err := eg.Wait(); err != nil {
+			t.Fatal(err)
+		}
+		// the nodes are packed once the last traversal is done
+		if usage := mode.MemoryUsage(); usage > 1 {
+			t.Fatalf("memory limit exceeded. want at most 1, got %d", usage)
+		}
+		if loads := ls.loads.Load(); loads <= int64(count) {
+			t.Fatalf("nodes not packed. want more than %d loads, got %d", count, loads)
+		}
+	})
+
+	t.Run("update and save", func(t *testing.T) {
+		for i := count; i < count+100; i++ {
+			e := newDetMockEntry(t, i)
+			all = append(all, e)
+			idx.Add(ctx, e)
+		}
+		for _, e := range all[:100] {
+			if err := idx.Delete(ctx, e.key); err != nil {
+				t.Fatal(err)
+			}
+		}
+		ref, err := idx.Save(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the nodes built by the updates are packed once saved
+		if usage := mode.MemoryUsage(); usage > limit {
+			t.Fatalf("memory limit exceeded. want at most %d, got %d", limit, usage)
+		}
+		for _, e := range all[100:] {
+			checkFound(t, ctx, idx, e)
+		}
+		loaded, err := pot.NewReference(ctx, elements.NewSwarmPotReference(basePotMode, ls, ref, newf), ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer loaded.Close()
+		for _, e := range all[:100] {
+			checkNotFound(t, ctx, loaded, e)
+		}
+		for _, e := range all[100:] {
+			checkFound(t, ctx, loaded, e)
+		}
+	})
+
+	t.Run("promotion", func(t *testing.T) {
+		// promotion unpacks the forks it chooses from while deleting, none of which may be packed meanwhile
+		promoted := closestMode{basePotMode, newDetMockEntry(t, -1).key}
+		mode := elements.NewSwarmPotReference(promoted, ls, ref, newf)
+		mode.SetMemoryLimit(1)
+		idx, err := pot.NewReference(ctx, mode, ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		entries := all[:count]
+		for i := 0; i < count/2; i += 2 {
+			if err := idx.Delete(ctx, entries[i].key); err != nil {
+				t.Fatal(err)
+			}
+			if i%50 == 0 {
+				if _, err := idx.Save(ctx); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		// a batch updates through the mode wrapped to defer packing
+		err = idx.Batch(ctx, func(b *pot.Batch) error {
+			for i := count / 2; i < count; i += 2 {
+				if err := b.Delete(entries[i].key); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.Save(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if usage := mode.MemoryUsage(); usage > 1 {
+			t.Fatalf("memory limit exceeded. want at most 1, got %d", usage)
+		}
+		for i, e := range entries {
+			if i%2 == 0 {
+				checkNotFound(t, ctx, idx, e)
+			} else {
+				checkFound(t, ctx, idx, e)
+			}
+		}
+		if size := idx.Size(); size != count/2 {
+			t.Fatalf("incorrect size. want %d, got %d", count/2, size)
+		}
+	})
+}
+
+func TestSaveWorkers(t *testing.T) {
+	count := 1000
+	workers := 8
+	ctx := context.Background()
+	newf := func(key []byte) elements.Entry { return &mockEntry{key: key} }
+	// save adds the entries in a batch, so the whole pot is saved at once when the batch is committed
+	save := func(t *testing.T, ls persister.LoadSaver, workers int) []byte {
+		t.Helper()
+		mode := elements.NewSwarmPot(basePotMode, ls, newf)
+		mode.SetSaveWorkers(workers)
+		idx, err := pot.New(mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		err = idx.Batch(ctx, func(b *pot.Batch) error {
+			for i := 0; i < count; i++ {
+				if err := b.Put(newDetMockEntry(t, i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ref, err := idx.Save(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ref
+	}
+	want := save(t, persister.NewInmemLoadSaver(), 1)
+	ls := &slowSavingLoadSaver{countingLoadSaver: countingLoadSaver{LoadSaver: persister.NewInmemLoadSaver()}, delay: 100 * time.Microsecond}
+	got := save(t, ls, workers)
+	if !bytes.Equal(want, got) {
+		t.Fatalf("incorrect reference. want %x, got %x", want, got)
+	}
+	if saves := ls.saves.Load(); saves != int64(count) {
+		t.Fatalf("incorrect number of saves. want %d, got %d", count, saves)
+	}
+	if m := ls.maxSaves.Load(); m <= 1 || m > int64(workers) {
+		t.Fatalf("incorrect number of saves in flight. want 2 to %d, got %d", workers, m)
+	}
+	idx, err := pot.NewReference(ctx, elements.NewSwarmPotReference(basePotMode, ls, got, newf), got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+	for i := 0; i < count; i++ {
+		checkFound(t, ctx, idx, newDetMockEntry(t, i))
+	}
+}
+
+// slowSavingLoadSaver saves with a delay and records the saves in flight
+type slowSavingLoadSaver struct {
+	countingLoadSaver
+	delay    time.Duration
+	inFlight atomic.Int64
+	maxSaves atomic.Int64
+}
+
+func (ls *slowSavingLoadSaver) Save(ctx context.Context, data []byte) ([]byte, error) {
+	n := ls.inFlight.Add(1)
+	defer ls.inFlight.Add(-1)
+	for m := ls.maxSaves.Load(); n > m && !ls.maxSaves.CompareAndSwap(m, n); m = ls.maxSaves.Load() {
+	}
+	time.Sleep(ls.delay)
+	return ls.countingLoadSaver.Save(ctx, data)
+}
+
+// slowLoadSaver loads with a delay and records the loads in flight
+type slowLoadSaver struct {
+	countingLoadSaver
+	delay    time.Duration
+	inFlight atomic.Int64
+	maxLoads atomic.Int64
+}
+
+func (ls *slowLoadSaver) Load(ctx context.Context, ref []byte) ([]byte, error) {
+	n := ls.inFlight.Add(1)
+	defer ls.inFlight.Add(-1)
+	for m := ls.maxLoads.Load(); n > m && !ls.maxLoads.CompareAndSwap(m, n); m = ls.maxLoads.Load() {
+	}
+	time.Sleep(ls.delay)
+	return ls.countingLoadSaver.Load(ctx, ref)
+}
+
+func TestPrefetch(t *testing.T) {
+	count := 300
+	workers := 8
+	ctx := context.Background()
+	newf := func(key []byte) elements.Entry { return &mockEntry{key: key} }
+	ls := &slowLoadSaver{countingLoadSaver: countingLoadSaver{LoadSaver: persister.NewInmemLoadSaver()}, delay: time.Millisecond}
+	idx, err := pot.New(elements.NewSwarmPot(basePotMode, ls, newf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var all []*mockEntry
+	for i := 0; i < count; i++ {
+		e := newDetMockEntry(t, i)
+		all = append(all, e)
+		idx.Add(ctx, e)
+	}
+	ref, err := idx.Save(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx.Close()
+	sort.Slice(all, func(i, j int) bool { return bytes.Compare(all[i].key, all[j].key) < 0 })
+	// load loads the pot prefetching with the given number of workers, none if 0
+	// and resets the statistics of the loads
+	load := func(t *testing.T, workers int) *pot.Index {
+		t.Helper()
+		mode := elements.NewSwarmPotReference(basePotMode, ls, ref, newf)
+		mode.SetPrefetch(workers)
+		t.Cleanup(func() { mode.Close() })
+		idx, err := pot.NewReference(ctx, mode, ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { idx.Close() })
+		ls.loads.Store(0)
+		ls.maxLoads.Store(0)
+		return idx
+	}
+
+	t.Run("range", func(t *testing.T) {
+		// scan walks the pot and returns the time it took
+		scan := func(t *testing.T, idx *pot.Index) time.Duration {
+			t.Helper()
+			start := time.Now()
+			i := 0
+			err := idx.Range(ctx, nil, nil, func(e elements.Entry) (bool, error) {
+				if !eq(all[i], e.(*mockEntry)) {
+					return true, fmt.Errorf("incorrect item at %d. want %v, got %v", i, all[i], e)
+				}
+				i++
+				return false, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if i != count {
+				t.Fatalf("incorrect number of items. want %d, got %d", count, i)
+			}
+			return time.Since(start)
+		}
+		sequential := scan(t, load(t, 0))
+		prefetched := scan(t, load(t, workers))
+		// every node but the root is loaded once, most of them in the background
+		if loads := ls.loads.Load(); loads != int64(count-1) {
+			t.Fatalf("incorrect number of loads. want %d, got %d", count-1, loads)
+		}
+		if m := ls.maxLoads.Load(); m < 2 || m > int64(workers)+1 {
+			t.Fatalf("incorrect number of loads in flight. want 2 to %d, got %d", workers+1, m)
+		}
+		// the first fork of each node is still waited for, while its siblings are fetched
+		if limit := sequential * 3 / 4; prefetched > limit {
+			t.Fatalf("no latency gain. want at most %v, got %v", limit, prefetched)
+		}
+	})
+
+	t.Run("find", func(t *testing.T) {
+		idx := load(t, 0)
+		for _, e := range all {
+			checkFound(t, ctx, idx, e)
+		}
+		want := ls.loads.Load()
+		// lookups follow one path, so they load the same nodes with prefetching and nothing in the background
+		idx = load(t, workers)
+		for _, e := range all {
+			checkFound(t, ctx, idx, e)
+		}
+		time.Sleep(10 * time.Millisecond)
+		if loads := ls.loads.Load(); loads != want {
+			t.Fatalf("incorrect number of loads. want %d, got %d", want, loads)
+		}
+		if m := ls.maxLoads.Load(); m != 1 {
+			t.Fatalf("incorrect number of loads in flight. want 1, got %d", m)
+		}
+	})
+
+	t.Run("close", func(t *testing.T) {
+		root, _, err := elements.NewSwarmPotReference(basePotMode, ls, ref, newf).Load(ctx, ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var refs [][]byte
+		_ = root.Iterate(0, func(cn elements.CNode) (bool, error) {
+			refs = append(refs, cn.Node.(persister.TreeNode).Reference())
+			return false, nil
+		})
+		p := elements.NewPrefetcher(ls, 1)
+		ls.loads.Store(0)
+		p.Prefetch(refs)
+		if err := p.Close(); err != nil {
+			t.Fatal(err)
+		}
+		// the fetches queued are dropped, at most the one in flight was loaded
+		loads := ls.loads.Load()
+		time.Sleep(10 * time.Millisecond)
+		if l := ls.loads.Load(); l != loads || l > 1 {
+			t.Fatalf("fetches not stopped. want at most 1 load, got %d", l)
+		}
+		data, err := p.Load(ctx, refs[len(refs)-1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) == 0 {
+			t.Fatal("no data loaded")
+		}
+	})
+}
+
 func newDetMockEntry(t *testing.T, n int) *mockEntry {
 	t.Helper()
 	buf := make([]byte, 4)
@@ -59,6 +59,16 @@ func NewSwarmKvsReference(ctx context.Context, ls persister.LoadSaver, ref []byt
 	}, nil
 }
 
+// NewSwarmKvsFromFeed loads a key-value store from the latest root reference published to the feed of the owner and the topic,
+// which readers can follow as a stable address while the store changes.
+func NewSwarmKvsFromFeed(ctx context.Context, sls *persister.SwarmLoadSaver, owner, topic []byte) (*SwarmKvs, error) {
+	ref, err := sls.Feed(owner, topic).Latest(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve feed: %w", err)
+	}
+	return NewSwarmKvsReference(ctx, sls, ref)
+}
+
 // Get retrieves the value associated with the given key.
 func (ps *SwarmKvs) Get(ctx context.Context, key []byte) ([]byte, error) {
 	entry, err := ps.idx.Find(ctx, key)
@@ -91,6 +101,18 @@ func (ps *SwarmKvs) Save(ctx context.Context) ([]byte, error) {
 	return ref, nil
 }
 
+// Publish saves the store and publishes its root reference as the next update of the feed
+func (ps *SwarmKvs) Publish(ctx context.Context, feed *persister.SwarmFeed) ([]byte, error) {
+	ref, err := ps.Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := feed.Publish(ctx, ref); err != nil {
+		return nil, fmt.Errorf("failed to publish root reference: %w", err)
+	}
+	return ref, nil
+}
+
 // Delete takes a key-value pair out of the trie
 func (ps *SwarmKvs) Delete(ctx context.Context, key []byte) error {
 	err := ps.idx.Delete(ctx, key)
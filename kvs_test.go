@@ -2,12 +2,24 @@ package pot_test
 
 import (
 	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
 	pot "github.com/ethersphere/proximity-order-trie"
 	"github.com/ethersphere/proximity-order-trie/pkg/persister"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/sha3"
 )
 
 func createLs() persister.LoadSaver {
@@ -148,3 +160,154 @@ func TestPotKvs_Save(t *testing.T) {
 		assert.NoError(t, err)
 	})
 }
+
+func TestPotKvs_LogLoadSaver(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "log")
+	ls, err := persister.NewLogLoadSaver(path)
+	assert.NoError(t, err)
+	key1, val1 := keyValuePair(t)
+	key2, val2 := keyValuePair(t)
+	fileSize := func() int64 {
+		stat, err := os.Stat(path)
+		assert.NoError(t, err)
+		return stat.Size()
+	}
+
+	kvs1, _ := pot.NewSwarmKvs(ls)
+	err = kvs1.Put(ctx, key1, val1)
+	assert.NoError(t, err)
+	ref, err := kvs1.Save(ctx)
+	assert.NoError(t, err)
+	synced := fileSize()
+	// the process crashes while writing the nodes of the next update, tearing the last record
+	err = kvs1.Put(ctx, key2, val2)
+	assert.NoError(t, err)
+	assert.NoError(t, ls.Close())
+	size := fileSize()
+	assert.Greater(t, size, synced)
+	assert.NoError(t, os.Truncate(path, size-1))
+
+	// the torn record is dropped and the last saved root reference is not dangling
+	reopened, err := persister.NewLogLoadSaver(path)
+	assert.NoError(t, err)
+	defer reopened.Close()
+	assert.Less(t, fileSize(), size-1)
+	kvs2, err := pot.NewSwarmKvsReference(ctx, reopened, ref)
+	assert.NoError(t, err)
+	val, err := kvs2.Get(ctx, key1)
+	assert.NoError(t, err)
+	assert.Equal(t, val1, val)
+	_, err = kvs2.Get(ctx, key2)
+	assert.Error(t, err, "not found")
+}
+
+// newMockBee serves the bytes, single owner chunk upload and sequence feed lookup endpoints of the Bee API
+func newMockBee(t *testing.T) *httptest.Server {
+	var mu sync.Mutex
+	store := make(map[string][]byte) // data by reference and wrapped chunk data by single owner chunk address
+	keccak256 := func(values ...[]byte) []byte {
+		h := sha3.NewLegacyKeccak256()
+		for _, v := range values {
+			h.Write(v)
+		}
+		return h.Sum(nil)
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		params := make([][]byte, len(parts)-1)
+		for i := range params {
+			params[i], _ = hex.DecodeString(parts[i+1])
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/bytes":
+			data, _ := io.ReadAll(r.Body)
+			ref := persister.Split(data).Address
+			store[string(ref)] = data
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(map[string]string{"reference": hex.EncodeToString(ref)})
+		case r.Method == http.MethodGet && parts[0] == "bytes" && len(params) == 1:
+			data, ok := store[string(params[0])]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write(data)
+		case r.Method == http.MethodPost && parts[0] == "soc" && len(params) == 2:
+			data, _ := io.ReadAll(r.Body)
+			store[string(keccak256(params[1], params[0]))] = data
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodGet && parts[0] == "feeds" && len(params) == 2:
+			var latest []byte
+			var next uint64
+			for ; ; next++ {
+				id := keccak256(params[1], binary.BigEndian.AppendUint64(nil, next))
+				data, ok := store[string(keccak256(id, params[0]))]
+				if !ok {
+					break
+				}
+				latest = data
+			}
+			if latest == nil {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Swarm-Feed-Index-Next", fmt.Sprintf("%016x", next))
+			_, _ = w.Write(latest[8:])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// placeholderSigner returns signatures of the right length, which the mock Bee API does not verify
+type placeholderSigner struct{}
+
+func (placeholderSigner) Sign([]byte) ([]byte, error) {
+	sig := make([]byte, 65)
+	sig[64] = 27
+	return sig, nil
+}
+
+func TestPotKvs_Feed(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	sls := persister.NewSwarmLoadSaver(newMockBee(t).URL, make([]byte, 32))
+	owner := make([]byte, 20)
+	owner[19] = 1
+	topic := persister.FeedTopic("kvs")
+	feed, err := sls.FeedPublisher(owner, &placeholderSigner{}, topic)
+	assert.NoError(t, err)
+
+	_, err = pot.NewSwarmKvsFromFeed(ctx, sls, feed.Owner(), topic)
+	assert.ErrorIs(t, err, persister.ErrFeedNotFound)
+
+	key1, val1 := keyValuePair(t)
+	key2, val2 := keyValuePair(t)
+	kvs1, _ := pot.NewSwarmKvs(sls)
+	defer kvs1.Close()
+	assert.NoError(t, kvs1.Put(ctx, key1, val1))
+	_, err = kvs1.Publish(ctx, feed)
+	assert.NoError(t, err)
+	assert.NoError(t, kvs1.Put(ctx, key2, val2))
+	ref, err := kvs1.Publish(ctx, feed)
+	assert.NoError(t, err)
+
+	// readers follow the feed to the latest root
+	kvs2, err := pot.NewSwarmKvsFromFeed(ctx, sls, feed.Owner(), topic)
+	assert.NoError(t, err)
+	defer kvs2.Close()
+	for _, kv := range [][2][]byte{{key1, val1}, {key2, val2}} {
+		val, err := kvs2.Get(ctx, kv[0])
+		assert.NoError(t, err)
+		assert.Equal(t, kv[1], val)
+	}
+	saved, err := kvs2.Save(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, ref, saved)
+}
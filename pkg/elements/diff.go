@@ -0,0 +1,154 @@
+package elements
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/ethersphere/proximity-order-trie/pkg/persister"
+)
+
+// Diff walks the entries that differ between two pots in ascending order of keys
+// and calls f with the entry in a (old) and in b (new); old is nil for entries added in b
+// and new is nil for entries removed from a.
+// Subtries that are the same node or persisted under the same reference on both sides
+// are skipped without unpacking, so comparing two versions of a pot only loads the nodes that differ.
+func Diff(ctx context.Context, a, b CNode, amode, bmode Mode, f func(old, new Entry) (stop bool, err error)) error {
+	as := &diffStack{mode: amode, items: []diffItem{{CNode: a}}}
+	bs := &diffStack{mode: bmode, items: []diffItem{{CNode: b}}}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		x, okx := as.top()
+		y, oky := bs.top()
+		var stop bool
+		var err error
+		switch {
+		case !okx && !oky:
+			return nil
+
+		case !oky || okx && x.before(y):
+			// x is not in b
+			if x.entry == nil {
+				err = as.expand(ctx)
+				break
+			}
+			as.pop()
+			stop, err = f(x.entry, nil)
+
+		case !okx || y.before(x):
+			// y is not in a
+			if y.entry == nil {
+				err = bs.expand(ctx)
+				break
+			}
+			bs.pop()
+			stop, err = f(nil, y.entry)
+
+		case x.entry != nil && y.entry != nil:
+			// overlapping entries have the same key
+			as.pop()
+			bs.pop()
+			if !x.entry.Equal(y.entry) {
+				stop, err = f(x.entry, y.entry)
+			}
+
+		case x.entry == nil && y.entry == nil && x.At == y.At && same(x.Node, y.Node):
+			as.pop()
+			bs.pop()
+
+		default:
+			// overlapping subtries are expanded starting from the wider one
+			// so that subtries shared by both sides meet at the top of the stacks
+			if x.entry == nil && (y.entry != nil || x.At <= y.At) {
+				err = as.expand(ctx)
+			}
+			if err == nil && y.entry == nil && (x.entry != nil || y.At <= x.At) {
+				err = bs.expand(ctx)
+			}
+		}
+		if stop || err != nil {
+			return err
+		}
+	}
+}
+
+// diffItem is either an entry or a subtrie spanning the keys from lo to hi
+// nil bounds mean the key space is not limited on that side
+type diffItem struct {
+	CNode
+	entry  Entry
+	lo, hi []byte
+}
+
+// before tells if all the keys of the item come before the keys of the other item
+func (d diffItem) before(o diffItem) bool {
+	return d.hi != nil && o.lo != nil && bytes.Compare(d.hi, o.lo) < 0
+}
+
+// diffStack holds the items of a pot yet to be compared with the next item on top
+type diffStack struct {
+	mode  Mode
+	items []diffItem
+}
+
+func (s *diffStack) top() (diffItem, bool) {
+	if len(s.items) == 0 {
+		return diffItem{}, false
+	}
+	return s.items[len(s.items)-1], true
+}
+
+func (s *diffStack) pop() {
+	s.items = s.items[:len(s.items)-1]
+}
+
+// expand replaces the subtrie on top of the stack with its entry and forks
+// so that they come off the stack in ascending order of keys
+func (s *diffStack) expand(ctx context.Context) error {
+	d, _ := s.top()
+	s.pop()
+	if err := s.mode.Unpack(ctx, d.Node); err != nil {
+		return err
+	}
+	if Empty(d.Node) {
+		return nil
+	}
+	k := KeyOf(d.Node)
+	item := func(c CNode) diffItem {
+		lo, hi := forkSpan(k, c.At)
+		return diffItem{CNode: c, lo: lo, hi: hi}
+	}
+	// higher forks at lower POs hold the highest keys
+	var lower []diffItem
+	_ = d.Node.Iterate(d.At+1, func(c CNode) (bool, error) {
+		if k[c.At/8]&(1<<(7-c.At%8)) != 0 {
+			lower = append(lower, item(c))
+		} else {
+			s.items = append(s.items, item(c))
+		}
+		return false, nil
+	})
+	s.items = append(s.items, diffItem{entry: d.Node.Entry(), lo: k, hi: k})
+	for i := len(lower) - 1; i >= 0; i-- {
+		s.items = append(s.items, lower[i])
+	}
+	return nil
+}
+
+// same tells if two nodes are the same in memory or persisted under the same reference
+func same(n, m Node) bool {
+	if n == m {
+		return true
+	}
+	tn, ok := n.(persister.TreeNode)
+	if !ok {
+		return false
+	}
+	tm, ok := m.(persister.TreeNode)
+	if !ok {
+		return false
+	}
+	ref := tn.Reference()
+	return len(ref) > 0 && bytes.Equal(ref, tm.Reference())
+}
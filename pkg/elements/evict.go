@@ -0,0 +1,193 @@
+package elements
+
+import (
+	"container/list"
+	"sync"
+)
+
+// memoryBudget keeps the nodes of a SwarmPot held in memory within a limit by packing the least recently used ones.
+// Only nodes with a reference are tracked: the ones unpacked from storage and the ones saved by SwarmPot.Save.
+// The memory a node takes is estimated by the length of its serialisation.
+//
+// Nodes are unpacked and packed under the lock of the budget, so traversals unpacking the same node concurrently
+// see it loaded once. A node is only packed while at most one traversal is registered, see SwarmPot.Traverse,
+// and no update is in progress, see SwarmPot.Updating.
+type memoryBudget struct {
+	mu      sync.Mutex
+	limit   int
+	used    int
+	active  int        // traversals in progress
+	updates int        // updates in progress, nothing is packed meanwhile
+	lru     *list.List // of *budgetEntry, the most recently used at the front
+	nodes   map[*SwarmNode]*list.Element
+}
+
+type budgetEntry struct {
+	node *SwarmNode
+	size int
+}
+
+func newMemoryBudget(limit int) *memoryBudget {
+	return &memoryBudget{
+		limit: limit,
+		lru:   list.New(),
+		nodes: make(map[*SwarmNode]*list.Element),
+	}
+}
+
+// enter registers a traversal
+func (b *memoryBudget) enter() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.active++
+}
+
+// leave ends a traversal, the last one packs the nodes left over the limit by concurrent traversals
+func (b *memoryBudget) leave() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.active--
+	if b.active == 0 {
+		b.evict(nil)
+	}
+}
+
+// update registers an update
+func (b *memoryBudget) update() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.updates++
+}
+
+// updated ends an update, the last one packs the nodes it left over the limit
+func (b *memoryBudget) updated() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.updates--
+	if b.updates == 0 {
+		b.evict(nil)
+	}
+}
+
+// unpacked tells if the node is held in memory and marks it as the most recently used
+func (b *memoryBudget) unpacked(n *SwarmNode) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if n.MemNode == nil {
+		return false
+	}
+	if e, ok := b.nodes[n]; ok {
+		b.lru.MoveToFront(e)
+	}
+	return true
+}
+
+// held tells if the node is held in memory
+func (b *memoryBudget) held(n *SwarmNode) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return n.MemNode != nil
+}
+
+// load sets the loaded content of a packed node, tracks it and packs the least recently used nodes beyond the limit
+// if a concurrent traversal loaded the node meanwhile, its content is kept
+func (b *memoryBudget) load(n *SwarmNode, m *MemNode, size int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if n.MemNode == nil {
+		n.MemNode = m
+		b.track(n, size)
+	}
+	b.evict(n)
+}
+
+// track starts tracking a node held in memory unless it is tracked already
+func (b *memoryBudget) track(n *SwarmNode, size int) {
+	if _, ok := b.nodes[n]; ok {
+		return
+	}
+	b.nodes[n] = b.lru.PushFront(&budgetEntry{node: n, size: size})
+	b.used += size
+}
+
+// evict packs the least recently used nodes until the memory used is within the limit.
+// The node being unpacked and its ancestors are kept as the traversal reaching it uses them after it returns,
+// the other nodes are loaded again when a traversal unpacks them.
+// Other traversals may use any node, so nothing is packed while more than one is in progress.
+// Updates hold nodes off the path, such as the forks a promotion chooses from, so nothing is packed during one.
+func (b *memoryBudget) evict(unpacked *SwarmNode) {
+	if b.active > 1 || b.updates > 0 {
+		return
+	}
+	for e := b.lru.Back(); e != nil && b.used > b.limit; {
+		prev := e.Prev()
+		be := e.Value.(*budgetEntry)
+		if unpacked == nil || !isAncestor(be.node, unpacked) {
+			b.lru.Remove(e)
+			delete(b.nodes, be.node)
+			b.used -= be.size
+			be.node.MemNode = nil
+		}
+		e = prev
+	}
+}
+
+// usage returns the estimated memory taken by the tracked nodes
+func (b *memoryBudget) usage() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+// isAncestor tells if d is a or a descendant of a reached through the nodes in memory,
+// following the fork at the proximity order of the keys at each node
+func isAncestor(a, d *SwarmNode) bool {
+	k := KeyOf(d)
+	if k == nil {
+		return a == d
+	}
+	for n := a; n != nil && n.MemNode != nil && !n.MemNode.Empty(); {
+		if n == d {
+			return true
+		}
+		po := PO(KeyOf(n), k, 0)
+		if po >= 8*len(k) {
+			return false
+		}
+		fork, _ := n.MemNode.Fork(po).Node.(*SwarmNode)
+		n = fork
+	}
+	return false
+}
+
+// saved tracks the nodes under n held in memory that got a reference by being saved
+// and packs the least recently used nodes beyond the limit
+func (b *memoryBudget) saved(n *SwarmNode) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.trackSaved(n); err != nil {
+		return err
+	}
+	b.evict(nil)
+	return nil
+}
+
+// trackSaved tracks the nodes under n, nodes built by updates being only reachable through other such nodes,
+// so tracked subtrees are skipped
+func (b *memoryBudget) trackSaved(n *SwarmNode) error {
+	return n.Iterate(0, func(cn CNode) (bool, error) {
+		c, ok := cn.Node.(*SwarmNode)
+		if !ok || c.MemNode == nil || c.Reference() == nil {
+			return false, nil
+		}
+		if _, tracked := b.nodes[c]; tracked {
+			return false, nil
+		}
+		data, err := c.MarshalBinary()
+		if err != nil {
+			return true, err
+		}
+		b.track(c, len(data))
+		return false, b.trackSaved(c)
+	})
+}
@@ -0,0 +1,80 @@
+package elements
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrConflict = errors.New("merge conflict")
+)
+
+// Merge combines the changes made to base in ours and in theirs and returns the root of the merged pot.
+// The changes of theirs are applied to ours, so the merged pot shares all the subtries of ours
+// that theirs did not change and only the subtries of base that theirs changed are unpacked.
+// If ours and theirs changed the entry at the same key differently, resolve is called with the two
+// entries (nil if deleted) and the entry it returns (nil to delete) is taken. With a nil resolve,
+// such a conflict fails the merge with ErrConflict.
+// The merged root is packed once all changes are applied.
+func Merge(ctx context.Context, base, ours, theirs Node, mode Mode, resolve func(ours, theirs Entry) (Entry, error)) (Node, error) {
+	defer Updating(mode)()
+	root := ours
+	if root == nil {
+		root = mode.New()
+	}
+	err := Diff(ctx, NewAt(-1, base), NewAt(-1, theirs), mode, mode, func(from, to Entry) (bool, error) {
+		var k []byte
+		if to != nil {
+			k = to.Key()
+		} else {
+			k = from.Key()
+		}
+		e, err := Find(ctx, root, k, mode)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return true, err
+		}
+		switch {
+		case equal(e, to):
+			return false, nil
+		case !equal(e, from):
+			// ours changed the entry too
+			if resolve == nil {
+				return true, fmt.Errorf("%w at key %x", ErrConflict, k)
+			}
+			if to, err = resolve(e, to); err != nil {
+				return true, err
+			}
+			if equal(e, to) {
+				return false, nil
+			}
+		}
+		var entry *Entry
+		if to != nil {
+			entry = &to
+		}
+		u, err := update(ctx, mode.New(), NewAt(0, root), k, entry, mode)
+		if err != nil {
+			return true, err
+		}
+		if u != nil {
+			root = u
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := mode.Pack(ctx, root); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// equal tells if two entries are the same or both missing
+func equal(e, f Entry) bool {
+	if e == nil || f == nil {
+		return e == nil && f == nil
+	}
+	return e.Equal(f)
+}
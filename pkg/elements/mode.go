@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
+	"sync/atomic"
 
 	"github.com/ethersphere/proximity-order-trie/pkg/persister"
 )
@@ -20,6 +21,24 @@ type Mode interface {
 	Update(context.Context, Node, []byte, *Entry) (Node, error)            // mode specific update
 }
 
+// Traverse registers a traversal of the nodes of the mode that may run concurrently with others
+// and returns the function ending it, see SwarmPot.Traverse. Modes without it need no registration.
+func Traverse(mode Mode) (done func()) {
+	if t, ok := mode.(interface{ Traverse() func() }); ok {
+		return t.Traverse()
+	}
+	return func() {}
+}
+
+// Updating registers an update of the nodes of the mode and returns the function ending it,
+// see SwarmPot.Updating. Modes without it need no registration.
+func Updating(mode Mode) (done func()) {
+	if u, ok := mode.(interface{ Updating() func() }); ok {
+		return u.Updating()
+	}
+	return func() {}
+}
+
 type SingleOrder struct {
 	depth int
 }
@@ -50,6 +69,20 @@ func (SingleOrder) Up() func(CNode) bool {
 	return nil
 }
 
+// Closest returns a promotion policy for Up that, after deletion, promotes the entry closest to key k
+// among the descendants of the deleted node
+func Closest(k []byte) func(CNode) bool {
+	// the fork first matching k on the bit it branches off at leads to the entries closest to k
+	return func(c CNode) bool {
+		i, mask := c.At/8, byte(1)<<(7-c.At%8)
+		key := KeyOf(c.Node)
+		if i >= len(key) || i >= len(k) {
+			return false
+		}
+		return key[i]&mask == k[i]&mask
+	}
+}
+
 // New constructs a new in-memory Node
 func (SingleOrder) New() Node {
 	return &MemNode{}
@@ -77,10 +110,14 @@ func (so SingleOrder) Update(ctx context.Context, root Node, k []byte, e *Entry)
 
 // Mode for Swarm persisted pots
 type SwarmPot struct {
-	Mode                        // non-persisted mode
-	n    Node                   // root node
-	ls   persister.LoadSaver    // persister interface to save pointer based data structure nodes
-	newf func(key []byte) Entry // pot entry constructor function. Entry must set the given key
+	Mode                           // non-persisted mode
+	n       Node                   // root node
+	ls      persister.LoadSaver    // persister interface to save pointer based data structure nodes
+	newf    func(key []byte) Entry // pot entry constructor function. Entry must set the given key
+	mem     *memoryBudget          // nodes held in memory if their memory is limited
+	workers int                    // number of concurrent node saves, nodes are saved one by one if at most 1
+
+	prefetcher atomic.Pointer[Prefetcher] // fetches the forks traversals are about to visit if set
 }
 
 // NewSwarmPot constructs a Mode for persisted pots
@@ -102,7 +139,7 @@ func (pm *SwarmPot) NewPacked(ref []byte) *SwarmNode {
 func (pm *SwarmPot) Load(ctx context.Context, ref []byte) (r Node, loaded bool, err error) {
 	root := pm.NewPacked(ref)
 	root.MemNode = &MemNode{}
-	if err := persister.Load(ctx, pm.ls, root); err != nil {
+	if err := persister.Load(ctx, pm.loader(), root); err != nil {
 		return nil, false, fmt.Errorf("failed to load persisted pot root node at %s: %w", hex.EncodeToString(ref), err)
 	}
 	pm.n = root
@@ -115,31 +152,173 @@ func (pm *SwarmPot) Save(ctx context.Context) ([]byte, error) {
 		return nil, fmt.Errorf("node is nil")
 	}
 
-	err := persister.Save(ctx, pm.ls, pm.n.(*SwarmNode))
+	err := pm.save(ctx, pm.n.(*SwarmNode))
 	if err != nil {
 		return nil, fmt.Errorf("pot save: %w", err)
 	}
+	// the saved nodes can be packed to keep within the memory limit
+	if pm.mem != nil {
+		if err := pm.mem.saved(pm.n.(*SwarmNode)); err != nil {
+			return nil, fmt.Errorf("pot save: %w", err)
+		}
+	}
 
 	return pm.n.(*SwarmNode).Reference(), nil
 }
 
+// SetMemoryLimit limits the memory taken by the nodes of the pot held in memory to about limit bytes,
+// estimated by the length of their serialisation. Beyond the limit the least recently used nodes with a reference
+// are packed and loaded again when needed; nodes built by updates get a reference when the pot is saved.
+// Nodes are only packed while at most one traversal registered by Traverse is in progress, so goroutines
+// traversing the pot concurrently must register, as reads and updates through an Index or a Snapshot do.
+// Unregistered traversals, such as calls to Iterate or proofs using the mode directly, must not run
+// concurrently with other traversals. The limit is set before the pot is used.
+// A limit of 0 or less keeps unpacked nodes in memory, which is the default.
+func (pm *SwarmPot) SetMemoryLimit(limit int) {
+	if limit <= 0 {
+		pm.mem = nil
+		return
+	}
+	pm.mem = newMemoryBudget(limit)
+}
+
+// Traverse registers a traversal of the pot that may run concurrently with others and returns the function
+// ending it. With a memory limit, nodes are only packed while at most the traversal unpacking them is registered,
+// the path to the node it unpacks being kept, and the nodes left beyond the limit by concurrent traversals
+// are packed once the last one ends.
+func (pm *SwarmPot) Traverse() (done func()) {
+	b := pm.mem
+	if b == nil {
+		return func() {}
+	}
+	b.enter()
+	return b.leave
+}
+
+// Updating registers an update of the pot and returns the function ending it. With a memory limit,
+// no node is packed until the update ends, as it holds nodes it unpacked across later unpacks,
+// such as the forks a promotion policy chooses from, and the nodes left beyond the limit are packed then.
+// Update and Merge register themselves.
+func (pm *SwarmPot) Updating() (done func()) {
+	b := pm.mem
+	if b == nil {
+		return func() {}
+	}
+	b.update()
+	return b.updated
+}
+
+// SetSaveWorkers sets the number of nodes saved concurrently by Save and Pack
+// which saves the forks of a node in parallel, useful with LoadSavers having a long round-trip such as Swarm.
+// With 1 or less nodes are saved one by one, which is the default.
+func (pm *SwarmPot) SetSaveWorkers(workers int) {
+	pm.workers = workers
+}
+
+// SetPrefetch makes the pot fetch the forks that iterations and range scans are about to visit in the background
+// with workers loads in flight, so that they do not wait for a round-trip for each fork.
+// Lookups of single keys, such as Find and proofs, follow one path and prefetch nothing.
+// With 0 or less nodes are loaded when unpacked only, which is the default. The previous prefetcher is closed.
+func (pm *SwarmPot) SetPrefetch(workers int) {
+	var p *Prefetcher
+	if workers > 0 {
+		p = NewPrefetcher(pm.ls, workers)
+	}
+	if old := pm.prefetcher.Swap(p); old != nil {
+		old.Close()
+	}
+}
+
+// Close stops the background fetches of the pot
+func (pm *SwarmPot) Close() error {
+	pm.SetPrefetch(0)
+	return nil
+}
+
+// Prefetch fetches the packed nodes of the forks in the background if the pot prefetches
+func (pm *SwarmPot) Prefetch(forks []CNode) {
+	p := pm.prefetcher.Load()
+	if p == nil {
+		return
+	}
+	refs := make([][]byte, 0, len(forks))
+	for _, cn := range forks {
+		if dn, ok := cn.Node.(*SwarmNode); ok && dn.Reference() != nil && !pm.unpacked(dn) {
+			refs = append(refs, dn.Reference())
+		}
+	}
+	p.Prefetch(refs)
+}
+
+// loader returns the LoadSaver nodes are loaded with, the prefetcher if the pot prefetches
+func (pm *SwarmPot) loader() persister.LoadSaver {
+	if p := pm.prefetcher.Load(); p != nil {
+		return p
+	}
+	return pm.ls
+}
+
+// unpacked tells if the node is held in memory
+func (pm *SwarmPot) unpacked(dn *SwarmNode) bool {
+	if pm.mem != nil {
+		return pm.mem.held(dn)
+	}
+	return dn.MemNode != nil
+}
+
+// save persists the nodes under n with the configured number of workers and syncs the LoadSaver.
+// Save, Pack and Commit all save through it, so every reference the pot hands out, be it returned by Save,
+// set on a snapshot or merged root or notified to watchers, is to nodes that are durable.
+func (pm *SwarmPot) save(ctx context.Context, n *SwarmNode) error {
+	var err error
+	if pm.workers > 1 {
+		err = persister.SaveParallel(ctx, pm.ls, n, pm.workers)
+	} else {
+		err = persister.Save(ctx, pm.ls, n)
+	}
+	if err != nil {
+		return err
+	}
+	return persister.Sync(ctx, pm.ls)
+}
+
+// MemoryUsage returns the estimated memory taken by the nodes tracked for the memory limit
+func (pm *SwarmPot) MemoryUsage() int {
+	if pm.mem == nil {
+		return 0
+	}
+	return pm.mem.usage()
+}
+
+// Commit packs a root built outside Update, such as by a batch of updates, and makes it the root persisted by Save
+func (pm *SwarmPot) Commit(ctx context.Context, root Node) error {
+	if err := pm.Pack(ctx, root); err != nil {
+		return err
+	}
+	pm.n = root
+	return nil
+}
+
 // Update builds on the generic Update
 func (pm *SwarmPot) Update(ctx context.Context, root Node, k []byte, e *Entry) (Node, error) {
 	update, err := Update(ctx, pm.New(), NewAt(0, root), k, e, pm)
 	if err != nil {
 		return nil, err
 	}
-	pm.n = update
+	// a nil update means no change, the root stays the one to save
+	if update != nil {
+		pm.n = update
+	}
 	return update, nil
 }
 
-// Pack serialises and saves the object
+// Pack serialises and saves the object, the saved nodes being durable once it returns
 // once a new node is saved it can be delinked as node from memory
 func (pm *SwarmPot) Pack(ctx context.Context, n Node) error {
 	if n == nil {
 		return nil // nothing to save
 	}
-	return persister.Save(ctx, pm.ls, n.(*SwarmNode))
+	return pm.save(ctx, n.(*SwarmNode))
 }
 
 // Unpack loads and deserialises node into memory
@@ -148,14 +327,67 @@ func (pm *SwarmPot) Unpack(ctx context.Context, n Node) error {
 		return nil
 	}
 	dn := n.(*SwarmNode)
-	if dn.MemNode != nil {
+	if pm.mem == nil {
+		if dn.MemNode != nil {
+			return nil
+		}
+		dn.MemNode = &MemNode{}
+		return persister.Load(ctx, pm.loader(), dn)
+	}
+	if pm.mem.unpacked(dn) {
 		return nil
 	}
-	dn.MemNode = &MemNode{}
-	return persister.Load(ctx, pm.ls, dn)
+	data, err := pm.loader().Load(ctx, dn.Reference())
+	if err != nil {
+		return err
+	}
+	// the node is loaded aside as other traversals may unpack it concurrently
+	loaded := &SwarmNode{MemNode: &MemNode{}, ref: dn.ref, newf: dn.newf}
+	if err := loaded.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	pm.mem.load(dn, loaded.MemNode, len(data))
+	return nil
 }
 
 // New constructs a new node
 func (pm *SwarmPot) New() Node {
 	return &SwarmNode{newf: pm.newf, MemNode: &MemNode{}}
 }
+
+// Balanced is a Mode whose insertion policy keeps the entry with the lowest key
+// at the root of every subtrie. It maintains the following invariants:
+//   - the key pinned to a node is lower (in lexicographic order) than any key in its forks,
+//     i.e., it has a 0 bit at the PO of each of its forks
+//   - the shape of the pot only depends on the set of keys it holds, not on the order
+//     of insertions and deletions, so the same entries always persist to the same reference
+//   - the path to a key only branches at the 1 bits of the key, so sequential keys
+//     do not build up long paths
+type Balanced struct {
+	SingleOrder
+}
+
+var _ Mode = (*Balanced)(nil)
+
+// NewBalanced constructs a balanced Mode for keys of bit length d
+func NewBalanced(d int) *Balanced {
+	return &Balanced{SingleOrder{depth: d}}
+}
+
+// Down pushes the entries of a fork below the node if they are greater than its key
+// which is the case iff they have a 1 bit where they branch off
+func (Balanced) Down(cn CNode) bool {
+	k := KeyOf(cn.Node)
+	return k[cn.At/8]&(1<<(7-cn.At%8)) != 0
+}
+
+// Up promotes the lowest key after deletion which is the entry of the last fork:
+// without a policy the last fork is promoted and the forks below it are kept down by Down
+func (Balanced) Up() func(CNode) bool {
+	return nil
+}
+
+// Update is the pot update function applying the balanced insertion policy
+func (b Balanced) Update(ctx context.Context, root Node, k []byte, e *Entry) (Node, error) {
+	return Update(ctx, b.New(), NewAt(0, root), k, e, b)
+}
@@ -50,6 +50,7 @@ func Whack(acc Node, n, m CNode) {
 
 // Update
 func Update(ctx context.Context, acc Node, cn CNode, k []byte, e *Entry, mode Mode) (Node, error) {
+	defer Updating(mode)()
 	u, err := update(ctx, acc, cn, k, e, mode)
 	if err != nil {
 		return nil, err
@@ -64,7 +65,7 @@ func update(ctx context.Context, acc Node, cn CNode, k []byte, entry *Entry, mod
 	/**
 	1. **Empty node case**: If target node is empty, simply pin the new entry
 	2. **Exact match case**: Update the entry if needed and use Whack to rebuild
-	3. **Empty match case**: Create a new node with the entry and use Whirl
+	3. **Empty match case**: Create a new node with the entry and use Whirl, or Wedge if the Mode keeps it down
 	4. **Special cases for proximity**: Different combinations of operations based on the node's depth and the Mode's preferences
 	5. **Recursive descent**: Using the Mode's policy to determine when to recurse into the trie
 	**/
@@ -81,8 +82,7 @@ func update(ctx context.Context, acc Node, cn CNode, k []byte, entry *Entry, mod
 	}
 	if match {
 		if entry == nil {
-			node := Pull(acc, cn, mode)
-			return node, nil
+			return Pull(ctx, acc, cn, mode)
 		}
 		orig := cn.Node.Entry()
 		if (*entry).Equal(orig) {
@@ -99,12 +99,36 @@ func update(ctx context.Context, acc Node, cn CNode, k []byte, entry *Entry, mod
 		}
 		n := mode.New()
 		n.Pin(*entry)
-		Whirl(acc, cn, NewAt(cm.At, n))
+		m := NewAt(cm.At, n)
+		if mode.Down(m) {
+			Wedge(acc, cn, m)
+			return acc, nil
+		}
+		if mode.Down(NewAt(m.At, cn.Node)) {
+			// the node is kept down below the new entry, so instead of reusing it
+			// it gets a node of its own without the forks taken over by the new entry
+			c := mode.New()
+			Append(c, cn.Node, m.At+1, MaxDepth)
+			c.Pin(cn.Node.Entry())
+			Append(acc, cn.Node, cn.At, m.At)
+			acc.Append(NewAt(m.At, c))
+			acc.Pin(*entry)
+			return acc, nil
+		}
+		Whirl(acc, cn, m)
+		return acc, nil
+	}
+	if mode.Down(cm) {
+		res, err := update(ctx, mode.New(), cm, k, entry, mode)
+		if err != nil || res == nil {
+			return nil, err // nil result means no change
+		}
+		Wedge(acc, cn, NewAt(cm.At, res))
 		return acc, nil
 	}
 	if cm.At == 0 {
 		res, err := update(ctx, acc, cm, k, entry, mode)
-		if err != nil {
+		if err != nil || res == nil {
 			return nil, err
 		}
 		cm := NewAt(-1, res)
@@ -112,58 +136,96 @@ func update(ctx context.Context, acc Node, cn CNode, k []byte, entry *Entry, mod
 			Wedge(acc, cn, NewAt(0, cm.Node))
 			return acc, nil
 		}
-		if mode.Down(cm) {
-			acc := mode.New()
-			Wedge(acc, cn, cm)
-			return acc, nil
-		}
 		n := mode.New()
 		Whack(n, cm, cn)
 		return n, nil
 	}
-	if mode.Down(cm) {
-		res, err := update(ctx, mode.New(), cm, k, entry, mode)
-		if err != nil {
-			return nil, err
-		}
-		Wedge(acc, cn, NewAt(cm.At, res))
-		return acc, nil
-	}
 	Whirl(acc, cn, cm)
 	return update(ctx, acc, cm.Next(), k, entry, mode)
 }
 
 // Pull handles node removal and restructuring of the trie.
-func Pull(acc Node, cn CNode, mode Mode) Node {
-	if f := mode.Up(); f == nil {
-		cm := FindFork(cn, nil, mode)
-		if !Empty(cm.Node) {
-			Wedge(acc, cn, NewAt(cm.At, nil))
-			return pullTail(acc, cm.Next(), mode)
+// The slot of the removed node is taken over by one of its descendants, chosen by the promotion
+// policy of the mode (see Mode.Up): starting from the removed node, the first fork the policy accepts
+// is followed until it accepts none, the entry of the last node reached is promoted.
+// Without a policy the last fork is followed down until the insertion policy of the mode keeps it
+// below the node reached (see Mode.Down), all the way down if it keeps none.
+func Pull(ctx context.Context, acc Node, cn CNode, mode Mode) (Node, error) {
+	f := mode.Up()
+	cm, err := findFork(ctx, cn, f, mode)
+	if err != nil {
+		return nil, err
+	}
+	if f != nil && Empty(cm.Node) {
+		// the removed node itself cannot stay, so one of its forks must be promoted
+		if cm, err = findFork(ctx, cn, nil, mode); err != nil {
+			return nil, err
 		}
+	}
+	if Empty(cm.Node) {
 		j := cn.At - 1
 		cn = acc.Fork(j)
 		acc.Truncate(j)
 		if cn.Node == nil {
 			// this happens only if the pot is singleton
-			return mode.New()
+			return mode.New(), nil
 		}
 		Wedge(acc, cn, NewAt(j, nil))
-		return acc
+		return acc, nil
 	}
-	return pull(acc, cn, mode)
+	return pull(ctx, acc, cn, cm, f, mode)
 }
 
-func pull(_ Node, _ CNode, _ Mode) Node {
-	return nil
+// pull promotes an entry from the fork cm into the slot of the node of cn being removed
+func pull(ctx context.Context, acc Node, cn, cm CNode, f func(CNode) bool, mode Mode) (Node, error) {
+	Append(acc, cn.Node, cn.At, cm.At)
+	// forks of the removed node beyond cm all fall on the fork at cm.At of the promoted entry
+	// they are gathered under one of their own entries
+	if len(Slice(cn.Node, cm.At+1, MaxDepth)) > 0 {
+		rest, err := Pull(ctx, mode.New(), NewAt(cm.At+1, cn.Node), mode)
+		if err != nil {
+			return nil, err
+		}
+		acc.Append(NewAt(cm.At, rest))
+	}
+	return pullTail(ctx, acc, cm.Next(), f, mode)
 }
 
-func pullTail(acc Node, cn CNode, mode Mode) Node {
-	cm := FindFork(cn, nil, mode)
-	if Empty(cm.Node) {
+func pullTail(ctx context.Context, acc Node, cn CNode, f func(CNode) bool, mode Mode) (Node, error) {
+	cm, err := findFork(ctx, cn, f, mode)
+	if err != nil {
+		return nil, err
+	}
+	// without a policy, the fork is left below the node reached if the mode keeps it down,
+	// which costs loading this one fork to tell
+	if Empty(cm.Node) || f == nil && mode.Down(cm) {
 		Wedge(acc, cn, NewAt(mode.Depth(), nil))
-		return acc
+		return acc, nil
 	}
 	Whirl(acc, cn, cm)
-	return pullTail(acc, cm.Next(), mode)
+	return pullTail(ctx, acc, cm.Next(), f, mode)
+}
+
+// findFork is FindFork on a node whose forks may need to be loaded
+// the fork returned is unpacked, and so are the forks the policy inspected before accepting it
+func findFork(ctx context.Context, n CNode, f func(CNode) bool, mode Mode) (CNode, error) {
+	var err error
+	accept := f
+	if f != nil {
+		// the promotion policy inspects the entries of the forks, which are unpacked as it reaches them
+		accept = func(c CNode) bool {
+			if err = mode.Unpack(ctx, c.Node); err != nil {
+				return true
+			}
+			return f(c)
+		}
+	}
+	cm := FindFork(n, accept, mode)
+	if err != nil {
+		return CNode{}, err
+	}
+	if err := mode.Unpack(ctx, cm.Node); err != nil {
+		return CNode{}, err
+	}
+	return cm, nil
 }
@@ -24,9 +24,20 @@ func NewSwarmNode(newf func(key []byte) Entry) *SwarmNode {
 	}
 }
 
-// Empty returns true if no entry is pinned to the Node
+// Empty returns true if no entry is pinned to the Node, a packed node being empty without a reference
 func (n *SwarmNode) Empty() bool {
-	return n.MemNode == nil && n.ref == nil || n.MemNode.Empty()
+	if n.MemNode == nil {
+		return n.ref == nil
+	}
+	return n.MemNode.Empty()
+}
+
+// Entry returns the entry pinned to the Node, nil while it is packed
+func (n *SwarmNode) Entry() Entry {
+	if n.MemNode == nil {
+		return nil
+	}
+	return n.MemNode.Entry()
 }
 
 // Reference returns the reference
@@ -87,12 +98,13 @@ func (n *SwarmNode) MarshalBinary() ([]byte, error) {
 	if takenBytes > 0 {
 		forkSizesBytes = append(forkSizesBytes, make([]byte, 32-takenBytes)...)
 	}
-	return append(
-		keyBytes,
-		append(bitMap,
-			append(forRefBytes,
-				append(forkSizesBytes, valueBytes...)...)...)...,
-	), nil
+	// the key may share its backing array with loaded node data so it must not be appended to
+	buf := make([]byte, 0, len(keyBytes)+len(bitMap)+len(forRefBytes)+len(forkSizesBytes)+len(valueBytes))
+	buf = append(buf, keyBytes...)
+	buf = append(buf, bitMap...)
+	buf = append(buf, forRefBytes...)
+	buf = append(buf, forkSizesBytes...)
+	return append(buf, valueBytes...), nil
 }
 
 // UnmarshalBinary makes SwarmNode implement the binary.Unmarshaler interface
@@ -104,10 +116,10 @@ func (n *SwarmNode) UnmarshalBinary(buf []byte) error {
 	bitMap := buf[32:64]
 	frLength := 32
 	c := 0
-	poMap := make([]int8, 0, 32)
+	poMap := make([]int, 0, 32)
 	for i := 0; i < 256; i++ {
 		if bitMap[i/8]&(1<<(7-i%8)) != 0 {
-			poMap = append(poMap, int8(i))
+			poMap = append(poMap, i)
 			c++
 		}
 	}
@@ -117,7 +129,7 @@ func (n *SwarmNode) UnmarshalBinary(buf []byte) error {
 		forkRef := buf[64+i*frLength : 64+(i+1)*frLength]
 		size := binary.BigEndian.Uint32(buf[64+c*frLength+i*4 : 64+c*frLength+(i+1)*4])
 		cn := CNode{
-			At:   int(poMap[i]),
+			At:   poMap[i],
 			Node: &SwarmNode{ref: forkRef, newf: n.newf},
 			size: int(size),
 		}
@@ -1,6 +1,7 @@
 package elements
 
 import (
+	"bytes"
 	"context"
 	"encoding"
 	"encoding/binary"
@@ -71,7 +72,11 @@ func KeyOf(n Node) []byte {
 	if Empty(n) {
 		return nil
 	}
-	return n.Entry().Key()
+	e := n.Entry()
+	if e == nil {
+		return nil
+	}
+	return e.Key()
 }
 
 func Label(k []byte) string {
@@ -121,30 +126,162 @@ func find(ctx context.Context, n CNode, k []byte, mode Mode) (Entry, error) {
 // Iterate is an iterator that walks all the entries subsumed under the given CNode
 // in ascending order of distance from a given key
 func Iterate(ctx context.Context, n CNode, p, k []byte, mode Mode, f func(Entry) (bool, error)) error {
-	m, _ := findNode(ctx, n, p, mode)
+	m, err := findNode(ctx, n, p, mode)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
 	if Empty(m.Node) {
 		return nil
 	}
-	_, err := iterate(ctx, m, k, m.At, mode, f)
+	_, err = iterate(ctx, m, k, 0, mode, f)
+	return err
+}
+
+// Neighbour is an entry along with its proximity order to a key
+type Neighbour struct {
+	Entry Entry
+	PO    int
+}
+
+// Nearest returns at most count entries subsumed under the given CNode closest to a given key
+// in ascending order of distance. Entries with proximity order less than po to the key are left out
+// and forks that can only hold such entries are not unpacked.
+func Nearest(ctx context.Context, n CNode, k []byte, count, po int, mode Mode) ([]Neighbour, error) {
+	if count <= 0 {
+		return nil, nil
+	}
+	var ns []Neighbour
+	_, err := iterate(ctx, n, k, po, mode, func(e Entry) (bool, error) {
+		ns = append(ns, Neighbour{Entry: e, PO: PO(e.Key(), k, 0)})
+		return len(ns) == count, ctx.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ns, nil
+}
+
+// Range walks the entries subsumed under the given CNode with keys in [start, end)
+// in ascending order of keys. A nil start or end leaves the range unbounded on that side.
+// Forks whose key space lies outside the range are not unpacked.
+func Range(ctx context.Context, n CNode, start, end []byte, mode Mode, f func(Entry) (bool, error)) error {
+	_, err := walkRange(ctx, n, start, end, mode, f)
 	return err
 }
 
-func iterate(ctx context.Context, n CNode, k []byte, at int, mode Mode, f func(Entry) (bool, error)) (stop bool, err error) {
+func walkRange(ctx context.Context, n CNode, start, end []byte, mode Mode, f func(Entry) (bool, error)) (stop bool, err error) {
+	if err := ctx.Err(); err != nil {
+		return true, err
+	}
+	if err := mode.Unpack(ctx, n.Node); err != nil {
+		return true, err
+	}
+	if Empty(n.Node) {
+		return false, nil
+	}
+	// keys in a fork are lower than the node's key iff the node's key has a 1 bit at the PO of the fork
+	// and among lower (higher) keys the forks at lower (higher) POs come first
+	k := KeyOf(n.Node)
+	var lower, higher []CNode
+	_ = n.Node.Iterate(n.At+1, func(c CNode) (bool, error) {
+		if !overlaps(k, c.At, start, end) {
+			return false, nil
+		}
+		if k[c.At/8]&(1<<(7-c.At%8)) != 0 {
+			lower = append(lower, c)
+		} else {
+			higher = append(higher, c)
+		}
+		return false, nil
+	})
+	if p, ok := mode.(prefetcher); ok {
+		visits := append([]CNode{}, lower...)
+		for i := len(higher) - 1; i >= 0; i-- {
+			visits = append(visits, higher[i])
+		}
+		p.Prefetch(visits)
+	}
+	for i := 0; !stop && err == nil && i < len(lower); i++ {
+		stop, err = walkRange(ctx, lower[i], start, end, mode, f)
+	}
+	if stop || err != nil {
+		return stop, err
+	}
+	if bytes.Compare(k, start) >= 0 && (end == nil || bytes.Compare(k, end) < 0) {
+		if stop, err = f(n.Node.Entry()); stop || err != nil {
+			return stop, err
+		}
+	}
+	for i := len(higher) - 1; !stop && err == nil && i >= 0; i-- {
+		stop, err = walkRange(ctx, higher[i], start, end, mode, f)
+	}
+	return stop, err
+}
+
+// overlaps tells if the key space of the fork at PO po of a node with key k intersects [start, end)
+func overlaps(k []byte, po int, start, end []byte) bool {
+	lo, hi := forkSpan(k, po)
+	return bytes.Compare(hi, start) >= 0 && (end == nil || bytes.Compare(lo, end) < 0)
+}
+
+// forkSpan returns the lowest and highest keys that the fork at PO po of a node with key k can hold
+func forkSpan(k []byte, po int) (lo, hi []byte) {
+	// the keys of the fork share the first po bits with k and differ at bit po
+	lo = make([]byte, len(k))
+	copy(lo, k[:po/8])
+	lo[po/8] = (k[po/8] ^ byte(1)<<(7-po%8)) & (0xff << (7 - po%8))
+	hi = make([]byte, len(k))
+	copy(hi, lo)
+	hi[po/8] |= 0xff >> (po%8 + 1)
+	for i := po/8 + 1; i < len(hi); i++ {
+		hi[i] = 0xff
+	}
+	return lo, hi
+}
+
+// prefetcher is implemented by modes fetching in the background the forks that a traversal is about to visit,
+// iterations and range scans tell them the forks in the order they visit them
+type prefetcher interface {
+	Prefetch(forks []CNode)
+}
+
+// iterate walks the entries of n in ascending order of distance from k
+// skipping the entries with proximity order to k less than po
+func iterate(ctx context.Context, n CNode, k []byte, po int, mode Mode, f func(Entry) (bool, error)) (stop bool, err error) {
+	if err := mode.Unpack(ctx, n.Node); err != nil {
+		return true, err
+	}
 	if Empty(n.Node) {
 		return false, nil
 	}
 	if n.Size() == 1 {
-		return f(n.Node.Entry())
+		e := n.Node.Entry()
+		if PO(e.Key(), k, 0) < po {
+			return false, nil
+		}
+		return f(e)
 	}
 	var cn CNode
-	po := Compare(n.Node, k, n.At+1)
-	cn = n.Node.Fork(po)
+	at := Compare(n.Node, k, n.At+1)
+	cn = n.Node.Fork(at)
+	forks := append(Slice(n.Node, n.At+1, cn.At), NewAt(cn.At, n.Node), cn)
+	if p, ok := mode.(prefetcher); ok {
+		// the forks within the window of the iteration are fetched while the first one is loaded
+		visits := []CNode{cn}
+		for i := len(forks) - 3; i >= 0 && cn.At >= po && forks[i].At >= po; i-- {
+			visits = append(visits, forks[i])
+		}
+		p.Prefetch(visits)
+	}
 	if err := mode.Unpack(ctx, cn.Node); err != nil {
 		return true, err
 	}
-	forks := append(Slice(n.Node, n.At+1, cn.At), NewAt(cn.At, n.Node), cn)
 	for i := len(forks) - 1; !stop && err == nil && i >= 0; i-- {
-		stop, err = iterate(ctx, forks[i], k, at, mode, f)
+		// entries apart from those in the fork at the PO of k are no closer to k than the PO of their fork
+		if i < len(forks)-1 && forks[i].At < po {
+			break
+		}
+		stop, err = iterate(ctx, forks[i], k, po, mode, f)
 	}
 	return stop, err
 }
@@ -169,7 +306,8 @@ func findNode(ctx context.Context, n CNode, k []byte, mode Mode) (CNode, error)
 		return CNode{}, err
 	}
 	if ok {
-		return NewAt(8*len(k), n.Node), nil
+		// the forks matching the prefix start at its bit length unless the node is a fork further down
+		return NewAt(max(n.At, 8*len(k)-1), n.Node), nil
 	}
 	return findNode(ctx, m, k, mode)
 }
@@ -194,8 +332,8 @@ func FindFork(n CNode, f func(CNode) bool, mode Mode) (m CNode) {
 	_ = n.Node.Iterate(n.At, func(c CNode) (stop bool, err error) {
 		if f == nil {
 			m = c
-		} else {
-			stop = f(m)
+		} else if stop = f(c); stop {
+			m = c
 		}
 		return stop, nil
 	})
@@ -0,0 +1,174 @@
+package elements
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"github.com/ethersphere/proximity-order-trie/pkg/persister"
+)
+
+// prefetchCapacity is the number of fetches held, queued or done but not loaded yet,
+// beyond which the oldest are dropped
+const prefetchCapacity = 1024
+
+var _ persister.LoadSaver = (*Prefetcher)(nil)
+
+// Prefetcher is a LoadSaver wrapper fetching the data of references in the background
+// before they are loaded. Traversals of a SwarmPot visiting several forks of a node, such as iterations,
+// ask for the forks they are about to visit, so that they do not wait for a round-trip for each of them.
+// A fixed number of workers fetch the references in the order they are asked for.
+// A load of a reference waits for its fetch if it is in flight and loads it directly if it is still queued.
+type Prefetcher struct {
+	persister.LoadSaver
+	ctx    context.Context // cancelled on Close
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	cond    *sync.Cond // signals the workers when fetches are queued or the prefetcher is closed
+	closed  bool
+	queue   *list.List // of *fetch not started, the oldest at the front
+	order   *list.List // of *fetch held, the oldest at the front
+	fetches map[[32]byte]*fetch
+}
+
+// fetch is the background load of a reference
+type fetch struct {
+	ref    [32]byte
+	queued *list.Element // element of the queue until a worker starts the fetch
+	held   *list.Element // element of the order
+	done   chan struct{}
+	data   []byte
+	err    error
+}
+
+// NewPrefetcher wraps the LoadSaver with a prefetcher running workers background loads
+// Close stops them
+func NewPrefetcher(ls persister.LoadSaver, workers int) *Prefetcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Prefetcher{
+		LoadSaver: ls,
+		ctx:       ctx,
+		cancel:    cancel,
+		queue:     list.New(),
+		order:     list.New(),
+		fetches:   make(map[[32]byte]*fetch),
+	}
+	p.cond = sync.NewCond(&p.mu)
+	for i := 0; i < max(workers, 1); i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+	return p
+}
+
+// Load returns the data of the reference, taking it from its fetch if it was started
+func (p *Prefetcher) Load(ctx context.Context, reference []byte) ([]byte, error) {
+	if f := p.take(reference); f != nil {
+		select {
+		case <-f.done:
+			if f.err == nil {
+				return f.data, nil
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return p.LoadSaver.Load(ctx, reference)
+}
+
+// Prefetch queues the references to be fetched in the background unless they are held already
+func (p *Prefetcher) Prefetch(references [][]byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	for _, reference := range references {
+		if len(reference) != 32 {
+			continue
+		}
+		if _, ok := p.fetches[[32]byte(reference)]; ok {
+			continue
+		}
+		f := &fetch{ref: [32]byte(reference), done: make(chan struct{})}
+		f.held = p.order.PushBack(f)
+		f.queued = p.queue.PushBack(f)
+		p.fetches[f.ref] = f
+		if p.order.Len() > prefetchCapacity {
+			p.drop(p.order.Front().Value.(*fetch))
+		}
+		p.cond.Signal()
+	}
+}
+
+// Close stops the workers, dropping the fetches queued and cancelling the ones in flight
+// loads go on directly to the wrapped LoadSaver
+func (p *Prefetcher) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	for p.queue.Len() > 0 {
+		p.drop(p.queue.Front().Value.(*fetch))
+	}
+	p.cond.Broadcast()
+	p.mu.Unlock()
+	p.cancel()
+	p.wg.Wait()
+	return nil
+}
+
+// take removes the fetch of the reference and returns it if it was started
+// a fetch still queued is dropped as the caller loads the reference itself sooner
+func (p *Prefetcher) take(reference []byte) *fetch {
+	if len(reference) != 32 {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	f, ok := p.fetches[[32]byte(reference)]
+	if !ok {
+		return nil
+	}
+	started := f.queued == nil
+	p.drop(f)
+	if !started {
+		return nil
+	}
+	return f
+}
+
+// drop removes a fetch from the ones held and from the queue if it was not started
+func (p *Prefetcher) drop(f *fetch) {
+	delete(p.fetches, f.ref)
+	p.order.Remove(f.held)
+	if f.queued != nil {
+		p.queue.Remove(f.queued)
+		f.queued = nil
+	}
+}
+
+// work runs the queued fetches one by one until the prefetcher is closed
+func (p *Prefetcher) work() {
+	defer p.wg.Done()
+	for {
+		p.mu.Lock()
+		for p.queue.Len() == 0 && !p.closed {
+			p.cond.Wait()
+		}
+		if p.closed {
+			p.mu.Unlock()
+			return
+		}
+		f := p.queue.Remove(p.queue.Front()).(*fetch)
+		f.queued = nil
+		p.mu.Unlock()
+
+		f.data, f.err = p.LoadSaver.Load(p.ctx, f.ref[:])
+		close(f.done)
+	}
+}
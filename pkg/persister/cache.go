@@ -0,0 +1,138 @@
+package persister
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// CacheStats are the statistics of a CachingLoadSaver
+type CacheStats struct {
+	Hits      uint64 // loads served from the cache
+	Misses    uint64 // loads passed to the underlying LoadSaver
+	Evictions uint64 // data evicted to keep the cache within its capacity
+	Entries   int    // number of references cached
+	Size      int    // total length of the data cached
+}
+
+// CachingLoadSaver is a read-through cache in front of a LoadSaver keeping the most recently used data
+// up to a total length, evicting the least recently used data beyond it.
+// Saved data is cached as well, as nodes are often loaded again soon after they are saved.
+// It is safe for concurrent use, concurrent loads of the same reference missing the cache load it once
+// and a caller giving up on its context does not fail the load for the others waiting for it.
+// The data is shared by the cache and its callers without copying, so loaded data is read-only
+// and data must not be modified once saved.
+type CachingLoadSaver struct {
+	ls       LoadSaver
+	capacity int
+	group    singleflight.Group
+
+	mu      sync.Mutex
+	lru     *list.List // of *cacheEntry, the most recently used at the front
+	entries map[[32]byte]*list.Element
+	stats   CacheStats
+}
+
+type cacheEntry struct {
+	ref  [32]byte
+	data []byte
+}
+
+// NewCachingLoadSaver wraps the LoadSaver with a cache holding data up to the given total length
+func NewCachingLoadSaver(ls LoadSaver, capacity int) *CachingLoadSaver {
+	return &CachingLoadSaver{
+		ls:       ls,
+		capacity: capacity,
+		lru:      list.New(),
+		entries:  make(map[[32]byte]*list.Element),
+	}
+}
+
+// Load returns the cached data of the reference or loads it from the underlying LoadSaver
+func (c *CachingLoadSaver) Load(ctx context.Context, reference []byte) ([]byte, error) {
+	if len(reference) != 32 {
+		return nil, fmt.Errorf("reference must be 32 bytes, got %d", len(reference))
+	}
+	ref := [32]byte(reference)
+	c.mu.Lock()
+	if e, ok := c.entries[ref]; ok {
+		c.lru.MoveToFront(e)
+		c.stats.Hits++
+		c.mu.Unlock()
+		return e.Value.(*cacheEntry).data, nil
+	}
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	// the load is shared, so it goes on when the caller that started it is cancelled
+	lctx := context.WithoutCancel(ctx)
+	res := c.group.DoChan(string(reference), func() (any, error) {
+		data, err := c.ls.Load(lctx, reference)
+		if err != nil {
+			return nil, err
+		}
+		c.add(ref, data)
+		return data, nil
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-res:
+		if r.Err != nil {
+			return nil, r.Err
+		}
+		return r.Val.([]byte), nil
+	}
+}
+
+// Save saves the data with the underlying LoadSaver and caches it
+func (c *CachingLoadSaver) Save(ctx context.Context, data []byte) ([]byte, error) {
+	reference, err := c.ls.Save(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	if len(reference) == 32 {
+		c.add([32]byte(reference), data)
+	}
+	return reference, nil
+}
+
+// Sync syncs the underlying LoadSaver if it buffers saves
+func (c *CachingLoadSaver) Sync(ctx context.Context) error {
+	return Sync(ctx, c.ls)
+}
+
+// Stats returns the statistics of the cache
+func (c *CachingLoadSaver) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// add caches the data of the reference and evicts the least recently used data beyond the capacity
+// data longer than the capacity is not cached
+func (c *CachingLoadSaver) add(ref [32]byte, data []byte) {
+	if len(data) > c.capacity {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[ref]; ok {
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.entries[ref] = c.lru.PushFront(&cacheEntry{ref: ref, data: data})
+	c.stats.Entries++
+	c.stats.Size += len(data)
+	for c.stats.Size > c.capacity {
+		e := c.lru.Back()
+		evicted := c.lru.Remove(e).(*cacheEntry)
+		delete(c.entries, evicted.ref)
+		c.stats.Entries--
+		c.stats.Size -= len(evicted.data)
+		c.stats.Evictions++
+	}
+}
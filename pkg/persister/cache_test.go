@@ -0,0 +1,177 @@
+package persister_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethersphere/proximity-order-trie/pkg/persister"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+)
+
+// countingLoadSaver counts the loads reaching the wrapped LoadSaver
+type countingLoadSaver struct {
+	persister.LoadSaver
+	loads atomic.Int64
+}
+
+func (c *countingLoadSaver) Load(ctx context.Context, reference []byte) ([]byte, error) {
+	c.loads.Add(1)
+	return c.LoadSaver.Load(ctx, reference)
+}
+
+// blockingLoadSaver blocks loads until released or their context is cancelled
+type blockingLoadSaver struct {
+	countingLoadSaver
+	release chan struct{}
+}
+
+func (b *blockingLoadSaver) Load(ctx context.Context, reference []byte) ([]byte, error) {
+	select {
+	case <-b.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return b.countingLoadSaver.Load(ctx, reference)
+}
+
+func TestCachingLoadSaver(t *testing.T) {
+	ctx := context.Background()
+	save := func(t *testing.T, ls persister.LoadSaver, data ...[]byte) [][]byte {
+		t.Helper()
+		refs := make([][]byte, len(data))
+		for i, d := range data {
+			ref, err := ls.Save(ctx, d)
+			require.NoError(t, err)
+			refs[i] = ref
+		}
+		return refs
+	}
+
+	t.Run("read through", func(t *testing.T) {
+		backend := &countingLoadSaver{LoadSaver: persister.NewInmemLoadSaver()}
+		refs := save(t, backend, []byte("hello"))
+		c := persister.NewCachingLoadSaver(backend, 100)
+		for i := 0; i < 3; i++ {
+			data, err := c.Load(ctx, refs[0])
+			require.NoError(t, err)
+			assert.Equal(t, []byte("hello"), data)
+		}
+		assert.Equal(t, int64(1), backend.loads.Load())
+		assert.Equal(t, persister.CacheStats{Hits: 2, Misses: 1, Entries: 1, Size: 5}, c.Stats())
+
+		_, err := c.Load(ctx, make([]byte, 32))
+		assert.Error(t, err)
+		assert.Equal(t, 1, c.Stats().Entries)
+	})
+
+	t.Run("least recently used evicted", func(t *testing.T) {
+		backend := &countingLoadSaver{LoadSaver: persister.NewInmemLoadSaver()}
+		c := persister.NewCachingLoadSaver(backend, 10)
+		// saved data is cached
+		refs := save(t, c, []byte("aaaa"), []byte("bbbb"))
+		_, err := c.Load(ctx, refs[0])
+		require.NoError(t, err)
+		// caching a third one evicts the least recently used second one
+		refs = append(refs, save(t, c, []byte("cccc"))...)
+		assert.Equal(t, persister.CacheStats{Hits: 1, Evictions: 1, Entries: 2, Size: 8}, c.Stats())
+		for _, ref := range [][]byte{refs[0], refs[2]} {
+			_, err := c.Load(ctx, ref)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, int64(0), backend.loads.Load())
+		_, err = c.Load(ctx, refs[1])
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), backend.loads.Load())
+
+		// data longer than the capacity is not cached
+		long := save(t, c, make([]byte, 11))
+		_, err = c.Load(ctx, long[0])
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), backend.loads.Load())
+		assert.LessOrEqual(t, c.Stats().Size, 10)
+	})
+
+	t.Run("concurrent readers", func(t *testing.T) {
+		backend := &countingLoadSaver{LoadSaver: persister.NewInmemLoadSaver()}
+		var data [][]byte
+		for i := 0; i < 50; i++ {
+			data = append(data, []byte{byte(i), byte(i), byte(i)})
+		}
+		refs := save(t, backend, data...)
+		c := persister.NewCachingLoadSaver(backend, 90)
+		start := make(chan struct{})
+		var eg errgroup.Group
+		for r := 0; r < 8; r++ {
+			eg.Go(func() error {
+				<-start
+				for i := 0; i < 500; i++ {
+					j := (i*7 + r) % len(refs)
+					got, err := c.Load(ctx, refs[j])
+					if err != nil {
+						return err
+					}
+					if !assert.Equal(t, data[j], got) {
+						return nil
+					}
+				}
+				return nil
+			})
+		}
+		close(start)
+		require.NoError(t, eg.Wait())
+		stats := c.Stats()
+		assert.Equal(t, uint64(8*500), stats.Hits+stats.Misses)
+		assert.LessOrEqual(t, stats.Size, 90)
+		assert.Equal(t, 3*stats.Entries, stats.Size)
+	})
+
+	t.Run("cancelled caller", func(t *testing.T) {
+		backend := &blockingLoadSaver{
+			countingLoadSaver: countingLoadSaver{LoadSaver: persister.NewInmemLoadSaver()},
+			release:           make(chan struct{}),
+		}
+		refs := save(t, backend, []byte("hello"))
+		c := persister.NewCachingLoadSaver(backend, 100)
+		cctx, cancel := context.WithCancel(ctx)
+		first := make(chan error, 1)
+		go func() {
+			_, err := c.Load(cctx, refs[0])
+			first <- err
+		}()
+		type result struct {
+			data []byte
+			err  error
+		}
+		second := make(chan result, 1)
+		require.Eventually(t, func() bool { return c.Stats().Misses == 1 }, time.Second, time.Millisecond)
+		go func() {
+			data, err := c.Load(ctx, refs[0])
+			second <- result{data, err}
+		}()
+		require.Eventually(t, func() bool { return c.Stats().Misses == 2 }, time.Second, time.Millisecond)
+
+		// the first caller gives up while the second one waits for the same load
+		cancel()
+		assert.ErrorIs(t, <-first, context.Canceled)
+		close(backend.release)
+		r := <-second
+		require.NoError(t, r.err)
+		assert.Equal(t, []byte("hello"), r.data)
+		assert.Equal(t, int64(1), backend.loads.Load())
+	})
+
+	t.Run("tree", func(t *testing.T) {
+		n := newMockTreeNode(depth, 1)
+		backend := &countingLoadSaver{LoadSaver: newMockLoadSaver()}
+		require.NoError(t, persister.Save(ctx, backend, n))
+		c := persister.NewCachingLoadSaver(backend, 1<<20)
+		sum := loadAndCheck(t, c, &mockTreeNode{ref: n.Reference()}, 1)
+		loadAndCheck(t, c, &mockTreeNode{ref: n.Reference()}, 1)
+		assert.Equal(t, int64(sum), backend.loads.Load())
+		assert.Equal(t, uint64(sum), c.Stats().Hits)
+	})
+}
@@ -0,0 +1,83 @@
+package persister
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+const (
+	// ChunkSize is the maximum payload size of a Swarm chunk
+	ChunkSize = 4096
+	// Branches is the number of child references an intermediate chunk holds
+	Branches = ChunkSize / 32
+)
+
+// Chunk is a chunk of the Swarm file tree that data longer than a chunk is split into
+// the same way as the Bee API stores bytes: data chunks hold the data in order and
+// intermediate chunks hold the references of up to Branches children, their span being
+// the length of the data under them.
+type Chunk struct {
+	Address  []byte   // BMT hash of the span and the payload
+	Span     uint64   // length of the data under the chunk
+	Payload  []byte   // data for data chunks, child references for intermediate chunks
+	Children []*Chunk // children of intermediate chunks
+}
+
+// Split builds the Swarm file tree of the data and returns its root chunk
+// data fitting in one chunk gives a single data chunk
+func Split(data []byte) *Chunk {
+	var level []*Chunk
+	for i := 0; i < len(data) || i == 0; i += ChunkSize {
+		end := min(i+ChunkSize, len(data))
+		level = append(level, newChunk(uint64(end-i), data[i:end], nil))
+	}
+	for len(level) > 1 {
+		var next []*Chunk
+		for i := 0; i < len(level); i += Branches {
+			children := level[i:min(i+Branches, len(level))]
+			// a single child is carried over to the level above without wrapping
+			if len(children) == 1 {
+				next = append(next, children[0])
+				continue
+			}
+			var span uint64
+			payload := make([]byte, 0, len(children)*32)
+			for _, c := range children {
+				span += c.Span
+				payload = append(payload, c.Address...)
+			}
+			next = append(next, newChunk(span, payload, children))
+		}
+		level = next
+	}
+	return level[0]
+}
+
+// newChunk creates a chunk calculating its address
+func newChunk(span uint64, payload []byte, children []*Chunk) *Chunk {
+	hasher := NewBMTHasher()
+	hasher.SetHeaderInt64(int64(span))
+	_, _ = hasher.Write(payload)
+	return &Chunk{
+		Address:  hasher.Sum(nil),
+		Span:     span,
+		Payload:  payload,
+		Children: children,
+	}
+}
+
+// SpanBytes returns the span of the chunk as it is prepended to the payload for hashing
+func (c *Chunk) SpanBytes() []byte {
+	return binary.LittleEndian.AppendUint64(nil, c.Span)
+}
+
+// ChildCapacity returns the length of data the children of a chunk with the given span
+// can hold: only the last child of an intermediate chunk may hold less
+// spans beyond the capacity of the children of a tree of any depth that fits in 64 bits get the deepest one
+func ChildCapacity(span uint64) uint64 {
+	capacity := uint64(ChunkSize)
+	for capacity <= math.MaxUint64/Branches && capacity*Branches < span {
+		capacity *= Branches
+	}
+	return capacity
+}
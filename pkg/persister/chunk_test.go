@@ -0,0 +1,74 @@
+package persister_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math"
+	"testing"
+
+	"github.com/ethersphere/proximity-order-trie/pkg/persister"
+)
+
+// TestSplit checks the addresses of the Swarm file trees against the test vectors of Bee
+func TestSplit(t *testing.T) {
+	for _, tc := range []struct {
+		length  int
+		address string
+	}{
+		{31, "ece86edb20669cc60d142789d464d57bdf5e33cb789d443f608cbd81cfa5697d"},
+		{persister.ChunkSize, "c10090961e7682a10890c334d759a28426647141213abda93b096b892824d2ef"},
+		{persister.ChunkSize + 31, "91699c83ed93a1f87e326a29ccd8cc775323f9e7260035a5f014c975c5f3cd28"},
+		{persister.ChunkSize*2 + 32, "61416726988f77b874435bdd89a419edc3861111884fd60e8adf54e2f299efd6"},
+		{persister.ChunkSize * 128, "3047d841077898c26bbe6be652a2ec590a5d9bd7cd45d290ea42511b48753c09"},
+		{persister.ChunkSize*128 + 31, "e5c76afa931e33ac94bce2e754b1bb6407d07f738f67856783d93934ca8fc576"},
+		{persister.ChunkSize * 129, "b8e1804e37a064d28d161ab5f256cc482b1423d5cd0a6b30fde7b0f51ece9199"},
+		{persister.ChunkSize * 130, "59de730bf6c67a941f3b2ffa2f920acfaa1713695ad5deea12b4a121e5f23fa1"},
+	} {
+		data := make([]byte, tc.length)
+		for i := range data {
+			data[i] = byte(i % 255)
+		}
+		root := persister.Split(data)
+		if got := hex.EncodeToString(root.Address); got != tc.address {
+			t.Errorf("length %d: expected address %s, got %s", tc.length, tc.address, got)
+		}
+		if root.Span != uint64(tc.length) {
+			t.Errorf("length %d: expected span %d, got %d", tc.length, tc.length, root.Span)
+		}
+		if got := join(root); !bytes.Equal(got, data) {
+			t.Errorf("length %d: joined data mismatch", tc.length)
+		}
+	}
+}
+
+// join concatenates the payloads of the data chunks of the tree
+func join(c *persister.Chunk) []byte {
+	if len(c.Children) == 0 {
+		return c.Payload
+	}
+	var data []byte
+	for _, child := range c.Children {
+		data = append(data, join(child)...)
+	}
+	return data
+}
+
+func TestChildCapacity(t *testing.T) {
+	for _, tc := range []struct {
+		span     uint64
+		capacity uint64
+	}{
+		{1, persister.ChunkSize},
+		{persister.ChunkSize * persister.Branches, persister.ChunkSize},
+		{persister.ChunkSize*persister.Branches + 1, persister.ChunkSize * persister.Branches},
+		{1 << 61, 1 << 54},
+		// spans whose tree would overflow get the children of the deepest tree that fits in 64 bits
+		{1<<61 + 1, 1 << 61},
+		{1 << 62, 1 << 61},
+		{math.MaxUint64, 1 << 61},
+	} {
+		if got := persister.ChildCapacity(tc.span); got != tc.capacity {
+			t.Errorf("span %d: expected capacity %d, got %d", tc.span, tc.capacity, got)
+		}
+	}
+}
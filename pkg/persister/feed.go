@@ -0,0 +1,198 @@
+package persister
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// ErrFeedNotFound is returned when a feed has no update yet
+var ErrFeedNotFound = errors.New("feed has no update")
+
+// Signer signs the digests of single owner chunks the way Bee verifies them.
+// It is the Sign method of the crypto.Signer of Bee, which can be used as is.
+type Signer interface {
+	// Sign returns the 65 bytes r || s || v secp256k1 signature of the Ethereum signed message hash
+	// of the digest, v being 27 or 28
+	Sign(digest []byte) ([]byte, error)
+}
+
+// SwarmFeed is a Swarm sequence feed of references: a stable address given by an owner and a topic
+// whose updates are single owner chunks signed by the owner, each holding a reference, usually the root of a POT.
+// Updates are uploaded and the latest one is looked up through the Bee API.
+// A feed has a single publisher as concurrent publishers would write the same update indexes.
+type SwarmFeed struct {
+	sls    *SwarmLoadSaver
+	owner  []byte
+	topic  []byte
+	signer Signer
+
+	mu    sync.Mutex
+	next  uint64 // index of the next update of the publisher
+	known bool   // the publisher knows the next index
+}
+
+// FeedTopic returns the topic of a feed named by a string, its Keccak-256 hash
+func FeedTopic(name string) []byte {
+	return keccak256([]byte(name))
+}
+
+// Feed returns the feed of the owner address and the 32 bytes topic for reading its updates
+func (sls *SwarmLoadSaver) Feed(owner, topic []byte) *SwarmFeed {
+	return &SwarmFeed{sls: sls, owner: owner, topic: topic}
+}
+
+// FeedPublisher returns the feed of the owner address and the topic for publishing its updates with the postage ID,
+// signed by the signer of the owner. With a crypto.Signer of Bee, the owner is its EthereumAddress.
+func (sls *SwarmLoadSaver) FeedPublisher(owner []byte, signer Signer, topic []byte) (*SwarmFeed, error) {
+	if len(owner) != 20 {
+		return nil, fmt.Errorf("owner must be 20 bytes, got %d", len(owner))
+	}
+	if signer == nil {
+		return nil, errors.New("feed publisher has no signer")
+	}
+	return &SwarmFeed{sls: sls, owner: owner, topic: topic, signer: signer}, nil
+}
+
+// Owner returns the address of the owner of the feed
+func (f *SwarmFeed) Owner() []byte {
+	return f.owner
+}
+
+// Topic returns the topic of the feed
+func (f *SwarmFeed) Topic() []byte {
+	return f.topic
+}
+
+// Latest returns the reference of the latest update of the feed
+// or ErrFeedNotFound if the feed has no update
+func (f *SwarmFeed) Latest(ctx context.Context) ([]byte, error) {
+	reference, _, err := f.latest(ctx)
+	return reference, err
+}
+
+// Publish publishes the reference as the next update of the feed
+func (f *SwarmFeed) Publish(ctx context.Context, reference []byte) error {
+	if f.signer == nil {
+		return errors.New("feed has no signer")
+	}
+	if len(reference) != 32 {
+		return fmt.Errorf("reference must be 32 bytes, got %d", len(reference))
+	}
+	if len(f.sls.postageID) != 32 {
+		return fmt.Errorf("postage ID is not correct. Its length is %d", len(f.sls.postageID))
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.known {
+		_, next, err := f.latest(ctx)
+		if err != nil && !errors.Is(err, ErrFeedNotFound) {
+			return err
+		}
+		f.next, f.known = next, true
+	}
+
+	// the update is a single owner chunk wrapping the content addressed chunk of the reference
+	id := f.id(f.next)
+	data := make([]byte, 8, 8+len(reference))
+	binary.LittleEndian.PutUint64(data, uint64(len(reference)))
+	data = append(data, reference...)
+	signature, err := f.signer.Sign(keccak256(id, Split(reference).Address))
+	if err != nil {
+		return fmt.Errorf("failed to sign feed update: %w", err)
+	}
+
+	u, err := f.sls.getBeeAPIURL()
+	if err != nil {
+		return fmt.Errorf("invalid bee API URL: %w", err)
+	}
+	u.Path = fmt.Sprintf("/soc/%x/%x", f.owner, id)
+	u.RawQuery = "sig=" + hex.EncodeToString(signature)
+	req, err := http.NewRequestWithContext(ctx, "POST", u.String(), bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Swarm-Postage-Batch-Id", fmt.Sprintf("%x", f.sls.postageID))
+
+	resp, err := f.sls.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to publish feed update to swarm: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		// the index may have been taken by another publisher, so it is looked up again next time
+		f.known = false
+		return fmt.Errorf("swarm returned status %d", resp.StatusCode)
+	}
+	f.next++
+	return nil
+}
+
+// id returns the identifier of the single owner chunk of the update at the index
+func (f *SwarmFeed) id(index uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, index)
+	return keccak256(f.topic, b)
+}
+
+// keccak256 returns the Keccak-256 hash of the concatenation of the values
+func keccak256(values ...[]byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	for _, v := range values {
+		h.Write(v)
+	}
+	return h.Sum(nil)
+}
+
+// latest looks up the latest update of the feed and returns its reference and the index of the next update
+func (f *SwarmFeed) latest(ctx context.Context) ([]byte, uint64, error) {
+	if len(f.owner) != 20 {
+		return nil, 0, fmt.Errorf("owner must be 20 bytes, got %d", len(f.owner))
+	}
+	if len(f.topic) != 32 {
+		return nil, 0, fmt.Errorf("topic must be 32 bytes, got %d", len(f.topic))
+	}
+	u, err := f.sls.getBeeAPIURL()
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid bee API URL: %w", err)
+	}
+	u.Path = fmt.Sprintf("/feeds/%x/%x", f.owner, f.topic)
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := f.sls.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to look up feed in swarm: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, 0, ErrFeedNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("swarm returned status %d", resp.StatusCode)
+	}
+
+	reference, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if len(reference) != 32 {
+		return nil, 0, fmt.Errorf("invalid feed update: expected a 32 bytes reference, got %d bytes", len(reference))
+	}
+	next, err := hex.DecodeString(resp.Header.Get("Swarm-Feed-Index-Next"))
+	if err != nil || len(next) != 8 {
+		return nil, 0, fmt.Errorf("invalid next feed index %q", resp.Header.Get("Swarm-Feed-Index-Next"))
+	}
+	return reference, binary.BigEndian.Uint64(next), nil
+}
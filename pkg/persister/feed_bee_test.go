@@ -0,0 +1,59 @@
+//go:build bee_crypto
+
+package persister_test
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/ethersphere/bee/v2/pkg/crypto"
+	"github.com/ethersphere/proximity-order-trie/pkg/persister"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the crypto.Signer of Bee is a Signer
+var _ persister.Signer = crypto.Signer(nil)
+
+// testPrivateKey is the key of the Bee signer and single owner chunk test vectors, its address being testOwner
+const testPrivateKey = "634fb5a872396d9693e5c9f9d7233cfa93f395c093371017ff44aa9ae6564cdd"
+
+// TestBeeSigner checks feed updates signed by the signer of Bee against the test vectors of Bee.
+// It needs the dependencies of the crypto package of Bee: go test -tags bee_crypto ./pkg/persister
+func TestBeeSigner(t *testing.T) {
+	key, err := crypto.DecodeSecp256k1PrivateKey(decodeHex(t, testPrivateKey))
+	require.NoError(t, err)
+	signer := crypto.NewDefaultSigner(key)
+	owner, err := signer.EthereumAddress()
+	require.NoError(t, err)
+	assert.Equal(t, testOwner, hex.EncodeToString(owner.Bytes()))
+
+	t.Run("deterministic", func(t *testing.T) {
+		sig, err := signer.Sign(decodeHex(t, "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"))
+		require.NoError(t, err)
+		assert.Equal(t, "336d24afef78c5883b96ad9a62552a8db3d236105cb059ddd04dc49680869dc16234f6852c277087f025d4114c4fac6b40295ecffd1194a84cdb91bd571769491b", hex.EncodeToString(sig))
+	})
+
+	t.Run("single owner chunk", func(t *testing.T) {
+		// the chunk of id 0 wrapping foo
+		id := make([]byte, 32)
+		sig, err := signer.Sign(keccak256(id, persister.Split([]byte("foo")).Address))
+		require.NoError(t, err)
+		assert.Equal(t, "5acd384febc133b7b245e5ddc62d82d2cded9182d2716126cd8844509af65a053deb418208027f548e3e88343af6f84a8772fb3cebc0a1833a0ea7ec0c1348311b", hex.EncodeToString(sig))
+		assert.Equal(t, "9d453ebb73b2fedaaf44ceddcf7a0aa37f3e3d6453fea5841c31f0ea6d61dc85", hex.EncodeToString(keccak256(id, owner.Bytes())))
+	})
+
+	t.Run("feed", func(t *testing.T) {
+		ctx := context.Background()
+		sls := persister.NewSwarmLoadSaver(newMockBee(t).URL, make([]byte, 32))
+		topic := persister.FeedTopic("index")
+		publisher, err := sls.FeedPublisher(owner.Bytes(), signer, topic)
+		require.NoError(t, err)
+		reference := append(make([]byte, 31), 1)
+		require.NoError(t, publisher.Publish(ctx, reference))
+		latest, err := sls.Feed(owner.Bytes(), topic).Latest(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, reference, latest)
+	})
+}
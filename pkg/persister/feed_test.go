@@ -0,0 +1,163 @@
+package persister_test
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethersphere/proximity-order-trie/pkg/persister"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/sha3"
+)
+
+// testOwner is the address of the key of the Bee test vectors, see feed_bee_test.go
+const testOwner = "8d3766440f0d7b949a5e32995d09619a7f86e632"
+
+func keccak256(values ...[]byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	for _, v := range values {
+		h.Write(v)
+	}
+	return h.Sum(nil)
+}
+
+func decodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
+
+// testSigner records the digests it signs and returns placeholder signatures,
+// which the mock Bee API does not verify
+type testSigner struct {
+	mu      sync.Mutex
+	digests [][]byte
+}
+
+func (s *testSigner) Sign(digest []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.digests = append(s.digests, append([]byte{}, digest...))
+	sig := make([]byte, 65)
+	sig[64] = 27
+	return sig, nil
+}
+
+// mockBee serves the single owner chunk upload and the sequence feed lookup of the Bee API
+type mockBee struct {
+	t    *testing.T
+	mu   sync.Mutex
+	socs map[string][]byte // wrapped chunk data by single owner chunk address
+}
+
+func newMockBee(t *testing.T) *httptest.Server {
+	b := &mockBee{t: t, socs: make(map[string][]byte)}
+	s := httptest.NewServer(b)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (b *mockBee) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if len(parts) != 3 {
+		http.NotFound(w, r)
+		return
+	}
+	owner, err1 := hex.DecodeString(parts[1])
+	second, err2 := hex.DecodeString(parts[2])
+	if err1 != nil || err2 != nil {
+		http.Error(w, "invalid path params", http.StatusBadRequest)
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case r.Method == http.MethodPost && parts[0] == "soc":
+		data, _ := io.ReadAll(r.Body)
+		sig, err := hex.DecodeString(r.URL.Query().Get("sig"))
+		if err != nil || len(sig) != 65 || r.Header.Get("Swarm-Postage-Batch-Id") == "" || len(data) < 8 ||
+			binary.LittleEndian.Uint64(data) != uint64(len(data)-8) {
+			http.Error(w, "invalid chunk", http.StatusBadRequest)
+			return
+		}
+		b.socs[string(keccak256(second, owner))] = data
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodGet && parts[0] == "feeds":
+		var latest []byte
+		var next uint64
+		for ; ; next++ {
+			index := binary.BigEndian.AppendUint64(nil, next)
+			data, ok := b.socs[string(keccak256(keccak256(second, index), owner))]
+			if !ok {
+				break
+			}
+			latest = data
+		}
+		if latest == nil {
+			http.Error(w, "no update found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Swarm-Feed-Index-Next", fmt.Sprintf("%016x", next))
+		_, _ = w.Write(latest[8:])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestSwarmFeed(t *testing.T) {
+	ctx := context.Background()
+	bee := newMockBee(t)
+	postageID := make([]byte, 32)
+	sls := persister.NewSwarmLoadSaver(bee.URL, postageID)
+	signer := &testSigner{}
+	topic := persister.FeedTopic("index")
+	feed := sls.Feed(decodeHex(t, testOwner), topic)
+
+	_, err := feed.Latest(ctx)
+	assert.ErrorIs(t, err, persister.ErrFeedNotFound)
+
+	_, err = sls.FeedPublisher(decodeHex(t, testOwner)[1:], signer, topic)
+	assert.Error(t, err, "invalid owner")
+	publisher, err := sls.FeedPublisher(decodeHex(t, testOwner), signer, topic)
+	require.NoError(t, err)
+	assert.Equal(t, testOwner, hex.EncodeToString(publisher.Owner()))
+	assert.Error(t, feed.Publish(ctx, make([]byte, 32)), "no signer")
+
+	ref := func(i byte) []byte {
+		return append(make([]byte, 31), i)
+	}
+	for i := byte(1); i <= 3; i++ {
+		require.NoError(t, publisher.Publish(ctx, ref(i)))
+		latest, err := feed.Latest(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, ref(i), latest)
+	}
+	// the digest signed for the update at index i is the hash of the identifier of the single owner chunk,
+	// keccak256(topic || i as 8 bytes big-endian), and the address of the chunk wrapping the reference
+	require.Len(t, signer.digests, 3)
+	for i, digest := range signer.digests {
+		id := keccak256(topic, binary.BigEndian.AppendUint64(nil, uint64(i)))
+		assert.Equal(t, keccak256(id, persister.Split(ref(byte(i+1))).Address), digest)
+	}
+
+	// a new publisher of the feed continues after the latest update
+	publisher, err = sls.FeedPublisher(decodeHex(t, testOwner), signer, topic)
+	require.NoError(t, err)
+	require.NoError(t, publisher.Publish(ctx, ref(4)))
+	latest, err := feed.Latest(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, ref(4), latest)
+
+	// other topics are not affected
+	_, err = sls.Feed(decodeHex(t, testOwner), persister.FeedTopic("other")).Latest(ctx)
+	assert.ErrorIs(t, err, persister.ErrFeedNotFound)
+}
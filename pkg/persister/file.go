@@ -0,0 +1,120 @@
+package persister
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrCorruptData is returned when the data stored under a reference does not hash to it
+var ErrCorruptData = errors.New("data does not match reference")
+
+// FileLoadSaver stores data in a directory, each in a file named after its reference,
+// the Swarm reference of the data, so that the data can be uploaded to Swarm later under the same references.
+// Files are sharded into subdirectories named after the first byte of the reference.
+//
+// The layout is dir/ab/abcd…, the shard being the first 2 and the file name all 64 lowercase hex digits
+// of the reference, and a file holds the raw data without header, the reference being that of the Swarm file tree
+// of the data as Split calculates it. Files named .tmp-* in the shards are left by saves interrupted by a crash
+// and can be removed.
+type FileLoadSaver struct {
+	dir string
+}
+
+// NewFileLoadSaver creates a FileLoadSaver storing data under dir, creating it if it does not exist
+// data saved by another FileLoadSaver under the same directory is loaded
+func NewFileLoadSaver(dir string) (*FileLoadSaver, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	return &FileLoadSaver{dir: dir}, nil
+}
+
+// path returns the shard directory and the path of the file of the reference
+func (ls *FileLoadSaver) path(reference []byte) (string, string) {
+	name := hex.EncodeToString(reference)
+	shard := filepath.Join(ls.dir, name[:2])
+	return shard, filepath.Join(shard, name)
+}
+
+// Load reads the data of the reference and checks that it hashes to the reference
+func (ls *FileLoadSaver) Load(ctx context.Context, reference []byte) ([]byte, error) {
+	if len(reference) != 32 {
+		return nil, fmt.Errorf("reference must be 32 bytes, got %d", len(reference))
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	_, path := ls.path(reference)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("reference not found: %w", err)
+		}
+		return nil, fmt.Errorf("failed to read data: %w", err)
+	}
+	if !bytes.Equal(Split(data).Address, reference) {
+		return nil, fmt.Errorf("%w: %x", ErrCorruptData, reference)
+	}
+	return data, nil
+}
+
+// Save writes the data to a temporary file, syncs it and renames it to the file of its reference,
+// so the file of a reference either holds the whole data or does not exist even if the process crashes
+func (ls *FileLoadSaver) Save(ctx context.Context, data []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	reference := Split(data).Address
+	shard, path := ls.path(reference)
+	if err := os.MkdirAll(shard, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create shard directory: %w", err)
+	}
+	f, err := os.CreateTemp(shard, ".tmp-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %w", err)
+	}
+	tmp := f.Name()
+	if err := writeSync(f, data); err != nil {
+		_ = os.Remove(tmp)
+		return nil, fmt.Errorf("failed to write data: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return nil, fmt.Errorf("failed to rename file: %w", err)
+	}
+	// the rename is durable once the directory holding the file is synced
+	if err := syncDir(shard); err != nil {
+		return nil, fmt.Errorf("failed to sync shard directory: %w", err)
+	}
+	return reference, nil
+}
+
+// writeSync writes the data to the file, syncs it to the disk and closes it
+func writeSync(f *os.File, data []byte) error {
+	_, err := f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// syncDir syncs the entries of a directory to the disk
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
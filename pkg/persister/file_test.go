@@ -0,0 +1,85 @@
+package persister_test
+
+import (
+	"context"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethersphere/proximity-order-trie/pkg/persister"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileLoadSaver(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	ls, err := persister.NewFileLoadSaver(dir)
+	require.NoError(t, err)
+
+	t.Run("save and load", func(t *testing.T) {
+		for _, length := range []int{0, 31, persister.ChunkSize, persister.ChunkSize*2 + 32} {
+			data := make([]byte, length)
+			for i := range data {
+				data[i] = byte(i % 255)
+			}
+			ref, err := ls.Save(ctx, data)
+			require.NoError(t, err)
+			// the reference is the one Swarm gives to the data
+			assert.Equal(t, persister.Split(data).Address, ref)
+			name := hex.EncodeToString(ref)
+			assert.FileExists(t, filepath.Join(dir, name[:2], name))
+
+			loaded, err := ls.Load(ctx, ref)
+			require.NoError(t, err)
+			assert.Equal(t, data, loaded)
+
+			// saving the same data again keeps a single file
+			again, err := ls.Save(ctx, data)
+			require.NoError(t, err)
+			assert.Equal(t, ref, again)
+			entries, err := os.ReadDir(filepath.Join(dir, name[:2]))
+			require.NoError(t, err)
+			assert.Len(t, entries, 1)
+		}
+	})
+
+	t.Run("reopen", func(t *testing.T) {
+		n := newMockTreeNode(depth, 1)
+		require.NoError(t, persister.Save(ctx, ls, n))
+
+		reopened, err := persister.NewFileLoadSaver(dir)
+		require.NoError(t, err)
+		sum := 1
+		base := 1
+		for i := 0; i < depth; i++ {
+			base *= branches
+			sum += base
+		}
+		assert.Equal(t, sum, loadAndCheck(t, reopened, &mockTreeNode{ref: n.Reference()}, 1))
+	})
+
+	t.Run("corrupt data", func(t *testing.T) {
+		ref, err := ls.Save(ctx, []byte("hello"))
+		require.NoError(t, err)
+		name := hex.EncodeToString(ref)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name[:2], name), []byte("hellO"), 0o644))
+		_, err = ls.Load(ctx, ref)
+		assert.ErrorIs(t, err, persister.ErrCorruptData)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := ls.Load(ctx, make([]byte, 32))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+		_, err = ls.Load(ctx, make([]byte, 31))
+		assert.Error(t, err)
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err := ls.Save(ctx, []byte("hello"))
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
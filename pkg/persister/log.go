@@ -0,0 +1,233 @@
+package persister
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+)
+
+// logHeaderSize is the length of the reference and the data length preceding the data of a record
+const logHeaderSize = 32 + 4
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// LogLoadSaver stores data in a single append-only log file, each record being the reference,
+// the length of the data as 4 bytes big-endian, the data and the CRC-32C of all of these.
+// The offsets of the records are indexed in memory and rebuilt from the log on open.
+//
+// Saves are buffered and made durable together by Sync, which SwarmPot calls once the nodes of an update, a commit
+// or a save are all saved, before any reference to them is handed out.
+// Records are appended in the order of the saves, children before their parents, and opening the log keeps
+// the records before the first torn or corrupt one, so a crash loses the last saves only and
+// every reference in the log is to data in the log.
+type LogLoadSaver struct {
+	mu      sync.RWMutex
+	file    *os.File
+	w       *bufio.Writer
+	index   map[[32]byte]logRecord
+	size    int64 // length of the log including the buffered records
+	flushed int64 // length of the log written to the file
+	synced  int64 // length of the log synced to the disk
+}
+
+// logRecord is the location of the data of a record in the log
+type logRecord struct {
+	offset int64
+	length uint32
+}
+
+// NewLogLoadSaver opens the log at path, creating it if it does not exist, and indexes its records
+// a torn or corrupt tail left by a crash is truncated
+func NewLogLoadSaver(path string) (*LogLoadSaver, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log: %w", err)
+	}
+	ls := &LogLoadSaver{
+		file:  file,
+		index: make(map[[32]byte]logRecord),
+	}
+	if err := ls.recover(); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	ls.w = bufio.NewWriterSize(file, 1<<20)
+	return ls, nil
+}
+
+// recover indexes the valid records of the log and truncates it after the last one
+func (ls *LogLoadSaver) recover() error {
+	stat, err := ls.file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat log: %w", err)
+	}
+	r := bufio.NewReaderSize(ls.file, 1<<20)
+	var offset int64
+	for {
+		ref, data, err := readLogRecord(r)
+		if err != nil {
+			break
+		}
+		ls.index[[32]byte(ref)] = logRecord{offset: offset + logHeaderSize, length: uint32(len(data))}
+		offset += logHeaderSize + int64(len(data)) + 4
+	}
+	if offset < stat.Size() {
+		if err := ls.file.Truncate(offset); err != nil {
+			return fmt.Errorf("failed to truncate log: %w", err)
+		}
+		if err := ls.file.Sync(); err != nil {
+			return fmt.Errorf("failed to sync log: %w", err)
+		}
+	}
+	if _, err := ls.file.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek log: %w", err)
+	}
+	ls.size, ls.flushed, ls.synced = offset, offset, offset
+	return nil
+}
+
+// readLogRecord reads the next record of the log and checks its CRC
+func readLogRecord(r io.Reader) (ref, data []byte, err error) {
+	header := make([]byte, logHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[32:])
+	if length > 1<<30 {
+		return nil, nil, errCorruptRecord
+	}
+	body := make([]byte, int(length)+4)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, nil, err
+	}
+	crc := crc32.Update(crc32.Checksum(header, crcTable), crcTable, body[:length])
+	if crc != binary.BigEndian.Uint32(body[length:]) {
+		return nil, nil, errCorruptRecord
+	}
+	return header[:32], body[:length], nil
+}
+
+var errCorruptRecord = errors.New("corrupt log record")
+
+// Load reads the data of the reference from the log
+func (ls *LogLoadSaver) Load(ctx context.Context, reference []byte) ([]byte, error) {
+	if len(reference) != 32 {
+		return nil, fmt.Errorf("reference must be 32 bytes, got %d", len(reference))
+	}
+	ls.mu.RLock()
+	rec, ok := ls.index[[32]byte(reference)]
+	buffered := rec.offset+int64(rec.length) > ls.flushed
+	file := ls.file
+	ls.mu.RUnlock()
+	if file == nil {
+		return nil, os.ErrClosed
+	}
+	if !ok {
+		return nil, fmt.Errorf("reference not found: %w", os.ErrNotExist)
+	}
+	if buffered {
+		if err := ls.flush(); err != nil {
+			return nil, err
+		}
+	}
+	// the CRC is read along with the data to check it
+	buf := make([]byte, logHeaderSize+int(rec.length)+4)
+	if _, err := file.ReadAt(buf, rec.offset-logHeaderSize); err != nil {
+		return nil, fmt.Errorf("failed to read log: %w", err)
+	}
+	crc := crc32.Checksum(buf[:len(buf)-4], crcTable)
+	if crc != binary.BigEndian.Uint32(buf[len(buf)-4:]) {
+		return nil, fmt.Errorf("%w: %x", ErrCorruptData, reference)
+	}
+	return buf[logHeaderSize : len(buf)-4], nil
+}
+
+// Save appends a record of the data to the log unless it is already there
+// the record is durable once Sync returns
+func (ls *LogLoadSaver) Save(ctx context.Context, data []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(data) > 1<<30 {
+		return nil, fmt.Errorf("data too long: %d", len(data))
+	}
+	ref := [32]byte(Split(data).Address)
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if ls.file == nil {
+		return nil, os.ErrClosed
+	}
+	if _, ok := ls.index[ref]; ok {
+		return ref[:], nil
+	}
+	record := make([]byte, logHeaderSize, logHeaderSize+len(data)+4)
+	copy(record, ref[:])
+	binary.BigEndian.PutUint32(record[32:], uint32(len(data)))
+	record = append(record, data...)
+	record = binary.BigEndian.AppendUint32(record, crc32.Checksum(record, crcTable))
+	if _, err := ls.w.Write(record); err != nil {
+		return nil, fmt.Errorf("failed to write log: %w", err)
+	}
+	ls.index[ref] = logRecord{offset: ls.size + logHeaderSize, length: uint32(len(data))}
+	ls.size += int64(len(record))
+	return ref[:], nil
+}
+
+// flush writes the buffered records to the file
+func (ls *LogLoadSaver) flush() error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	return ls.flushLocked()
+}
+
+func (ls *LogLoadSaver) flushLocked() error {
+	if ls.flushed == ls.size {
+		return nil
+	}
+	if err := ls.w.Flush(); err != nil {
+		return fmt.Errorf("failed to write log: %w", err)
+	}
+	ls.flushed = ls.size
+	return nil
+}
+
+// Sync makes the records saved so far durable with a single fsync
+func (ls *LogLoadSaver) Sync(ctx context.Context) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if ls.file == nil {
+		return os.ErrClosed
+	}
+	if ls.synced == ls.size {
+		return nil
+	}
+	if err := ls.flushLocked(); err != nil {
+		return err
+	}
+	if err := ls.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync log: %w", err)
+	}
+	ls.synced = ls.size
+	return nil
+}
+
+// Close syncs the log and closes its file
+func (ls *LogLoadSaver) Close() error {
+	err := ls.Sync(context.Background())
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if ls.file == nil {
+		return err
+	}
+	if cerr := ls.file.Close(); err == nil {
+		err = cerr
+	}
+	ls.file = nil
+	return err
+}
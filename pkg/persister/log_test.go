@@ -0,0 +1,158 @@
+package persister_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethersphere/proximity-order-trie/pkg/persister"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogLoadSaver(t *testing.T) {
+	ctx := context.Background()
+	sum := 1
+	base := 1
+	for i := 0; i < depth; i++ {
+		base *= branches
+		sum += base
+	}
+	// saveTree saves a tree of mock nodes and syncs the log the way SwarmPot.Save does
+	saveTree := func(t *testing.T, ls *persister.LogLoadSaver, val int) []byte {
+		t.Helper()
+		n := newMockTreeNode(depth, val)
+		require.NoError(t, persister.Save(ctx, ls, n))
+		require.NoError(t, persister.Sync(ctx, ls))
+		return n.Reference()
+	}
+	fileSize := func(t *testing.T, path string) int64 {
+		t.Helper()
+		stat, err := os.Stat(path)
+		require.NoError(t, err)
+		return stat.Size()
+	}
+
+	t.Run("save and load", func(t *testing.T) {
+		ls, err := persister.NewLogLoadSaver(filepath.Join(t.TempDir(), "log"))
+		require.NoError(t, err)
+		defer ls.Close()
+		for _, length := range []int{0, 31, persister.ChunkSize*2 + 32} {
+			data := make([]byte, length)
+			for i := range data {
+				data[i] = byte(i % 255)
+			}
+			ref, err := ls.Save(ctx, data)
+			require.NoError(t, err)
+			assert.Equal(t, persister.Split(data).Address, ref)
+			// buffered records are loaded too
+			loaded, err := ls.Load(ctx, ref)
+			require.NoError(t, err)
+			assert.Equal(t, data, loaded)
+		}
+		_, err = ls.Load(ctx, make([]byte, 32))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("reopen", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "log")
+		ls, err := persister.NewLogLoadSaver(path)
+		require.NoError(t, err)
+		ref := saveTree(t, ls, 1)
+		size := fileSize(t, path)
+		// saving the same tree again does not grow the log
+		saveTree(t, ls, 1)
+		assert.Equal(t, size, fileSize(t, path))
+		require.NoError(t, ls.Close())
+
+		reopened, err := persister.NewLogLoadSaver(path)
+		require.NoError(t, err)
+		defer reopened.Close()
+		assert.Equal(t, sum, loadAndCheck(t, reopened, &mockTreeNode{ref: ref}, 1))
+	})
+
+	t.Run("crash before sync", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "log")
+		ls, err := persister.NewLogLoadSaver(path)
+		require.NoError(t, err)
+		ref := saveTree(t, ls, 1)
+		size := fileSize(t, path)
+		// the saves after the sync are still buffered when the process crashes
+		require.NoError(t, persister.Save(ctx, ls, newMockTreeNode(depth, 2)))
+
+		reopened, err := persister.NewLogLoadSaver(path)
+		require.NoError(t, err)
+		defer reopened.Close()
+		assert.Equal(t, size, fileSize(t, path))
+		assert.Equal(t, sum, loadAndCheck(t, reopened, &mockTreeNode{ref: ref}, 1))
+	})
+
+	t.Run("torn tail", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "log")
+		ls, err := persister.NewLogLoadSaver(path)
+		require.NoError(t, err)
+		ref := saveTree(t, ls, 1)
+		size := fileSize(t, path)
+		lost := saveTree(t, ls, 2)
+		require.NoError(t, ls.Close())
+		// the last record is written partially
+		require.NoError(t, os.Truncate(path, fileSize(t, path)-3))
+
+		reopened, err := persister.NewLogLoadSaver(path)
+		require.NoError(t, err)
+		assert.Greater(t, fileSize(t, path), size)
+		assert.Equal(t, sum, loadAndCheck(t, reopened, &mockTreeNode{ref: ref}, 1))
+		// the root of the torn save is lost, the log is appended after the records kept
+		_, err = reopened.Load(ctx, lost)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+		lost = saveTree(t, reopened, 2)
+		require.NoError(t, reopened.Close())
+
+		reopened, err = persister.NewLogLoadSaver(path)
+		require.NoError(t, err)
+		defer reopened.Close()
+		assert.Equal(t, sum, loadAndCheck(t, reopened, &mockTreeNode{ref: lost}, 2))
+	})
+
+	t.Run("corrupt record", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "log")
+		ls, err := persister.NewLogLoadSaver(path)
+		require.NoError(t, err)
+		ref := saveTree(t, ls, 1)
+		size := fileSize(t, path)
+		corrupt, err := ls.Save(ctx, []byte("hello"))
+		require.NoError(t, err)
+		after := saveTree(t, ls, 2)
+
+		f, err := os.OpenFile(path, os.O_RDWR, 0)
+		require.NoError(t, err)
+		_, err = f.WriteAt([]byte("hellO"), size+36)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+		_, err = ls.Load(ctx, corrupt)
+		assert.ErrorIs(t, err, persister.ErrCorruptData)
+		require.NoError(t, ls.Close())
+
+		// the records after the corrupt one are dropped along with it
+		reopened, err := persister.NewLogLoadSaver(path)
+		require.NoError(t, err)
+		defer reopened.Close()
+		assert.Equal(t, size, fileSize(t, path))
+		assert.Equal(t, sum, loadAndCheck(t, reopened, &mockTreeNode{ref: ref}, 1))
+		_, err = reopened.Load(ctx, after)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		ls, err := persister.NewLogLoadSaver(filepath.Join(t.TempDir(), "log"))
+		require.NoError(t, err)
+		ref, err := ls.Save(ctx, []byte("hello"))
+		require.NoError(t, err)
+		require.NoError(t, ls.Close())
+		_, err = ls.Load(ctx, ref)
+		assert.ErrorIs(t, err, os.ErrClosed)
+		_, err = ls.Save(ctx, []byte("hello"))
+		assert.ErrorIs(t, err, os.ErrClosed)
+	})
+}
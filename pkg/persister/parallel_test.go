@@ -0,0 +1,107 @@
+package persister_test
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethersphere/proximity-order-trie/pkg/persister"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowLoadSaver checks that the children of saved nodes are saved before them
+// and records the saves in flight and the goroutines running
+type slowLoadSaver struct {
+	*mockLoadSaver
+	delay         time.Duration
+	failAt        int64 // the save failing, none if 0
+	saves         atomic.Int64
+	inFlight      atomic.Int64
+	maxMu         sync.Mutex
+	max           int64
+	maxGoroutines int
+}
+
+func (s *slowLoadSaver) Save(ctx context.Context, data []byte) ([]byte, error) {
+	n := s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+	s.maxMu.Lock()
+	s.max = max(s.max, n)
+	s.maxGoroutines = max(s.maxGoroutines, runtime.NumGoroutine())
+	s.maxMu.Unlock()
+	if s.saves.Add(1) == s.failAt {
+		return nil, errors.New("save failed")
+	}
+	for i := 4; i < len(data); i += 32 {
+		if _, err := s.Load(ctx, data[i:i+32]); err != nil {
+			return nil, errors.New("child not saved")
+		}
+	}
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return s.mockLoadSaver.Save(ctx, data)
+}
+
+func TestSaveParallel(t *testing.T) {
+	ctx := context.Background()
+	sum := 1
+	base := 1
+	for i := 0; i < depth; i++ {
+		base *= branches
+		sum += base
+	}
+	sequential := newMockTreeNode(depth, 1)
+	require.NoError(t, persister.Save(ctx, newMockLoadSaver(), sequential))
+
+	t.Run("same references", func(t *testing.T) {
+		ls := &slowLoadSaver{mockLoadSaver: newMockLoadSaver(), delay: time.Millisecond}
+		n := newMockTreeNode(depth, 1)
+		goroutines := runtime.NumGoroutine()
+		require.NoError(t, persister.SaveParallel(ctx, ls, n, 4))
+		assert.Equal(t, sequential.Reference(), n.Reference())
+		assert.Equal(t, int64(sum), ls.saves.Load())
+		assert.LessOrEqual(t, ls.max, int64(4))
+		assert.Greater(t, ls.max, int64(1))
+		// the calling goroutine is one of the workers
+		assert.LessOrEqual(t, ls.maxGoroutines, goroutines+3)
+		assert.Equal(t, sum, loadAndCheck(t, ls, &mockTreeNode{ref: n.Reference()}, 1))
+
+		// saved nodes are not saved again
+		require.NoError(t, persister.SaveParallel(ctx, ls, n, 4))
+		assert.Equal(t, int64(sum), ls.saves.Load())
+	})
+
+	t.Run("shared nodes", func(t *testing.T) {
+		ls := &slowLoadSaver{mockLoadSaver: newMockLoadSaver()}
+		shared := newMockTreeNode(1, 2)
+		n := &mockTreeNode{val: 1, children: []*mockTreeNode{shared, {val: 3, children: []*mockTreeNode{shared}}, shared}}
+		require.NoError(t, persister.SaveParallel(ctx, ls, n, 8))
+		// the root, the node holding the shared one and the shared one with its children
+		assert.Equal(t, int64(3+branches), ls.saves.Load())
+	})
+
+	t.Run("first error cancels", func(t *testing.T) {
+		ls := &slowLoadSaver{mockLoadSaver: newMockLoadSaver(), delay: 10 * time.Millisecond, failAt: 3}
+		n := newMockTreeNode(depth, 1)
+		err := persister.SaveParallel(ctx, ls, n, 2)
+		assert.EqualError(t, err, "save failed")
+		assert.Empty(t, n.Reference())
+		assert.Less(t, ls.saves.Load(), int64(sum/2))
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		ls := &slowLoadSaver{mockLoadSaver: newMockLoadSaver()}
+		err := persister.SaveParallel(ctx, ls, newMockTreeNode(depth, 1), 2)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"encoding"
 	"fmt"
+	"sync"
 
 	"github.com/ethersphere/bee/v2/pkg/bmt"
 	"golang.org/x/crypto/sha3"
+	"golang.org/x/sync/errgroup"
 )
 
 // LoadSaver to be implemented as thin wrappers around persistent key-value storage
@@ -15,6 +17,11 @@ type LoadSaver interface {
 	Save(ctx context.Context, data []byte) (reference []byte, err error) // persists nodes out of scopc	qfor write operations
 }
 
+// Syncer is implemented by LoadSavers that buffer saves, Sync makes the data saved so far durable
+type Syncer interface {
+	Sync(ctx context.Context) error
+}
+
 // TreeNode is a generic interface for recursive persistable data structures
 type TreeNode interface {
 	Reference() []byte
@@ -24,7 +31,9 @@ type TreeNode interface {
 	encoding.BinaryUnmarshaler
 }
 
+// InmemLoadSaver keeps data in memory, it is safe for concurrent use
 type InmemLoadSaver struct {
+	mu    sync.RWMutex
 	store map[[32]byte][]byte
 }
 
@@ -40,7 +49,9 @@ func (ls *InmemLoadSaver) Load(ctx context.Context, reference []byte) ([]byte, e
 	}
 	var refArr [32]byte
 	copy(refArr[:], reference)
+	ls.mu.RLock()
 	data, ok := ls.store[refArr]
+	ls.mu.RUnlock()
 	if !ok {
 		return nil, fmt.Errorf("reference not found")
 	}
@@ -48,8 +59,11 @@ func (ls *InmemLoadSaver) Load(ctx context.Context, reference []byte) ([]byte, e
 }
 
 func (ls *InmemLoadSaver) Save(ctx context.Context, data []byte) ([]byte, error) {
-	ref := getBMTHash(data)
+	// the reference is the one of the Swarm file tree for data longer than a chunk
+	ref := [32]byte(Split(data).Address)
+	ls.mu.Lock()
 	ls.store[ref] = data
+	ls.mu.Unlock()
 	return ref[:], nil
 }
 
@@ -85,11 +99,110 @@ func Save(ctx context.Context, ls LoadSaver, n TreeNode) error {
 	return nil
 }
 
-func getBMTHash(nodeData []byte) [32]byte {
-	prover := NewBMTHasher()
-	prover.SetHeaderInt64(int64(len(nodeData)))
-	prover.Write(nodeData)
-	return [32]byte(prover.Sum(nil))
+// Sync makes the data saved to the LoadSaver durable if it buffers saves
+func Sync(ctx context.Context, ls LoadSaver) error {
+	if s, ok := ls.(Syncer); ok {
+		return s.Sync(ctx)
+	}
+	return nil
+}
+
+// SaveParallel persists a trie like Save with the children of each node saved concurrently
+// by at most workers goroutines, the calling one included, each marshalling and saving nodes to the LoadSaver.
+// A child is saved by a new goroutine while fewer than workers are running and by the one saving its parent otherwise.
+// A node is saved once its children have references, nodes shared by several parents are saved once
+// and the first error cancels the saves not yet done.
+func SaveParallel(ctx context.Context, ls LoadSaver, n TreeNode, workers int) error {
+	s := &parallelSaver{
+		ls:      ls,
+		workers: make(chan struct{}, max(workers, 1)-1),
+		saving:  make(map[TreeNode]*nodeSave),
+	}
+	return s.save(ctx, n)
+}
+
+// parallelSaver keeps track of the nodes saved by SaveParallel
+type parallelSaver struct {
+	ls      LoadSaver
+	workers chan struct{} // limits the goroutines started besides the calling one
+	mu      sync.Mutex
+	saving  map[TreeNode]*nodeSave
+}
+
+// nodeSave is the save of a node that others saving the same node wait for
+type nodeSave struct {
+	done chan struct{}
+	err  error
+}
+
+func (s *parallelSaver) save(ctx context.Context, n TreeNode) error {
+	s.mu.Lock()
+	if c, ok := s.saving[n]; ok {
+		s.mu.Unlock()
+		select {
+		case <-c.done:
+			return c.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	// only the nodes in saving get references set concurrently
+	if ref := n.Reference(); len(ref) > 0 {
+		s.mu.Unlock()
+		return nil
+	}
+	c := &nodeSave{done: make(chan struct{})}
+	s.saving[n] = c
+	s.mu.Unlock()
+
+	c.err = s.saveNode(ctx, n)
+	close(c.done)
+	return c.err
+}
+
+// saveNode saves the children of the node concurrently, then the node itself
+func (s *parallelSaver) saveNode(ctx context.Context, n TreeNode) error {
+	// the first error cancels the saves of the other children and is the one returned
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	save := func(tn TreeNode) error {
+		err := s.save(ctx, tn)
+		if err != nil {
+			cancel(err)
+		}
+		return err
+	}
+	var eg errgroup.Group
+	err := n.Children(func(tn TreeNode) error {
+		select {
+		case s.workers <- struct{}{}:
+			eg.Go(func() error {
+				defer func() { <-s.workers }()
+				return save(tn)
+			})
+			return nil
+		default:
+		}
+		// no worker is free, so the child is saved by this goroutine
+		return save(tn)
+	})
+	if werr := eg.Wait(); err == nil {
+		err = werr
+	}
+	if err != nil {
+		cancel(err)
+		return context.Cause(ctx)
+	}
+	bytes, err := n.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	ref, err := s.ls.Save(ctx, bytes)
+	if err != nil {
+		return err
+	}
+	n.SetReference(ref)
+	return nil
 }
 
 // NewBMTHasher creates a new BMT hasher instance
@@ -0,0 +1,215 @@
+package proof
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ethersphere/bee/v2/pkg/bmt"
+	"golang.org/x/crypto/sha3"
+)
+
+// The ABI types follow the structs of POTProofVerifier in blockchain/contracts: a Proof is the proof segments,
+// the proven segment, the chunk span and the chunk proofs, each with its proof segments and chunk span.
+// Segment indexes are not encoded as the contract calculates them. The types change with the structs,
+// so calldata is only valid for the contract compiled from the same sources.
+const (
+	// ProofABIType is the ABI type of POTProofVerifier.Proof
+	ProofABIType = "(bytes32[],bytes32,uint64,(bytes32[],uint64)[])"
+	// ForkPathProofABIType is the ABI type of POTProofVerifier.ForkPathProof
+	ForkPathProofABIType = "(bytes32,bytes32,(" + ProofABIType + "," + ProofABIType + ")[],(" + ProofABIType + "," + ProofABIType + "))"
+	// AssertForkPathProofSignature is the signature of POTProofVerifierTester.assertForkPathProof
+	AssertForkPathProofSignature = "assertForkPathProof(" + ForkPathProofABIType + ")"
+)
+
+// ABIEncode returns the ABI encoding of the proof as the only parameter of a function taking
+// a POTProofVerifier.ForkPathProof, which is the same as abi.encode(proof) in Solidity
+func (f *ForkPathProof) ABIEncode() ([]byte, error) {
+	v, err := f.abiValue()
+	if err != nil {
+		return nil, err
+	}
+	return abiTuple{v}.encode(), nil
+}
+
+// Calldata returns the calldata of a call to the method with the given signature taking the proof as its only parameter,
+// such as AssertForkPathProofSignature. The calldata is the ABI encoding of the proof prefixed with the function selector,
+// the first 4 bytes of the Keccak-256 hash of the signature. Without a signature it is the ABI encoding only.
+func (f *ForkPathProof) Calldata(signature string) ([]byte, error) {
+	encoded, err := f.ABIEncode()
+	if err != nil {
+		return nil, err
+	}
+	if signature == "" {
+		return encoded, nil
+	}
+	return append(FunctionSelector(signature), encoded...), nil
+}
+
+// FunctionSelector returns the first 4 bytes of the Keccak-256 hash of the function signature
+func FunctionSelector(signature string) []byte {
+	h := sha3.NewLegacyKeccak256()
+	h.Write([]byte(signature))
+	return h.Sum(nil)[:4]
+}
+
+// abiValue returns the ABI value of the proof as POTProofVerifier.ForkPathProof
+func (f *ForkPathProof) abiValue() (abiTuple, error) {
+	if f.EntryProof == nil {
+		return nil, fmt.Errorf("missing entry proof")
+	}
+	rootReference, err := abiBytes32(f.RootReference)
+	if err != nil {
+		return nil, fmt.Errorf("root reference: %w", err)
+	}
+	targetKey, err := abiBytes32(f.TargetKey)
+	if err != nil {
+		return nil, fmt.Errorf("target key: %w", err)
+	}
+	forkRefProofs := make(abiArray, len(f.ForkRefProofs))
+	for i, p := range f.ForkRefProofs {
+		if p == nil {
+			return nil, fmt.Errorf("missing fork reference proof at %d", i)
+		}
+		bitVectorProof, err := abiProof(p.BitVectorProof, p.BitVectorChunkProofs)
+		if err != nil {
+			return nil, fmt.Errorf("bit vector proof at fork %d: %w", i, err)
+		}
+		forkReferenceProof, err := abiProof(p.ForkReferenceProof, p.ForkReferenceChunkProofs)
+		if err != nil {
+			return nil, fmt.Errorf("fork reference proof at fork %d: %w", i, err)
+		}
+		forkRefProofs[i] = abiTuple{bitVectorProof, forkReferenceProof}
+	}
+	bitVectorProof, err := abiProof(f.EntryProof.BitVectorProof, f.EntryProof.BitVectorChunkProofs)
+	if err != nil {
+		return nil, fmt.Errorf("bit vector proof of the entry: %w", err)
+	}
+	entryProof, err := abiProof(f.EntryProof.EntryProof, f.EntryProof.EntryChunkProofs)
+	if err != nil {
+		return nil, fmt.Errorf("entry proof: %w", err)
+	}
+	return abiTuple{rootReference, targetKey, forkRefProofs, abiTuple{bitVectorProof, entryProof}}, nil
+}
+
+// abiProof returns the ABI value of a BMT proof along with its chunk proofs as POTProofVerifier.Proof
+// the proven segments of the chunk proofs are left out as they are calculated from the chunks below
+func abiProof(proof *bmt.Proof, chunkProofs []*bmt.Proof) (abiTuple, error) {
+	if proof == nil || len(proof.Span) != 8 {
+		return nil, errMalformed
+	}
+	proveSegment, err := abiBytes32(proof.ProveSegment)
+	if err != nil {
+		return nil, err
+	}
+	proofSegments, err := abiBytes32Array(proof.ProofSegments)
+	if err != nil {
+		return nil, err
+	}
+	chunks := make(abiArray, len(chunkProofs))
+	for i, chunkProof := range chunkProofs {
+		if chunkProof == nil || len(chunkProof.Span) != 8 {
+			return nil, errMalformed
+		}
+		segments, err := abiBytes32Array(chunkProof.ProofSegments)
+		if err != nil {
+			return nil, err
+		}
+		chunks[i] = abiTuple{segments, abiUint(binary.LittleEndian.Uint64(chunkProof.Span))}
+	}
+	return abiTuple{proofSegments, proveSegment, abiUint(binary.LittleEndian.Uint64(proof.Span)), chunks}, nil
+}
+
+// abiEncoder is a value of the ABI encoding
+type abiEncoder interface {
+	// dynamic tells if the value is encoded in the tail of the tuple holding it
+	dynamic() bool
+	encode() []byte
+}
+
+// abiWord is a static value taking a 32 byte word
+type abiWord [32]byte
+
+// abiTuple is a tuple of values, a struct or the parameters of a function
+type abiTuple []abiEncoder
+
+// abiArray is a dynamic array of values of the same type
+type abiArray []abiEncoder
+
+// abiUint returns the word of an unsigned integer
+func abiUint(v uint64) abiWord {
+	var w abiWord
+	binary.BigEndian.PutUint64(w[24:], v)
+	return w
+}
+
+// abiBytes32 returns the word of a 32 byte segment
+func abiBytes32(b []byte) (abiWord, error) {
+	var w abiWord
+	if len(b) != 32 {
+		return w, fmt.Errorf("%w: invalid segment length: %d", errMalformed, len(b))
+	}
+	copy(w[:], b)
+	return w, nil
+}
+
+// abiBytes32Array returns the array of 32 byte segments
+func abiBytes32Array(segments [][]byte) (abiArray, error) {
+	a := make(abiArray, len(segments))
+	for i, segment := range segments {
+		w, err := abiBytes32(segment)
+		if err != nil {
+			return nil, err
+		}
+		a[i] = w
+	}
+	return a, nil
+}
+
+func (w abiWord) dynamic() bool {
+	return false
+}
+
+func (w abiWord) encode() []byte {
+	return w[:]
+}
+
+func (t abiTuple) dynamic() bool {
+	for _, v := range t {
+		if v.dynamic() {
+			return true
+		}
+	}
+	return false
+}
+
+// encode writes the heads of the values, then the tails of the dynamic ones
+// the head of a dynamic value is the offset of its tail from the start of the tuple
+func (t abiTuple) encode() []byte {
+	headSize := 0
+	for _, v := range t {
+		if v.dynamic() {
+			headSize += 32
+		} else {
+			headSize += len(v.encode())
+		}
+	}
+	var head, tail []byte
+	for _, v := range t {
+		if !v.dynamic() {
+			head = append(head, v.encode()...)
+			continue
+		}
+		head = append(head, abiUint(uint64(headSize+len(tail))).encode()...)
+		tail = append(tail, v.encode()...)
+	}
+	return append(head, tail...)
+}
+
+func (a abiArray) dynamic() bool {
+	return true
+}
+
+// encode writes the length of the array followed by its values encoded as a tuple
+func (a abiArray) encode() []byte {
+	return append(abiUint(uint64(len(a))).encode(), abiTuple(a).encode()...)
+}
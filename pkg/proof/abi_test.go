@@ -0,0 +1,153 @@
+package proof_test
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/ethersphere/proximity-order-trie/pkg/proof"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestForkPathProofCalldata checks that the calldata decodes to the parameters of the POTProofVerifier tests
+func TestForkPathProofCalldata(t *testing.T) {
+	assert.Equal(t, "a9059cbb", hex.EncodeToString(proof.FunctionSelector("transfer(address,uint256)")))
+
+	t.Run("contract sample", func(t *testing.T) {
+		data, err := os.ReadFile("../../blockchain/test/forkPathProofSample.json")
+		require.NoError(t, err)
+		var expected map[string]any
+		require.NoError(t, json.Unmarshal(data, &expected))
+		p := new(proof.ForkPathProof)
+		require.NoError(t, json.Unmarshal(data, p))
+
+		calldata, err := p.Calldata(proof.AssertForkPathProofSignature)
+		require.NoError(t, err)
+		assert.Equal(t, proof.FunctionSelector(proof.AssertForkPathProofSignature), calldata[:4])
+		assert.Equal(t, expected, decodeForkPathProofABI(t, calldata[4:]))
+
+		encoded, err := p.ABIEncode()
+		require.NoError(t, err)
+		assert.Equal(t, calldata[4:], encoded)
+		noSelector, err := p.Calldata("")
+		require.NoError(t, err)
+		assert.Equal(t, encoded, noSelector)
+	})
+
+	t.Run("multi-chunk proof", func(t *testing.T) {
+		root, _, ls, keys := createMultiChunkIndex(t)
+		p, err := proof.CreateForkPathProof(context.Background(), root, ls, keys[20])
+		require.NoError(t, err)
+		require.NotEmpty(t, p.EntryProof.EntryChunkProofs)
+
+		// the fields the contract does not take are left out of the encoding
+		data, err := json.Marshal(p)
+		require.NoError(t, err)
+		var expected map[string]any
+		require.NoError(t, json.Unmarshal(data, &expected))
+		for _, forkRefProof := range expected["forkRefProofs"].([]any) {
+			delete(forkRefProof.(map[string]any), "forkPO")
+		}
+		delete(expected["entryProof"].(map[string]any), "valueProofs")
+
+		encoded, err := p.ABIEncode()
+		require.NoError(t, err)
+		assert.Equal(t, expected, decodeForkPathProofABI(t, encoded))
+	})
+
+	t.Run("invalid proof", func(t *testing.T) {
+		data, err := os.ReadFile("../../blockchain/test/forkPathProofSample.json")
+		require.NoError(t, err)
+		p := new(proof.ForkPathProof)
+		require.NoError(t, json.Unmarshal(data, p))
+
+		_, err = (&proof.ForkPathProof{RootReference: p.RootReference, TargetKey: p.TargetKey}).ABIEncode()
+		assert.Error(t, err)
+
+		p.ForkRefProofs[0].BitVectorProof.ProofSegments[1] = p.ForkRefProofs[0].BitVectorProof.ProofSegments[1][:31]
+		_, err = p.Calldata(proof.AssertForkPathProofSignature)
+		assert.Error(t, err)
+	})
+}
+
+// decodeForkPathProofABI decodes the ABI encoding of a POTProofVerifier.ForkPathProof parameter
+// into the form of the JSON parameters and checks that every byte of the encoding is read
+func decodeForkPathProofABI(t *testing.T, data []byte) map[string]any {
+	t.Helper()
+	end := 0
+	word := func(offset int) []byte {
+		t.Helper()
+		require.GreaterOrEqual(t, offset, 0)
+		require.LessOrEqual(t, offset+32, len(data))
+		end = max(end, offset+32)
+		return data[offset : offset+32]
+	}
+	number := func(offset int) int {
+		t.Helper()
+		w := word(offset)
+		require.Equal(t, make([]byte, 24), w[:24])
+		n := 0
+		for _, b := range w[24:] {
+			n = n<<8 | int(b)
+		}
+		return n
+	}
+	bytes32 := func(offset int) any {
+		return "0x" + hex.EncodeToString(word(offset))
+	}
+	// the head of a dynamic value is the offset of its tail from the start of the enclosing tuple
+	tail := func(tuple, i int) int {
+		return tuple + number(tuple+32*i)
+	}
+	bytes32Array := func(offset int) []any {
+		n := number(offset)
+		a := make([]any, n)
+		for i := range a {
+			a[i] = bytes32(offset + 32 + 32*i)
+		}
+		return a
+	}
+	dynamicArray := func(offset int, decode func(int) any) []any {
+		n := number(offset)
+		a := make([]any, n)
+		for i := range a {
+			a[i] = decode(tail(offset+32, i))
+		}
+		return a
+	}
+	chunkProof := func(offset int) any {
+		return map[string]any{
+			"proofSegments": bytes32Array(tail(offset, 0)),
+			"chunkSpan":     float64(number(offset + 32)),
+		}
+	}
+	bmtProof := func(offset int) any {
+		return map[string]any{
+			"proofSegments": bytes32Array(tail(offset, 0)),
+			"proveSegment":  bytes32(offset + 32),
+			"chunkSpan":     float64(number(offset + 64)),
+			"chunkProofs":   dynamicArray(tail(offset, 3), chunkProof),
+		}
+	}
+	proofPair := func(first, second string) func(int) any {
+		return func(offset int) any {
+			return map[string]any{
+				first:  bmtProof(tail(offset, 0)),
+				second: bmtProof(tail(offset, 1)),
+			}
+		}
+	}
+
+	forkPathProof := tail(0, 0)
+	decoded := map[string]any{
+		"rootReference": bytes32(forkPathProof),
+		"targetKey":     bytes32(forkPathProof + 32),
+		"forkRefProofs": dynamicArray(tail(forkPathProof, 2), proofPair("bitVectorProof", "forkReferenceProof")),
+		"entryProof":    proofPair("bitVectorProof", "entryProof")(tail(forkPathProof, 3)),
+	}
+	require.Equal(t, len(data), end)
+	return decoded
+}
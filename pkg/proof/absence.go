@@ -0,0 +1,104 @@
+package proof
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ethersphere/bee/v2/pkg/bmt"
+	"github.com/ethersphere/proximity-order-trie/pkg/elements"
+	"github.com/ethersphere/proximity-order-trie/pkg/persister"
+)
+
+var (
+	// ErrKeyExists is returned when an absence proof is requested for a key that is in the pot
+	ErrKeyExists = errors.New("target key is in the trie")
+)
+
+// AbsenceProof proves that a key is not in the pot: the path of forks matching the key
+// ends at a node whose bitvector has no fork at the PO of its key and the target key
+type AbsenceProof struct {
+	// ForkRefProofs contains all the fork node proofs in the path
+	ForkRefProofs []*ForkRefProof
+	// RootReference is the reference to the root node
+	RootReference []byte
+	// TargetKey is the key proven to be absent
+	TargetKey []byte
+	// BitVectorProof is the BMT proof for the bitvector and the key of the last node on the path
+	BitVectorProof *bmt.Proof
+	// BitVectorChunkProofs are the chunk proofs of the bitvector if the last node is stored in multiple chunks
+	BitVectorChunkProofs []*bmt.Proof
+}
+
+// CreateAbsenceProof generates a proof that the target key is not in the pot with the given root node.
+// It gives ErrKeyExists if the key is in the pot.
+func CreateAbsenceProof(ctx context.Context, rootNode elements.Node, ls persister.LoadSaver, targetKey []byte) (*AbsenceProof, error) {
+	path, nodesData, err := forkPath(ctx, rootNode, ls, targetKey)
+	if err == nil {
+		return nil, ErrKeyExists
+	}
+	if !errors.Is(err, ErrForkNotFound) {
+		return nil, err
+	}
+
+	// prove the bitvector along with the node's key
+	bitVectorProof, bitVectorChunkProofs := newNodeProver(nodesData[len(nodesData)-1]).proof(1)
+
+	return &AbsenceProof{
+		ForkRefProofs:        path.ForkRefProofs,
+		RootReference:        path.RootReference,
+		TargetKey:            targetKey,
+		BitVectorProof:       bitVectorProof,
+		BitVectorChunkProofs: bitVectorChunkProofs,
+	}, nil
+}
+
+// Verify checks the absence proof against the root reference
+// the same way as POTProofVerifier.assertAbsenceProof does
+func (a *AbsenceProof) Verify(rootRef []byte) error {
+	if !bytes.Equal(a.RootReference, rootRef) {
+		return fmt.Errorf("%w: root reference mismatch", ErrInvalidProof)
+	}
+	if len(a.TargetKey) != 32 {
+		return fmt.Errorf("%w: invalid target key length: %d", ErrInvalidProof, len(a.TargetKey))
+	}
+	nodeHash, po, err := verifyForkRefProofs(rootRef, a.TargetKey, a.ForkRefProofs)
+	if err != nil {
+		return err
+	}
+	if !wellFormed(a.BitVectorProof) {
+		return fmt.Errorf("%w: malformed bit vector proof", ErrInvalidProof)
+	}
+	nodeKey := a.BitVectorProof.ProofSegments[0]
+	bitVector := a.BitVectorProof.ProveSegment
+	po = elements.PO(nodeKey, a.TargetKey, po)
+	if po >= elements.MaxDepth {
+		return fmt.Errorf("%w: %w", ErrInvalidProof, ErrKeyExists)
+	}
+	if isBitSet(bitVector, po) {
+		return fmt.Errorf("%w: fork is set in the node's bitvector", ErrInvalidProof)
+	}
+	if err := verifyAt(nodeHash, *a.BitVectorProof, 1, a.BitVectorChunkProofs); err != nil {
+		return fmt.Errorf("%w: invalid bit vector proof at the last node", ErrInvalidProof)
+	}
+	return nil
+}
+
+// JSON returns hexified JSON values used as smart contract validation parameter
+func (a *AbsenceProof) JSON() (string, error) {
+	proofsData := map[string]interface{}{
+		"rootReference":  "0x" + hex.EncodeToString(a.RootReference),
+		"targetKey":      "0x" + hex.EncodeToString(a.TargetKey),
+		"forkRefProofs":  forkRefProofsJSON(a.ForkRefProofs),
+		"bitVectorProof": bmtProofJSON(a.BitVectorProof, a.BitVectorChunkProofs),
+	}
+
+	jsonProofsData, err := json.MarshalIndent(proofsData, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(jsonProofsData), nil
+}
@@ -0,0 +1,142 @@
+package proof_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"testing"
+
+	pot "github.com/ethersphere/proximity-order-trie"
+	"github.com/ethersphere/proximity-order-trie/pkg/elements"
+	"github.com/ethersphere/proximity-order-trie/pkg/persister"
+	"github.com/ethersphere/proximity-order-trie/pkg/proof"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAbsenceProof tests the creation and verification of absence proofs on a hand built trie
+func TestAbsenceProof(t *testing.T) {
+	ls := persister.NewInmemLoadSaver()
+	ctx := context.Background()
+	root, keys := createTestTrie(t, ls, 2)
+	rootRef := root.(*elements.SwarmNode).Reference()
+
+	absentKey := func(b ...byte) []byte {
+		key := make([]byte, 32)
+		copy(key, b)
+		return key
+	}
+	tests := []struct {
+		name   string
+		key    []byte
+		length int // number of fork proofs on the path
+	}{
+		{
+			name:   "no fork at root",
+			key:    absentKey(0x01),
+			length: 0,
+		},
+		{
+			name:   "no fork one level below",
+			key:    absentKey(0xc0),
+			length: 1,
+		},
+		{
+			name:   "no fork two levels below",
+			key:    absentKey(0x80, 0x81),
+			length: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := proof.CreateAbsenceProof(ctx, root, ls, tt.key)
+			require.NoError(t, err)
+			assert.Len(t, p.ForkRefProofs, tt.length)
+			require.NoError(t, p.Verify(rootRef))
+		})
+	}
+
+	t.Run("present key", func(t *testing.T) {
+		for _, key := range keys {
+			_, err := proof.CreateAbsenceProof(ctx, root, ls, key)
+			assert.ErrorIs(t, err, proof.ErrKeyExists)
+		}
+	})
+
+	t.Run("invalid proofs", func(t *testing.T) {
+		create := func() *proof.AbsenceProof {
+			p, err := proof.CreateAbsenceProof(ctx, root, ls, absentKey(0x80, 0x81))
+			require.NoError(t, err)
+			return p
+		}
+		p := create()
+		assert.ErrorIs(t, p.Verify(make([]byte, 32)), proof.ErrInvalidProof)
+
+		// claim absence of a key that is in the pot with the proof of a sibling key
+		p = create()
+		p.TargetKey = keys[2]
+		assert.ErrorIs(t, p.Verify(rootRef), proof.ErrInvalidProof)
+
+		// the bitvector has a fork at the PO
+		p = create()
+		p.BitVectorProof.ProveSegment = bytes.Repeat([]byte{0xff}, 32)
+		assert.ErrorIs(t, p.Verify(rootRef), proof.ErrInvalidProof)
+
+		// the bitvector does not belong to the node
+		p = create()
+		p.BitVectorProof.ProveSegment = append([]byte{0x01}, make([]byte, 31)...)
+		assert.ErrorIs(t, p.Verify(rootRef), proof.ErrInvalidProof)
+
+		// claim the path ends at an intermediate node
+		p = create()
+		p.ForkRefProofs = p.ForkRefProofs[:1]
+		assert.ErrorIs(t, p.Verify(rootRef), proof.ErrInvalidProof)
+
+		p = create()
+		p.ForkRefProofs[0].ForkReferenceProof.ProveSegment = make([]byte, 32)
+		assert.ErrorIs(t, p.Verify(rootRef), proof.ErrInvalidProof)
+
+		p = create()
+		p.BitVectorProof.ProofSegments = p.BitVectorProof.ProofSegments[:3]
+		assert.ErrorIs(t, p.Verify(rootRef), proof.ErrInvalidProof)
+	})
+}
+
+// TestAbsenceProofIndex checks absence proofs on a persisted index
+func TestAbsenceProofIndex(t *testing.T) {
+	ctx := context.Background()
+	ls := persister.NewInmemLoadSaver()
+	newf := func(key []byte) elements.Entry {
+		e, _ := pot.NewSwarmEntry(key, nil)
+		return e
+	}
+	key := func(i int) []byte {
+		buf := make([]byte, 4)
+		binary.BigEndian.PutUint32(buf, uint32(i))
+		h := sha256.Sum256(buf)
+		return h[:]
+	}
+	idx, err := pot.New(elements.NewSwarmPot(elements.NewSingleOrder(256), ls, newf))
+	require.NoError(t, err)
+	defer idx.Close()
+	count := 200
+	for i := 0; i < count; i++ {
+		e, err := pot.NewSwarmEntry(key(i), []byte{byte(i)})
+		require.NoError(t, err)
+		require.NoError(t, idx.Add(ctx, e))
+	}
+	ref, err := idx.Save(ctx)
+	require.NoError(t, err)
+	root, _, err := elements.NewSwarmPotReference(elements.NewSingleOrder(256), ls, ref, newf).Load(ctx, ref)
+	require.NoError(t, err)
+
+	for i := 0; i < count; i++ {
+		_, err := proof.CreateAbsenceProof(ctx, root, ls, key(i))
+		require.ErrorIs(t, err, proof.ErrKeyExists)
+
+		p, err := proof.CreateAbsenceProof(ctx, root, ls, key(count+i))
+		require.NoError(t, err)
+		require.NoError(t, p.Verify(ref))
+	}
+}
@@ -0,0 +1,165 @@
+package proof
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ethersphere/bee/v2/pkg/bmt"
+	"github.com/ethersphere/proximity-order-trie/pkg/persister"
+)
+
+// Nodes longer than a chunk are stored as Swarm files: the segments of the node data are proven
+// in the data chunk holding them, then the reference of each chunk is proven in its parent up to
+// the root chunk of the node. These chunk proofs are ordered from the parent of the data chunk upwards
+// and are empty for nodes that fit in a single chunk.
+
+// maxChunkDepth is the maximum number of levels of intermediate chunks of the Swarm file tree of a node
+// proofs may have, which bounds the spans they may give to maxSpan
+const maxChunkDepth = 7
+
+// maxSpan is the length of the data a Swarm file tree of maxChunkDepth levels of intermediate chunks holds
+const maxSpan = uint64(persister.ChunkSize) << (7 * maxChunkDepth) // Branches is 1 << 7
+
+// validSpan tells if the span is the one of a chunk holding data of a tree proofs may have
+func validSpan(span uint64) bool {
+	return span > 0 && span <= maxSpan
+}
+
+// nodeProver proves segments of node data stored in one or more chunks
+type nodeProver struct {
+	root    *persister.Chunk
+	provers map[*persister.Chunk]*BMTProver
+}
+
+// newNodeProver creates a prover for the node data
+func newNodeProver(nodeData []byte) *nodeProver {
+	return &nodeProver{
+		root:    persister.Split(nodeData),
+		provers: make(map[*persister.Chunk]*BMTProver),
+	}
+}
+
+// chunkProver returns the BMT prover of a chunk of the node
+func (p *nodeProver) chunkProver(c *persister.Chunk) *BMTProver {
+	if prover, ok := p.provers[c]; ok {
+		return prover
+	}
+	prover := NewBMTProver()
+	prover.SetHeaderInt64(int64(c.Span))
+	prover.Write(c.Payload)
+	_, _ = prover.Hash(nil) // necessary to fill up bmt
+	p.provers[c] = prover
+	return prover
+}
+
+// locate returns the data chunk holding the segment at the given index of the node data,
+// the index of the segment in the data chunk and the proofs of the chunk references up to the root chunk
+func (p *nodeProver) locate(index int) (*persister.Chunk, int, []*bmt.Proof) {
+	offset := uint64(index) * 32
+	c := p.root
+	var chunkProofs []*bmt.Proof
+	for len(c.Children) > 0 {
+		capacity := persister.ChildCapacity(c.Span)
+		i := int(offset / capacity)
+		offset %= capacity
+		proof := p.chunkProver(c).Proof(i)
+		chunkProofs = append([]*bmt.Proof{&proof}, chunkProofs...)
+		c = c.Children[i]
+	}
+	return c, int(offset / 32), chunkProofs
+}
+
+// proof returns the proof of the segment at the given index of the node data along with its chunk proofs
+func (p *nodeProver) proof(index int) (*bmt.Proof, []*bmt.Proof) {
+	c, i, chunkProofs := p.locate(index)
+	proof := p.chunkProver(c).Proof(i)
+	return &proof, chunkProofs
+}
+
+// segmentsProofs returns the proofs of the segments with indexes in [from, to) of the node data,
+// one for each data chunk holding them
+func (p *nodeProver) segmentsProofs(from, to int) []*SegmentsProof {
+	var proofs []*SegmentsProof
+	for from < to {
+		c, i, chunkProofs := p.locate(from)
+		n := min(to-from, persister.Branches-i)
+		proof := p.chunkProver(c).SegmentsProof(i, i+n)
+		proof.ChunkProofs = chunkProofs
+		proofs = append(proofs, &proof)
+		from += n
+	}
+	return proofs
+}
+
+// locate maps the index of a segment of the node data to its index in the data chunk with the given span
+// and to the indexes of the chunk references on the path up to the root chunk, checking that the spans
+// of the chunks are the ones of the Swarm file tree of the node
+func locate(index int, span []byte, chunkProofs []*bmt.Proof) (int, []int, error) {
+	if len(chunkProofs) > maxChunkDepth {
+		return 0, nil, fmt.Errorf("%w: %d chunk proofs exceed the maximum depth %d", ErrInvalidProof, len(chunkProofs), maxChunkDepth)
+	}
+	offset := uint64(index) * 32
+	indexes := make([]int, len(chunkProofs))
+	for k := len(chunkProofs) - 1; k >= 0; k-- {
+		chunkSpan, err := chunkProofSpan(chunkProofs[k])
+		if err != nil {
+			return 0, nil, err
+		}
+		if !validSpan(chunkSpan) {
+			return 0, nil, fmt.Errorf("%w: chunk span %d is out of range", ErrInvalidProof, chunkSpan)
+		}
+		if chunkSpan <= persister.ChunkSize {
+			return 0, nil, fmt.Errorf("%w: intermediate chunk span %d fits in a chunk", ErrInvalidProof, chunkSpan)
+		}
+		capacity := persister.ChildCapacity(chunkSpan)
+		i := offset / capacity
+		if i*capacity >= chunkSpan {
+			return 0, nil, fmt.Errorf("%w: segment %d is out of the node", ErrInvalidProof, index)
+		}
+		offset %= capacity
+		childSpan := span
+		if k > 0 {
+			childSpan = chunkProofs[k-1].Span
+		}
+		if len(childSpan) != 8 || binary.LittleEndian.Uint64(childSpan) != min(capacity, chunkSpan-i*capacity) {
+			return 0, nil, fmt.Errorf("%w: invalid chunk span", ErrInvalidProof)
+		}
+		indexes[k] = int(i)
+	}
+	return int(offset / 32), indexes, nil
+}
+
+// chunkProofSpan checks the form of a chunk proof and returns the span of the chunk
+func chunkProofSpan(proof *bmt.Proof) (uint64, error) {
+	if proof == nil || len(proof.Span) != 8 || len(proof.ProofSegments) != 7 {
+		return 0, fmt.Errorf("%w: malformed chunk proof", ErrInvalidProof)
+	}
+	return binary.LittleEndian.Uint64(proof.Span), nil
+}
+
+// verifyChunkPath checks that the address of a data chunk resolves to the node hash
+// through the chunk proofs with the given indexes
+func verifyChunkPath(nodeHash, chunkAddress []byte, chunkProofs []*bmt.Proof, indexes []int) error {
+	for k, chunkProof := range chunkProofs {
+		proof := *chunkProof
+		proof.ProveSegment = chunkAddress
+		proof.Index = indexes[k]
+		var err error
+		if chunkAddress, err = Verify(proof); err != nil {
+			return err
+		}
+	}
+	if !bytes.Equal(chunkAddress, nodeHash) {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+// nodeSpan returns the length of the node data a proof with the given chunk proofs is in
+func nodeSpan(span []byte, chunkProofs []*bmt.Proof) []byte {
+	if len(chunkProofs) == 0 {
+		return span
+	}
+	return chunkProofs[len(chunkProofs)-1].Span
+}
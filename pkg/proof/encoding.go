@@ -0,0 +1,666 @@
+package proof
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethersphere/bee/v2/pkg/bmt"
+	"github.com/ethersphere/proximity-order-trie/pkg/elements"
+)
+
+// The JSON encoding of the proofs is the form POTProofVerifier takes them as parameters with 0x prefixed hex segments.
+// The binary encoding is a compact form to send proofs over the wire: BMT proofs of chunks always have 7 sisters,
+// so only the segments and the 8 byte spans are written, with unsigned varint counts of the variable parts.
+// Neither of them holds what follows from the rest of the proof: the indexes of the proven segments are calculated
+// from the bitvectors and the spans of the chunks, the proven segments of chunk proofs are the addresses of the chunks below.
+// Decoding restores them, so decoded proofs are the same as the ones created.
+
+// errMalformed is returned when encoding a proof with segments of invalid length
+var errMalformed = errors.New("malformed proof")
+
+// hexBytes is a byte slice encoded as a 0x prefixed hex string
+type hexBytes []byte
+
+func (h hexBytes) MarshalText() ([]byte, error) {
+	return []byte("0x" + hex.EncodeToString(h)), nil
+}
+
+func (h *hexBytes) UnmarshalText(text []byte) error {
+	b, err := hex.DecodeString(strings.TrimPrefix(string(text), "0x"))
+	if err != nil {
+		return err
+	}
+	if len(b) == 0 {
+		b = nil
+	}
+	*h = b
+	return nil
+}
+
+// hexSegments returns the hexified segments
+func hexSegments(segments [][]byte) []hexBytes {
+	hexified := make([]hexBytes, len(segments))
+	for i, segment := range segments {
+		hexified[i] = segment
+	}
+	return hexified
+}
+
+// segmentsOf returns the segments of hexified segments
+func segmentsOf(hexified []hexBytes) [][]byte {
+	if len(hexified) == 0 {
+		return nil
+	}
+	segments := make([][]byte, len(hexified))
+	for i, segment := range hexified {
+		segments[i] = segment
+	}
+	return segments
+}
+
+// proofJSON is the JSON form of a BMT proof along with its chunk proofs, Proof of POTProofVerifier
+type proofJSON struct {
+	ProofSegments []hexBytes       `json:"proofSegments"`
+	ProveSegment  hexBytes         `json:"proveSegment"`
+	ChunkSpan     uint64           `json:"chunkSpan"`
+	ChunkProofs   []chunkProofJSON `json:"chunkProofs"`
+}
+
+// chunkProofJSON is the JSON form of a chunk proof, ChunkProof of POTProofVerifier
+// the proven segments are left out as they are calculated from the chunks below
+type chunkProofJSON struct {
+	ProofSegments []hexBytes `json:"proofSegments"`
+	ChunkSpan     uint64     `json:"chunkSpan"`
+}
+
+// segmentsProofJSON is the JSON form of a proof of consecutive segments, ValueProof of POTProofVerifier
+type segmentsProofJSON struct {
+	Segments      []hexBytes       `json:"segments"`
+	ProofSegments []hexBytes       `json:"proofSegments"`
+	ChunkSpan     uint64           `json:"chunkSpan"`
+	ChunkProofs   []chunkProofJSON `json:"chunkProofs"`
+}
+
+// forkRefProofJSON is the JSON form of a ForkRefProof
+// the fork PO is not a field of the contract struct, fork path proofs calculate it from the target key
+type forkRefProofJSON struct {
+	BitVectorProof     proofJSON `json:"bitVectorProof"`
+	ForkReferenceProof proofJSON `json:"forkReferenceProof"`
+	ForkPO             *int      `json:"forkPO,omitempty"`
+}
+
+// entryProofJSON is the JSON form of an EntryProof
+// the value proofs are not a field of the contract struct, the contract takes them as a separate parameter
+type entryProofJSON struct {
+	BitVectorProof proofJSON           `json:"bitVectorProof"`
+	EntryProof     proofJSON           `json:"entryProof"`
+	ValueProofs    []segmentsProofJSON `json:"valueProofs,omitempty"`
+}
+
+// forkPathProofJSON is the JSON form of a ForkPathProof
+type forkPathProofJSON struct {
+	RootReference hexBytes           `json:"rootReference"`
+	TargetKey     hexBytes           `json:"targetKey"`
+	ForkRefProofs []forkRefProofJSON `json:"forkRefProofs"`
+	EntryProof    *entryProofJSON    `json:"entryProof"`
+}
+
+// bmtProofJSON returns the JSON form of a BMT proof along with its chunk proofs
+func bmtProofJSON(proof *bmt.Proof, chunkProofs []*bmt.Proof) proofJSON {
+	if proof == nil {
+		return proofJSON{ProofSegments: []hexBytes{}, ChunkProofs: []chunkProofJSON{}}
+	}
+	return proofJSON{
+		ProofSegments: hexSegments(proof.ProofSegments),
+		ProveSegment:  proof.ProveSegment,
+		ChunkSpan:     spanOf(proof.Span),
+		ChunkProofs:   chunkProofsJSON(chunkProofs),
+	}
+}
+
+// chunkProofsJSON returns the JSON form of chunk proofs
+func chunkProofsJSON(proofs []*bmt.Proof) []chunkProofJSON {
+	chunkProofs := make([]chunkProofJSON, len(proofs))
+	for i, proof := range proofs {
+		chunkProofs[i] = chunkProofJSON{
+			ProofSegments: hexSegments(proof.ProofSegments),
+			ChunkSpan:     spanOf(proof.Span),
+		}
+	}
+	return chunkProofs
+}
+
+// segmentsProofsJSON returns the JSON form of proofs of consecutive segments
+func segmentsProofsJSON(proofs []*SegmentsProof) []segmentsProofJSON {
+	segmentsProofs := make([]segmentsProofJSON, len(proofs))
+	for i, proof := range proofs {
+		segmentsProofs[i] = segmentsProofJSON{
+			Segments:      hexSegments(proof.Segments),
+			ProofSegments: hexSegments(proof.ProofSegments),
+			ChunkSpan:     spanOf(proof.Span),
+			ChunkProofs:   chunkProofsJSON(proof.ChunkProofs),
+		}
+	}
+	return segmentsProofs
+}
+
+// forkRefProofsJSON returns the JSON form of fork reference proofs
+func forkRefProofsJSON(proofs []*ForkRefProof) []forkRefProofJSON {
+	forkRefProofs := make([]forkRefProofJSON, len(proofs))
+	for i, proof := range proofs {
+		forkRefProofs[i] = proof.jsonData()
+	}
+	return forkRefProofs
+}
+
+// spanOf returns the span encoded in 8 bytes little endian as in chunks
+func spanOf(span []byte) uint64 {
+	if len(span) != 8 {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(span)
+}
+
+// proof returns the BMT proof and the chunk proofs of the JSON form
+func (j proofJSON) proof() (*bmt.Proof, []*bmt.Proof) {
+	return &bmt.Proof{
+		ProveSegment:  j.ProveSegment,
+		ProofSegments: segmentsOf(j.ProofSegments),
+		Span:          binary.LittleEndian.AppendUint64(nil, j.ChunkSpan),
+	}, chunkProofsOf(j.ChunkProofs)
+}
+
+// chunkProofsOf returns the chunk proofs of the JSON form
+func chunkProofsOf(proofs []chunkProofJSON) []*bmt.Proof {
+	if len(proofs) == 0 {
+		return nil
+	}
+	chunkProofs := make([]*bmt.Proof, len(proofs))
+	for i, proof := range proofs {
+		chunkProofs[i] = &bmt.Proof{
+			ProofSegments: segmentsOf(proof.ProofSegments),
+			Span:          binary.LittleEndian.AppendUint64(nil, proof.ChunkSpan),
+		}
+	}
+	return chunkProofs
+}
+
+// segmentsProofsOf returns the proofs of consecutive segments of the JSON form
+func segmentsProofsOf(proofs []segmentsProofJSON) []*SegmentsProof {
+	if len(proofs) == 0 {
+		return nil
+	}
+	segmentsProofs := make([]*SegmentsProof, len(proofs))
+	for i, proof := range proofs {
+		segmentsProofs[i] = &SegmentsProof{
+			Segments:      segmentsOf(proof.Segments),
+			ProofSegments: segmentsOf(proof.ProofSegments),
+			Span:          binary.LittleEndian.AppendUint64(nil, proof.ChunkSpan),
+			ChunkProofs:   chunkProofsOf(proof.ChunkProofs),
+		}
+	}
+	return segmentsProofs
+}
+
+// jsonData returns the JSON form of the fork reference proof
+func (f *ForkRefProof) jsonData() forkRefProofJSON {
+	forkPO := f.ForkPO
+	return forkRefProofJSON{
+		BitVectorProof:     bmtProofJSON(f.BitVectorProof, f.BitVectorChunkProofs),
+		ForkReferenceProof: bmtProofJSON(f.ForkReferenceProof, f.ForkReferenceChunkProofs),
+		ForkPO:             &forkPO,
+	}
+}
+
+// setJSONData sets the fields of the fork reference proof from its JSON form
+func (f *ForkRefProof) setJSONData(j forkRefProofJSON) {
+	f.BitVectorProof, f.BitVectorChunkProofs = j.BitVectorProof.proof()
+	f.ForkReferenceProof, f.ForkReferenceChunkProofs = j.ForkReferenceProof.proof()
+	f.ForkPO = 0
+	if j.ForkPO != nil {
+		f.ForkPO = *j.ForkPO
+	}
+}
+
+// MarshalJSON encodes the fork reference proof in the form of POTProofVerifier.ForkRefProof along with the fork PO
+func (f *ForkRefProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.jsonData())
+}
+
+// UnmarshalJSON decodes a fork reference proof encoded by MarshalJSON
+func (f *ForkRefProof) UnmarshalJSON(data []byte) error {
+	var j forkRefProofJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if j.ForkPO == nil {
+		return fmt.Errorf("unmarshal fork reference proof: missing fork PO")
+	}
+	f.setJSONData(j)
+	f.setIndexes()
+	return nil
+}
+
+// jsonData returns the JSON form of the entry proof
+func (e *EntryProof) jsonData() *entryProofJSON {
+	return &entryProofJSON{
+		BitVectorProof: bmtProofJSON(e.BitVectorProof, e.BitVectorChunkProofs),
+		EntryProof:     bmtProofJSON(e.EntryProof, e.EntryChunkProofs),
+		ValueProofs:    segmentsProofsJSON(e.ValueProofs),
+	}
+}
+
+// setJSONData sets the fields of the entry proof from its JSON form
+func (e *EntryProof) setJSONData(j *entryProofJSON) {
+	e.BitVectorProof, e.BitVectorChunkProofs = j.BitVectorProof.proof()
+	e.EntryProof, e.EntryChunkProofs = j.EntryProof.proof()
+	e.ValueProofs = segmentsProofsOf(j.ValueProofs)
+}
+
+// MarshalJSON encodes the entry proof in the form of POTProofVerifier.EntryProof along with the value proofs
+func (e *EntryProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.jsonData())
+}
+
+// UnmarshalJSON decodes an entry proof encoded by MarshalJSON
+func (e *EntryProof) UnmarshalJSON(data []byte) error {
+	var j entryProofJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	e.setJSONData(&j)
+	e.setIndexes()
+	return nil
+}
+
+// MarshalJSON encodes the fork path proof in the form of POTProofVerifier.ForkPathProof
+// along with the fork POs and the value proofs
+func (f *ForkPathProof) MarshalJSON() ([]byte, error) {
+	if f.EntryProof == nil {
+		return nil, fmt.Errorf("marshal fork path proof: missing entry proof")
+	}
+	return json.Marshal(forkPathProofJSON{
+		RootReference: f.RootReference,
+		TargetKey:     f.TargetKey,
+		ForkRefProofs: forkRefProofsJSON(f.ForkRefProofs),
+		EntryProof:    f.EntryProof.jsonData(),
+	})
+}
+
+// UnmarshalJSON decodes a fork path proof encoded by MarshalJSON or given as the parameter of POTProofVerifier,
+// in which case the fork POs are calculated from the target key
+func (f *ForkPathProof) UnmarshalJSON(data []byte) error {
+	var j forkPathProofJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if j.EntryProof == nil {
+		return fmt.Errorf("unmarshal fork path proof: missing entry proof")
+	}
+	f.RootReference = j.RootReference
+	f.TargetKey = j.TargetKey
+	f.ForkRefProofs = make([]*ForkRefProof, len(j.ForkRefProofs))
+	for i, forkRefProof := range j.ForkRefProofs {
+		f.ForkRefProofs[i] = new(ForkRefProof)
+		f.ForkRefProofs[i].setJSONData(forkRefProof)
+		if forkRefProof.ForkPO == nil {
+			f.ForkRefProofs[i].ForkPO = f.forkPO(i)
+		}
+	}
+	f.EntryProof = new(EntryProof)
+	f.EntryProof.setJSONData(j.EntryProof)
+	f.setIndexes()
+	return nil
+}
+
+// forkPO calculates the PO of the fork of the fork reference proof at the given position from the target key
+func (f *ForkPathProof) forkPO(i int) int {
+	if segments := f.ForkRefProofs[i].BitVectorProof.ProofSegments; len(segments) > 0 {
+		return elements.PO(segments[0], f.TargetKey, 0)
+	}
+	return 0
+}
+
+// MarshalBinary encodes the fork reference proof compactly:
+// the fork PO followed by the proofs of the bitvector and the fork reference, each with its chunk proofs
+func (f *ForkRefProof) MarshalBinary() ([]byte, error) {
+	return f.appendBinary(nil)
+}
+
+// UnmarshalBinary decodes a fork reference proof encoded by MarshalBinary
+func (f *ForkRefProof) UnmarshalBinary(buf []byte) error {
+	d := &decoder{buf: buf}
+	f.decode(d)
+	if err := d.close(); err != nil {
+		return fmt.Errorf("unmarshal fork reference proof: %w", err)
+	}
+	f.setIndexes()
+	return nil
+}
+
+// appendBinary appends the binary encoding of the fork reference proof
+func (f *ForkRefProof) appendBinary(buf []byte) ([]byte, error) {
+	if f.ForkPO < 0 {
+		return nil, errMalformed
+	}
+	buf = binary.AppendUvarint(buf, uint64(f.ForkPO))
+	buf, err := appendProof(buf, f.BitVectorProof, f.BitVectorChunkProofs)
+	if err != nil {
+		return nil, err
+	}
+	return appendProof(buf, f.ForkReferenceProof, f.ForkReferenceChunkProofs)
+}
+
+// decode reads the fork reference proof
+func (f *ForkRefProof) decode(d *decoder) {
+	f.ForkPO = d.count()
+	f.BitVectorProof, f.BitVectorChunkProofs = d.proof()
+	f.ForkReferenceProof, f.ForkReferenceChunkProofs = d.proof()
+}
+
+// MarshalBinary encodes the entry proof compactly: the proofs of the bitvector and the entry,
+// each with its chunk proofs, followed by the number of value proofs and the value proofs
+func (e *EntryProof) MarshalBinary() ([]byte, error) {
+	return e.appendBinary(nil)
+}
+
+// UnmarshalBinary decodes an entry proof encoded by MarshalBinary
+func (e *EntryProof) UnmarshalBinary(buf []byte) error {
+	d := &decoder{buf: buf}
+	e.decode(d)
+	if err := d.close(); err != nil {
+		return fmt.Errorf("unmarshal entry proof: %w", err)
+	}
+	e.setIndexes()
+	return nil
+}
+
+// appendBinary appends the binary encoding of the entry proof
+func (e *EntryProof) appendBinary(buf []byte) ([]byte, error) {
+	buf, err := appendProof(buf, e.BitVectorProof, e.BitVectorChunkProofs)
+	if err != nil {
+		return nil, err
+	}
+	if buf, err = appendProof(buf, e.EntryProof, e.EntryChunkProofs); err != nil {
+		return nil, err
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(e.ValueProofs)))
+	for _, proof := range e.ValueProofs {
+		if buf, err = appendSegmentsProof(buf, proof); err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+// decode reads the entry proof
+func (e *EntryProof) decode(d *decoder) {
+	e.BitVectorProof, e.BitVectorChunkProofs = d.proof()
+	e.EntryProof, e.EntryChunkProofs = d.proof()
+	e.ValueProofs = nil
+	n := d.count()
+	for i := 0; i < n && d.err == nil; i++ {
+		e.ValueProofs = append(e.ValueProofs, d.segmentsProof())
+	}
+}
+
+// MarshalBinary encodes the fork path proof compactly: the root reference, the length of the target key
+// and the target key, the number of fork reference proofs and the fork reference proofs, then the entry proof
+func (f *ForkPathProof) MarshalBinary() ([]byte, error) {
+	if len(f.RootReference) != 32 {
+		return nil, fmt.Errorf("invalid root reference length: %d", len(f.RootReference))
+	}
+	if f.EntryProof == nil {
+		return nil, fmt.Errorf("missing entry proof")
+	}
+	buf := append([]byte{}, f.RootReference...)
+	buf = binary.AppendUvarint(buf, uint64(len(f.TargetKey)))
+	buf = append(buf, f.TargetKey...)
+	buf = binary.AppendUvarint(buf, uint64(len(f.ForkRefProofs)))
+	var err error
+	for i, proof := range f.ForkRefProofs {
+		if proof == nil {
+			return nil, fmt.Errorf("missing fork reference proof at %d", i)
+		}
+		if buf, err = proof.appendBinary(buf); err != nil {
+			return nil, fmt.Errorf("fork reference proof at %d: %w", i, err)
+		}
+	}
+	if buf, err = f.EntryProof.appendBinary(buf); err != nil {
+		return nil, fmt.Errorf("entry proof: %w", err)
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary decodes a fork path proof encoded by MarshalBinary
+func (f *ForkPathProof) UnmarshalBinary(buf []byte) error {
+	d := &decoder{buf: buf}
+	f.RootReference = d.bytes(32)
+	f.TargetKey = d.bytes(d.count())
+	n := d.count()
+	f.ForkRefProofs = make([]*ForkRefProof, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		proof := new(ForkRefProof)
+		proof.decode(d)
+		f.ForkRefProofs = append(f.ForkRefProofs, proof)
+	}
+	f.EntryProof = new(EntryProof)
+	f.EntryProof.decode(d)
+	if err := d.close(); err != nil {
+		return fmt.Errorf("unmarshal fork path proof: %w", err)
+	}
+	f.setIndexes()
+	return nil
+}
+
+// appendProof appends a BMT proof of a chunk, its proven segment, span and 7 sisters,
+// followed by the number of its chunk proofs and their spans and sisters
+func appendProof(buf []byte, proof *bmt.Proof, chunkProofs []*bmt.Proof) ([]byte, error) {
+	if !wellFormed(proof) {
+		return nil, errMalformed
+	}
+	buf = append(buf, proof.ProveSegment...)
+	buf = append(buf, proof.Span...)
+	for _, segment := range proof.ProofSegments {
+		buf = append(buf, segment...)
+	}
+	return appendChunkProofs(buf, chunkProofs)
+}
+
+// appendChunkProofs appends the number of the chunk proofs and their spans and sisters
+func appendChunkProofs(buf []byte, chunkProofs []*bmt.Proof) ([]byte, error) {
+	buf = binary.AppendUvarint(buf, uint64(len(chunkProofs)))
+	for _, proof := range chunkProofs {
+		if _, err := chunkProofSpan(proof); err != nil {
+			return nil, errMalformed
+		}
+		buf = append(buf, proof.Span...)
+		for _, segment := range proof.ProofSegments {
+			if len(segment) != 32 {
+				return nil, errMalformed
+			}
+			buf = append(buf, segment...)
+		}
+	}
+	return buf, nil
+}
+
+// appendSegmentsProof appends a proof of consecutive segments: the number of segments and the segments,
+// the number of sisters and the sisters, the span and the chunk proofs
+func appendSegmentsProof(buf []byte, proof *SegmentsProof) ([]byte, error) {
+	if proof == nil || len(proof.Span) != 8 {
+		return nil, errMalformed
+	}
+	for _, segments := range [][][]byte{proof.Segments, proof.ProofSegments} {
+		buf = binary.AppendUvarint(buf, uint64(len(segments)))
+		for _, segment := range segments {
+			if len(segment) != 32 {
+				return nil, errMalformed
+			}
+			buf = append(buf, segment...)
+		}
+	}
+	buf = append(buf, proof.Span...)
+	return appendChunkProofs(buf, proof.ChunkProofs)
+}
+
+// setIndexes restores the indexes of the proofs of the fork reference proof
+func (f *ForkRefProof) setIndexes() {
+	setProofIndexes(f.BitVectorProof, f.BitVectorChunkProofs, 1)
+	if bitVector := f.BitVectorProof.ProveSegment; len(bitVector) == 32 {
+		setProofIndexes(f.ForkReferenceProof, f.ForkReferenceChunkProofs, 2+countOnesUntil(bitVector, f.ForkPO))
+	}
+}
+
+// setIndexes restores the indexes of the proofs of the entry proof
+// the value proofs take the segments from the entry segment one after the other
+func (e *EntryProof) setIndexes() {
+	setProofIndexes(e.BitVectorProof, e.BitVectorChunkProofs, 1)
+	bitVector := e.BitVectorProof.ProveSegment
+	if len(bitVector) != 32 {
+		return
+	}
+	index := entrySegmentIndexOf(bitVector)
+	setProofIndexes(e.EntryProof, e.EntryChunkProofs, index)
+	for _, proof := range e.ValueProofs {
+		i, indexes, err := locate(index, proof.Span, proof.ChunkProofs)
+		if err != nil {
+			return
+		}
+		proof.Index = i
+		chunkAddress, _ := VerifySegments(*proof)
+		setChunkProofs(chunkAddress, proof.ChunkProofs, indexes)
+		index += len(proof.Segments)
+	}
+}
+
+// setIndexes restores the indexes of the proofs of the fork path proof
+func (f *ForkPathProof) setIndexes() {
+	for _, proof := range f.ForkRefProofs {
+		proof.setIndexes()
+	}
+	f.EntryProof.setIndexes()
+}
+
+// setProofIndexes restores the index of the proof of the segment at the given index of the node data
+// along with the indexes and the proven segments of its chunk proofs
+// proofs not matching the Swarm file tree of a node are left as they are as they do not verify anyway
+func setProofIndexes(proof *bmt.Proof, chunkProofs []*bmt.Proof, index int) {
+	i, indexes, err := locate(index, proof.Span, chunkProofs)
+	if err != nil {
+		return
+	}
+	proof.Index = i
+	chunkAddress, _ := Verify(*proof)
+	setChunkProofs(chunkAddress, chunkProofs, indexes)
+}
+
+// setChunkProofs sets the indexes of the chunk proofs from the parent of the chunk with the given address upwards
+// and their proven segments, the addresses of the chunks below
+func setChunkProofs(chunkAddress []byte, chunkProofs []*bmt.Proof, indexes []int) {
+	for k, proof := range chunkProofs {
+		proof.Index = indexes[k]
+		proof.ProveSegment = chunkAddress
+		chunkAddress, _ = Verify(*proof)
+	}
+}
+
+// errShortBuffer is returned when decoding runs out of data
+var errShortBuffer = errors.New("buffer too short")
+
+// decoder reads the fields of an encoded proof keeping the first error
+type decoder struct {
+	buf []byte
+	err error
+}
+
+// bytes reads n bytes
+func (d *decoder) bytes(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if len(d.buf) < n {
+		d.err = errShortBuffer
+		return nil
+	}
+	b := d.buf[:n:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+// count reads an unsigned varint not greater than the length of the buffer
+// as every counted item takes at least a byte
+func (d *decoder) count() int {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.err = errShortBuffer
+		return 0
+	}
+	d.buf = d.buf[n:]
+	if v > uint64(len(d.buf)) {
+		d.err = errShortBuffer
+		return 0
+	}
+	return int(v)
+}
+
+// segments reads a count followed by that many 32 byte segments
+func (d *decoder) segments() [][]byte {
+	n := d.count()
+	var segments [][]byte
+	for i := 0; i < n && d.err == nil; i++ {
+		segments = append(segments, d.bytes(32))
+	}
+	return segments
+}
+
+// proof reads a BMT proof of a chunk along with its chunk proofs
+func (d *decoder) proof() (*bmt.Proof, []*bmt.Proof) {
+	proof := &bmt.Proof{ProveSegment: d.bytes(32), Span: d.bytes(8)}
+	for i := 0; i < 7 && d.err == nil; i++ {
+		proof.ProofSegments = append(proof.ProofSegments, d.bytes(32))
+	}
+	return proof, d.chunkProofs()
+}
+
+// chunkProofs reads chunk proofs without their proven segments
+func (d *decoder) chunkProofs() []*bmt.Proof {
+	n := d.count()
+	var chunkProofs []*bmt.Proof
+	for i := 0; i < n && d.err == nil; i++ {
+		proof := &bmt.Proof{Span: d.bytes(8)}
+		for j := 0; j < 7 && d.err == nil; j++ {
+			proof.ProofSegments = append(proof.ProofSegments, d.bytes(32))
+		}
+		chunkProofs = append(chunkProofs, proof)
+	}
+	return chunkProofs
+}
+
+// segmentsProof reads a proof of consecutive segments
+func (d *decoder) segmentsProof() *SegmentsProof {
+	return &SegmentsProof{
+		Segments:      d.segments(),
+		ProofSegments: d.segments(),
+		Span:          d.bytes(8),
+		ChunkProofs:   d.chunkProofs(),
+	}
+}
+
+// close checks that the whole buffer is read
+func (d *decoder) close() error {
+	if d.err != nil {
+		return d.err
+	}
+	if len(d.buf) != 0 {
+		return fmt.Errorf("%d trailing bytes", len(d.buf))
+	}
+	return nil
+}
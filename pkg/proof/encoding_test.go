@@ -0,0 +1,302 @@
+package proof_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"testing"
+
+	pot "github.com/ethersphere/proximity-order-trie"
+	"github.com/ethersphere/proximity-order-trie/pkg/elements"
+	"github.com/ethersphere/proximity-order-trie/pkg/persister"
+	"github.com/ethersphere/proximity-order-trie/pkg/proof"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestProofEncoding checks that proofs decoded from their JSON and binary encodings are the ones created
+func TestProofEncoding(t *testing.T) {
+	ctx := context.Background()
+	root, ref, ls, keys := createIndex(t, elements.NewSingleOrder(256), 200, func(i int) []byte {
+		buf := make([]byte, 4)
+		binary.BigEndian.PutUint32(buf, uint32(i))
+		h := sha256.Sum256(buf)
+		return h[:]
+	})
+	multiChunkRoot, multiChunkRef, multiChunkLs, multiChunkKeys := createMultiChunkIndex(t)
+
+	tests := []struct {
+		name string
+		root elements.Node
+		ref  []byte
+		ls   persister.LoadSaver
+		key  []byte
+	}{
+		{name: "deep key", root: root, ref: ref, ls: ls, key: keys[0]},
+		{name: "root key", root: root, ref: ref, ls: ls, key: keys[len(keys)-1]},
+		{name: "multi-chunk root", root: multiChunkRoot, ref: multiChunkRef, ls: multiChunkLs, key: multiChunkKeys[len(multiChunkKeys)-2]},
+		{name: "multi-chunk value", root: multiChunkRoot, ref: multiChunkRef, ls: multiChunkLs, key: multiChunkKeys[20]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := proof.CreateForkPathProof(ctx, tt.root, tt.ls, tt.key)
+			require.NoError(t, err)
+
+			jsonData, err := json.Marshal(p)
+			require.NoError(t, err)
+			fromJSON := new(proof.ForkPathProof)
+			require.NoError(t, json.Unmarshal(jsonData, fromJSON))
+			assert.Equal(t, p, fromJSON)
+
+			binaryData, err := p.MarshalBinary()
+			require.NoError(t, err)
+			fromBinary := new(proof.ForkPathProof)
+			require.NoError(t, fromBinary.UnmarshalBinary(binaryData))
+			assert.Equal(t, p, fromBinary)
+			assert.Less(t, len(binaryData), len(jsonData))
+
+			_, err = fromBinary.VerifyValue(tt.ref)
+			require.NoError(t, err)
+
+			nodeHash := tt.ref
+			for _, forkRefProof := range p.ForkRefProofs {
+				jsonData, err := json.Marshal(forkRefProof)
+				require.NoError(t, err)
+				fromJSON := new(proof.ForkRefProof)
+				require.NoError(t, json.Unmarshal(jsonData, fromJSON))
+				assert.Equal(t, forkRefProof, fromJSON)
+
+				binaryData, err := forkRefProof.MarshalBinary()
+				require.NoError(t, err)
+				fromBinary := new(proof.ForkRefProof)
+				require.NoError(t, fromBinary.UnmarshalBinary(binaryData))
+				assert.Equal(t, forkRefProof, fromBinary)
+				nodeHash = forkRefProof.ForkReferenceProof.ProveSegment
+			}
+
+			jsonData, err = json.Marshal(p.EntryProof)
+			require.NoError(t, err)
+			entryFromJSON := new(proof.EntryProof)
+			require.NoError(t, json.Unmarshal(jsonData, entryFromJSON))
+			assert.Equal(t, p.EntryProof, entryFromJSON)
+
+			binaryData, err = p.EntryProof.MarshalBinary()
+			require.NoError(t, err)
+			entryFromBinary := new(proof.EntryProof)
+			require.NoError(t, entryFromBinary.UnmarshalBinary(binaryData))
+			assert.Equal(t, p.EntryProof, entryFromBinary)
+			require.NoError(t, proof.ValidateEntryProof(nodeHash, entryFromBinary))
+		})
+	}
+}
+
+// TestProofEncodingContractSamples checks that the parameters of the POTProofVerifier tests decode to valid proofs
+func TestProofEncodingContractSamples(t *testing.T) {
+	read := func(t *testing.T, name string) []byte {
+		t.Helper()
+		data, err := os.ReadFile("../../blockchain/test/" + name)
+		require.NoError(t, err)
+		return data
+	}
+	verify := func(t *testing.T, p *proof.ForkPathProof) {
+		t.Helper()
+		require.NoError(t, p.Verify(p.RootReference))
+		// the indexes of the segments the contract calculates are restored
+		nodeHash := p.RootReference
+		if n := len(p.ForkRefProofs); n > 0 {
+			nodeHash = p.ForkRefProofs[n-1].ForkReferenceProof.ProveSegment
+		}
+		require.NoError(t, proof.ValidateEntryProof(nodeHash, p.EntryProof))
+	}
+
+	t.Run("fork path proof", func(t *testing.T) {
+		p := new(proof.ForkPathProof)
+		require.NoError(t, json.Unmarshal(read(t, "forkPathProofSample.json"), p))
+		verify(t, p)
+	})
+
+	for _, name := range []string{"valueProofSample.json", "multiChunkProofSample.json"} {
+		t.Run(name, func(t *testing.T) {
+			var sample struct {
+				ForkPathProof map[string]any
+				ValueProofs   any
+				Value         string
+			}
+			require.NoError(t, json.Unmarshal(read(t, name), &sample))
+			// the value proofs passed next to the fork path proof are the ones of its entry proof
+			sample.ForkPathProof["entryProof"].(map[string]any)["valueProofs"] = sample.ValueProofs
+			data, err := json.Marshal(sample.ForkPathProof)
+			require.NoError(t, err)
+			p := new(proof.ForkPathProof)
+			require.NoError(t, json.Unmarshal(data, p))
+			verify(t, p)
+			value, err := p.VerifyValue(p.RootReference)
+			require.NoError(t, err)
+			assert.Equal(t, sample.Value, "0x"+hex.EncodeToString(value))
+		})
+	}
+
+	// the multi-chunk samples are the proofs of the multi-chunk index in the form of the contract parameters
+	root, ref, ls, keys := createMultiChunkIndex(t)
+	params := func(t *testing.T, data []byte) any {
+		t.Helper()
+		var v any
+		require.NoError(t, json.Unmarshal(data, &v))
+		return withoutForkPOs(v)
+	}
+	// encoded returns the JSON values of a proof
+	encoded := func(t *testing.T, encode func() (string, error)) []byte {
+		t.Helper()
+		s, err := encode()
+		require.NoError(t, err)
+		return []byte(s)
+	}
+
+	t.Run("multi-chunk absence proof", func(t *testing.T) {
+		key := make([]byte, 32)
+		key[31] = 0x20 // differs from the root key at PO 250, beyond the forks of the root
+		p, err := proof.CreateAbsenceProof(context.Background(), root, ls, key)
+		require.NoError(t, err)
+		require.NoError(t, p.Verify(ref))
+		require.NotEmpty(t, p.BitVectorChunkProofs)
+		assert.Equal(t, params(t, encoded(t, p.JSON)), params(t, read(t, "multiChunkAbsenceProofSample.json")))
+	})
+
+	t.Run("multi-chunk size proof", func(t *testing.T) {
+		p, err := proof.CreateSizeProof(root)
+		require.NoError(t, err)
+		size, err := p.Verify(ref)
+		require.NoError(t, err)
+		assert.Equal(t, 201, size)
+		require.NotEmpty(t, p.ForkSizeProofs[0].ChunkProofs)
+		assert.Equal(t, params(t, encoded(t, p.JSON)), params(t, read(t, "multiChunkSizeProofSample.json")))
+	})
+
+	t.Run("multi-chunk rank proof", func(t *testing.T) {
+		p, err := proof.CreateRankProof(context.Background(), root, ls, keys[5])
+		require.NoError(t, err)
+		rank, size, err := p.Verify(ref)
+		require.NoError(t, err)
+		assert.Equal(t, 195, rank)
+		assert.Equal(t, 201, size)
+		assert.Equal(t, params(t, encoded(t, p.JSON)), params(t, read(t, "multiChunkRankProofSample.json")))
+	})
+}
+
+// withoutForkPOs removes the fork POs from the JSON values of a proof as the contract calculates them
+func withoutForkPOs(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		delete(v, "forkPO")
+		for _, e := range v {
+			withoutForkPOs(e)
+		}
+	case []any:
+		for _, e := range v {
+			withoutForkPOs(e)
+		}
+	}
+	return v
+}
+
+// TestProofEncodingInvalid checks that malformed encodings are rejected
+func TestProofEncodingInvalid(t *testing.T) {
+	ctx := context.Background()
+	root, _, ls, keys := createIndex(t, elements.NewSingleOrder(256), 20, func(i int) []byte {
+		buf := make([]byte, 4)
+		binary.BigEndian.PutUint32(buf, uint32(i))
+		h := sha256.Sum256(buf)
+		return h[:]
+	})
+	p, err := proof.CreateForkPathProof(ctx, root, ls, keys[0])
+	require.NoError(t, err)
+	require.NotEmpty(t, p.ForkRefProofs)
+
+	t.Run("truncated binary", func(t *testing.T) {
+		binaryData, err := p.MarshalBinary()
+		require.NoError(t, err)
+		for _, n := range []int{0, 31, 33, len(binaryData) / 2, len(binaryData) - 1} {
+			assert.Error(t, new(proof.ForkPathProof).UnmarshalBinary(binaryData[:n]))
+		}
+		assert.Error(t, new(proof.ForkPathProof).UnmarshalBinary(append(binaryData, 0)))
+	})
+
+	t.Run("malformed proof", func(t *testing.T) {
+		malformed := *p.ForkRefProofs[0]
+		bitVectorProof := *malformed.BitVectorProof
+		bitVectorProof.ProofSegments = bitVectorProof.ProofSegments[:6]
+		malformed.BitVectorProof = &bitVectorProof
+		_, err := malformed.MarshalBinary()
+		assert.Error(t, err)
+	})
+
+	t.Run("missing entry proof", func(t *testing.T) {
+		_, err := json.Marshal(&proof.ForkPathProof{RootReference: p.RootReference, TargetKey: p.TargetKey})
+		assert.Error(t, err)
+		assert.Error(t, json.Unmarshal([]byte(`{"rootReference": "0x00", "forkRefProofs": []}`), new(proof.ForkPathProof)))
+	})
+
+	t.Run("missing fork PO", func(t *testing.T) {
+		jsonData, err := json.Marshal(p.ForkRefProofs[0])
+		require.NoError(t, err)
+		var fields map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(jsonData, &fields))
+		delete(fields, "forkPO")
+		jsonData, err = json.Marshal(fields)
+		require.NoError(t, err)
+		assert.Error(t, json.Unmarshal(jsonData, new(proof.ForkRefProof)))
+	})
+
+	t.Run("invalid hex", func(t *testing.T) {
+		assert.Error(t, json.Unmarshal([]byte(`{"rootReference": "0xzz", "entryProof": {}}`), new(proof.ForkPathProof)))
+	})
+}
+
+// createMultiChunkIndex persists an index whose root node is stored in multiple chunks along with nodes
+// holding long values and returns its loaded root along with the keys, the one of the root node last
+func createMultiChunkIndex(t *testing.T) (elements.Node, []byte, persister.LoadSaver, [][]byte) {
+	t.Helper()
+	ctx := context.Background()
+	ls := persister.NewInmemLoadSaver()
+	newf := func(key []byte) elements.Entry {
+		e, _ := pot.NewSwarmEntry(key, nil)
+		return e
+	}
+	idx, err := pot.New(elements.NewSwarmPot(elements.NewSingleOrder(256), ls, newf))
+	require.NoError(t, err)
+	defer idx.Close()
+
+	// keys differing from the zero key at a single bit are all forks of the zero key's node
+	// which is added last so that it takes the root
+	var keys [][]byte
+	for po := 0; po < 200; po++ {
+		key := make([]byte, 32)
+		key[po/8] = 1 << (7 - po%8)
+		keys = append(keys, key)
+	}
+	keys = append(keys, make([]byte, 32))
+	for i, key := range keys {
+		length := 64
+		switch i {
+		case len(keys) - 1:
+			length = 5000
+		case 20:
+			length = persister.ChunkSize*persister.Branches + 100
+		}
+		value := make([]byte, length)
+		for j := range value {
+			value[j] = byte(i + j)
+		}
+		e, err := pot.NewSwarmEntry(key, value)
+		require.NoError(t, err)
+		require.NoError(t, idx.Add(ctx, e))
+	}
+	ref, err := idx.Save(ctx)
+	require.NoError(t, err)
+	root, _, err := elements.NewSwarmPotReference(elements.NewSingleOrder(256), ls, ref, newf).Load(ctx, ref)
+	require.NoError(t, err)
+	return root, ref, ls, keys
+}
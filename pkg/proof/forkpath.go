@@ -1,10 +1,11 @@
 package proof
 
 import (
+	"bytes"
 	"context"
-	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/ethersphere/proximity-order-trie/pkg/elements"
@@ -26,25 +27,35 @@ type ForkPathProof struct {
 // CreateForkPathProof generates a path of proofs from the root node to the target key.
 // It iteratively loads nodes and creates proofs until it reaches the target key or encounters an error.
 func CreateForkPathProof(ctx context.Context, rootNode elements.Node, ls persister.LoadSaver, targetKey []byte) (*ForkPathProof, error) {
+	path, nodesData, err := forkPath(ctx, rootNode, ls, targetKey)
+	if err != nil {
+		return nil, err
+	}
+	entryProof, err := CreateEntryProof(nodesData[len(nodesData)-1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create entry proof: %w", err)
+	}
+	path.EntryProof = entryProof
+	return path, nil
+}
+
+// forkPath follows the forks matching the target key from the root node and returns the path
+// of fork proofs along with the data of the nodes on the path, the last one holding the target key.
+// If the key is not in the pot, the error wraps ErrForkNotFound and the data of the nodes up to the last one is returned.
+func forkPath(ctx context.Context, rootNode elements.Node, ls persister.LoadSaver, targetKey []byte) (*ForkPathProof, [][]byte, error) {
 	if rootNode == nil {
-		return nil, fmt.Errorf("root node is nil")
+		return nil, nil, fmt.Errorf("root node is nil")
 	}
 	if ls == nil {
-		return nil, fmt.Errorf("load saver is nil")
+		return nil, nil, fmt.Errorf("load saver is nil")
 	}
 	if len(targetKey) == 0 {
-		return nil, fmt.Errorf("target key is empty")
+		return nil, nil, fmt.Errorf("target key is empty")
 	}
 
-	// Get the Swarm reference from the root node
-	swarmNode, ok := rootNode.(*elements.SwarmNode)
-	if !ok {
-		return nil, fmt.Errorf("root node is not a SwarmNode")
-	}
-
-	rootRef := swarmNode.Reference()
-	if len(rootRef) == 0 {
-		return nil, fmt.Errorf("root node has no reference")
+	rootRef, currentNodeData, err := rootNodeData(rootNode)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	// Initialize the path
@@ -54,28 +65,21 @@ func CreateForkPathProof(ctx context.Context, rootNode elements.Node, ls persist
 		TargetKey:     targetKey,
 	}
 
-	// Load the initial node data
-	currentNodeData, err := swarmNode.MarshalBinary()
-	if err != nil {
-		return nil, fmt.Errorf("failed to load root node: %w", err)
-	}
-
 	// Iteratively create proofs and load nodes
+	var nodesData [][]byte
 	for {
+		nodesData = append(nodesData, currentNodeData)
 		// Create a proof for the current node
 		proof, err := CreateForkNodeProof(currentNodeData, targetKey)
 		if err != nil {
 			// If we've reached the target key, we're done
-			if err.Error() == "parent key and target key are the same" {
-				// Save the final node data
-				entryProof, err := CreateEntryProof(currentNodeData)
-				if err != nil {
-					return nil, fmt.Errorf("failed to create entry proof: %w", err)
-				}
-				path.EntryProof = entryProof
-				break
+			if errors.Is(err, ErrTargetReached) {
+				return path, nodesData, nil
 			}
-			return nil, fmt.Errorf("failed to create fork node proof: %w", err)
+			if errors.Is(err, ErrForkNotFound) {
+				return path, nodesData, fmt.Errorf("failed to create fork node proof: %w", err)
+			}
+			return nil, nil, fmt.Errorf("failed to create fork node proof: %w", err)
 		}
 		path.ForkRefProofs = append(path.ForkRefProofs, proof)
 
@@ -83,78 +87,111 @@ func CreateForkPathProof(ctx context.Context, rootNode elements.Node, ls persist
 
 		nextNodeData, err := ls.Load(ctx, forkRef)
 		if err != nil {
-			return nil, fmt.Errorf("failed to load next node with reference %x: %w", forkRef, err)
+			return nil, nil, fmt.Errorf("failed to load next node with reference %x: %w", forkRef, err)
 		}
 		currentNodeData = nextNodeData
 	}
+}
 
-	return path, nil
+// rootNodeData returns the reference and the data of the persisted root node
+func rootNodeData(rootNode elements.Node) ([]byte, []byte, error) {
+	// Get the Swarm reference from the root node
+	swarmNode, ok := rootNode.(*elements.SwarmNode)
+	if !ok {
+		return nil, nil, fmt.Errorf("root node is not a SwarmNode")
+	}
+
+	rootRef := swarmNode.Reference()
+	if len(rootRef) == 0 {
+		return nil, nil, fmt.Errorf("root node has no reference")
+	}
+
+	// Load the initial node data
+	rootData, err := swarmNode.MarshalBinary()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load root node: %w", err)
+	}
+	return rootRef, rootData, nil
 }
 
-// return hexified JSON values used as smart contract validation parameter
-func (f *ForkPathProof) JSON() string {
-	proofsData := map[string]interface{}{
-		"rootReference": "0x" + hex.EncodeToString(f.RootReference),
-		"entryProof": map[string]interface{}{
-			"entryProof": map[string]interface{}{
-				"proveSegment": "0x" + hex.EncodeToString(f.EntryProof.EntryProof.ProveSegment),
-				"proofSegments": func() []string {
-					segments := make([]string, len(f.EntryProof.EntryProof.ProofSegments))
-					for i, segment := range f.EntryProof.EntryProof.ProofSegments {
-						segments[i] = "0x" + hex.EncodeToString(segment)
-					}
-					return segments
-				}(),
-				"chunkSpan": binary.LittleEndian.Uint64(f.EntryProof.EntryProof.Span),
-			},
-			"bitVectorProof": map[string]interface{}{
-				"proveSegment": "0x" + hex.EncodeToString(f.EntryProof.BitVectorProof.ProveSegment),
-				"proofSegments": func() []string {
-					segments := make([]string, len(f.EntryProof.BitVectorProof.ProofSegments))
-					for i, segment := range f.EntryProof.BitVectorProof.ProofSegments {
-						segments[i] = "0x" + hex.EncodeToString(segment)
-					}
-					return segments
-				}(),
-				"chunkSpan": binary.LittleEndian.Uint64(f.EntryProof.BitVectorProof.Span),
-			},
-		},
-		"forkRefProofs": func() []map[string]interface{} {
-			forkRefProofs := make([]map[string]interface{}, len(f.ForkRefProofs))
-			for i, forkRefProof := range f.ForkRefProofs {
-				forkRefProofs[i] = map[string]interface{}{
-					"forkReferenceProof": map[string]interface{}{
-						"proveSegment": "0x" + hex.EncodeToString(forkRefProof.ForkReferenceProof.ProveSegment),
-						"proofSegments": func() []string {
-							segments := make([]string, len(forkRefProof.ForkReferenceProof.ProofSegments))
-							for j, segment := range forkRefProof.ForkReferenceProof.ProofSegments {
-								segments[j] = "0x" + hex.EncodeToString(segment)
-							}
-							return segments
-						}(),
-						"chunkSpan": binary.LittleEndian.Uint64(forkRefProof.ForkReferenceProof.Span),
-					},
-					"bitVectorProof": map[string]interface{}{
-						"proveSegment": "0x" + hex.EncodeToString(forkRefProof.BitVectorProof.ProveSegment),
-						"proofSegments": func() []string {
-							segments := make([]string, len(forkRefProof.BitVectorProof.ProofSegments))
-							for j, segment := range forkRefProof.BitVectorProof.ProofSegments {
-								segments[j] = "0x" + hex.EncodeToString(segment)
-							}
-							return segments
-						}(),
-						"chunkSpan": binary.LittleEndian.Uint64(forkRefProof.BitVectorProof.Span),
-					},
-				}
-			}
-			return forkRefProofs
-		}(),
-		"targetKey": "0x" + hex.EncodeToString(f.TargetKey),
+// Verify checks the proof against the root reference the same way as POTProofVerifier.assertForkPathProof does:
+// the entry key must be the target key, each fork on the path must be set in the bitvector of its parent at the PO
+// of the parent's key and the target key and proven to be at the segment given by its rank in the bitvector,
+// and the entry must be proven to be at the segment following the forks of the last node.
+func (f *ForkPathProof) Verify(rootRef []byte) error {
+	_, _, err := f.verify(rootRef)
+	return err
+}
+
+// VerifyValue checks the proof against the root reference the same way as POTProofVerifier.assertForkPathValueProof does
+// and returns the entry value rebuilt from the value proof: on top of Verify, the value proof must cover the segments
+// from the entry segment until the end of the node holding the entry.
+func (f *ForkPathProof) VerifyValue(rootRef []byte) ([]byte, error) {
+	nodeHash, entrySegmentIndex, err := f.verify(rootRef)
+	if err != nil {
+		return nil, err
+	}
+	span := nodeSpan(f.EntryProof.EntryProof.Span, f.EntryProof.EntryChunkProofs)
+	if err := verifyValue(nodeHash, entrySegmentIndex, span, f.EntryProof.ValueProofs); err != nil {
+		return nil, fmt.Errorf("%w: invalid value proof", err)
+	}
+	return f.EntryProof.Value(), nil
+}
+
+// verify checks the proof and returns the hash of the node holding the entry and the index of the entry segment
+func (f *ForkPathProof) verify(rootRef []byte) ([]byte, int, error) {
+	if !bytes.Equal(f.RootReference, rootRef) {
+		return nil, 0, fmt.Errorf("%w: root reference mismatch", ErrInvalidProof)
+	}
+	if len(f.TargetKey) != 32 {
+		return nil, 0, fmt.Errorf("%w: invalid target key length: %d", ErrInvalidProof, len(f.TargetKey))
+	}
+	if f.EntryProof == nil || !wellFormed(f.EntryProof.BitVectorProof) || !wellFormed(f.EntryProof.EntryProof) {
+		return nil, 0, fmt.Errorf("%w: malformed entry proof", ErrInvalidProof)
+	}
+	if !bytes.Equal(f.EntryProof.BitVectorProof.ProofSegments[0], f.TargetKey) {
+		return nil, 0, fmt.Errorf("%w: entry key does not match target key", ErrInvalidProof)
+	}
+
+	nodeHash, _, err := verifyForkRefProofs(rootRef, f.TargetKey, f.ForkRefProofs)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	entrySegmentIndex := entrySegmentIndexOf(f.EntryProof.BitVectorProof.ProveSegment)
+	if err := verifyAt(nodeHash, *f.EntryProof.BitVectorProof, 1, f.EntryProof.BitVectorChunkProofs); err != nil {
+		return nil, 0, fmt.Errorf("%w: invalid bit vector proof of the entry", ErrInvalidProof)
+	}
+	if err := verifyAt(nodeHash, *f.EntryProof.EntryProof, entrySegmentIndex, f.EntryProof.EntryChunkProofs); err != nil {
+		return nil, 0, fmt.Errorf("%w: invalid entry proof", ErrInvalidProof)
+	}
+	return nodeHash, entrySegmentIndex, nil
+}
+
+// JSON returns hexified JSON values used as smart contract validation parameter
+func (f *ForkPathProof) JSON() (string, error) {
+	jsonProofsData, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(jsonProofsData), nil
+}
+
+// ValueJSON returns hexified JSON values of the proof along with the value proof
+// used as parameters of POTProofVerifier.assertForkPathValueProof
+func (f *ForkPathProof) ValueJSON() (string, error) {
+	if f.EntryProof == nil {
+		return "", fmt.Errorf("missing entry proof")
+	}
+	valueData := map[string]interface{}{
+		"forkPathProof": f,
+		"valueProofs":   segmentsProofsJSON(f.EntryProof.ValueProofs),
+		"value":         "0x" + hex.EncodeToString(f.EntryProof.Value()),
 	}
 
-	jsonProofsData, err := json.MarshalIndent(proofsData, "", "  ")
+	jsonValueData, err := json.MarshalIndent(valueData, "", "  ")
 	if err != nil {
-		return ""
+		return "", err
 	}
-	return string(jsonProofsData)
+	return string(jsonValueData), nil
 }
@@ -1,8 +1,12 @@
 package proof_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
+	"math/bits"
 	"testing"
+	"time"
 
 	pot "github.com/ethersphere/proximity-order-trie"
 	"github.com/ethersphere/proximity-order-trie/pkg/elements"
@@ -147,6 +151,367 @@ func TestForkPathProof(t *testing.T) {
 	}
 }
 
+// TestForkPathProofVerify tests the verification of fork path proofs
+// with the same cases as the POTProofVerifier contract tests
+func TestForkPathProofVerify(t *testing.T) {
+	ls := persister.NewInmemLoadSaver()
+	ctx := context.Background()
+	root, keys := createTestTrie(t, ls, 2)
+	rootRef := root.(*elements.SwarmNode).Reference()
+
+	for _, key := range keys {
+		proofs, err := proof.CreateForkPathProof(ctx, root, ls, key)
+		require.NoError(t, err)
+		require.NoError(t, proofs.Verify(rootRef))
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*proof.ForkPathProof)
+	}{
+		{
+			name:   "entry key does not match target key",
+			modify: func(p *proof.ForkPathProof) { p.TargetKey = append([]byte{0x00, 0x20}, make([]byte, 30)...) },
+		},
+		{
+			name:   "fork is not set in the parent's bitvector",
+			modify: func(p *proof.ForkPathProof) { p.ForkRefProofs[0].BitVectorProof.ProveSegment = make([]byte, 32) },
+		},
+		{
+			name:   "invalid fork reference proof",
+			modify: func(p *proof.ForkPathProof) { p.ForkRefProofs[0].ForkReferenceProof.ProveSegment = make([]byte, 32) },
+		},
+		{
+			name: "invalid bit vector proof of a fork",
+			modify: func(p *proof.ForkPathProof) {
+				p.ForkRefProofs[0].BitVectorProof.ProveSegment = append([]byte{0xc0}, make([]byte, 31)...)
+			},
+		},
+		{
+			name:   "invalid entry proof",
+			modify: func(p *proof.ForkPathProof) { p.EntryProof.EntryProof.ProveSegment = make([]byte, 32) },
+		},
+		{
+			name: "invalid bit vector proof of the entry",
+			modify: func(p *proof.ForkPathProof) {
+				p.EntryProof.BitVectorProof.ProveSegment = bytes.Repeat([]byte{0x11}, 32)
+			},
+		},
+		{
+			name:   "missing fork",
+			modify: func(p *proof.ForkPathProof) { p.ForkRefProofs = p.ForkRefProofs[1:] },
+		},
+		{
+			name:   "malformed proof",
+			modify: func(p *proof.ForkPathProof) { p.EntryProof.EntryProof.ProofSegments = nil },
+		},
+		{
+			name:   "root reference mismatch",
+			modify: func(p *proof.ForkPathProof) { p.RootReference = p.ForkRefProofs[0].ForkReferenceProof.ProveSegment },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proofs, err := proof.CreateForkPathProof(ctx, root, ls, keys[2])
+			require.NoError(t, err)
+			tt.modify(proofs)
+			assert.ErrorIs(t, proofs.Verify(rootRef), proof.ErrInvalidProof)
+		})
+	}
+}
+
+// TestForkPathProofValue tests that the whole value of entries spanning multiple segments is proven
+func TestForkPathProofValue(t *testing.T) {
+	ctx := context.Background()
+	ls := persister.NewInmemLoadSaver()
+	newf := func(key []byte) elements.Entry {
+		e, _ := pot.NewSwarmEntry(key, nil)
+		return e
+	}
+	idx, err := pot.New(elements.NewSwarmPot(elements.NewSingleOrder(256), ls, newf))
+	require.NoError(t, err)
+	defer idx.Close()
+
+	lengths := []int{1, 31, 32, 33, 64, 100, 150, 200}
+	keys := make([][]byte, len(lengths))
+	values := make([][]byte, len(lengths))
+	for i, length := range lengths {
+		keys[i] = make([]byte, 32)
+		keys[i][0] = byte(i * 37)
+		values[i] = make([]byte, length)
+		for j := range values[i] {
+			values[i][j] = byte(i + j + 1)
+		}
+		e, err := pot.NewSwarmEntry(keys[i], values[i])
+		require.NoError(t, err)
+		require.NoError(t, idx.Add(ctx, e))
+	}
+	ref, err := idx.Save(ctx)
+	require.NoError(t, err)
+	root, _, err := elements.NewSwarmPotReference(elements.NewSingleOrder(256), ls, ref, newf).Load(ctx, ref)
+	require.NoError(t, err)
+
+	for i, key := range keys {
+		proofs, err := proof.CreateForkPathProof(ctx, root, ls, key)
+		require.NoError(t, err)
+		value, err := proofs.VerifyValue(ref)
+		require.NoError(t, err)
+		assert.Equal(t, values[i], value)
+		require.Len(t, proofs.EntryProof.ValueProofs, 1)
+		assert.Len(t, proofs.EntryProof.ValueProofs[0].Segments, (lengths[i]+31)/32)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*proof.ForkPathProof)
+	}{
+		{
+			name:   "missing value proof",
+			modify: func(p *proof.ForkPathProof) { p.EntryProof.ValueProofs = nil },
+		},
+		{
+			name:   "invalid value segment",
+			modify: func(p *proof.ForkPathProof) { p.EntryProof.ValueProofs[0].Segments[1] = make([]byte, 32) },
+		},
+		{
+			name: "missing value segment",
+			modify: func(p *proof.ForkPathProof) {
+				p.EntryProof.ValueProofs[0].Segments = p.EntryProof.ValueProofs[0].Segments[:len(p.EntryProof.ValueProofs[0].Segments)-1]
+			},
+		},
+		{
+			name:   "value proof not at the entry segment",
+			modify: func(p *proof.ForkPathProof) { p.EntryProof.ValueProofs[0].Index++ },
+		},
+		{
+			name: "invalid proof segment",
+			modify: func(p *proof.ForkPathProof) {
+				p.EntryProof.ValueProofs[0].ProofSegments[0] = bytes.Repeat([]byte{0xff}, 32)
+			},
+		},
+		{
+			name: "missing proof segment",
+			modify: func(p *proof.ForkPathProof) {
+				p.EntryProof.ValueProofs[0].ProofSegments = p.EntryProof.ValueProofs[0].ProofSegments[1:]
+			},
+		},
+		{
+			name: "span extended",
+			modify: func(p *proof.ForkPathProof) {
+				span := binary.LittleEndian.Uint64(p.EntryProof.ValueProofs[0].Span)
+				p.EntryProof.ValueProofs[0].Span = binary.LittleEndian.AppendUint64(nil, span+1)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proofs, err := proof.CreateForkPathProof(ctx, root, ls, keys[len(keys)-1])
+			require.NoError(t, err)
+			tt.modify(proofs)
+			require.NoError(t, proofs.Verify(ref))
+			_, err = proofs.VerifyValue(ref)
+			assert.ErrorIs(t, err, proof.ErrInvalidProof)
+		})
+	}
+}
+
+// TestForkPathProofMultiChunk tests proofs in nodes that are stored in multiple chunks
+// because of their many forks or their long values
+func TestForkPathProofMultiChunk(t *testing.T) {
+	ctx := context.Background()
+	ls := persister.NewInmemLoadSaver()
+	newf := func(key []byte) elements.Entry {
+		e, _ := pot.NewSwarmEntry(key, nil)
+		return e
+	}
+	idx, err := pot.New(elements.NewSwarmPot(elements.NewSingleOrder(256), ls, newf))
+	require.NoError(t, err)
+	defer idx.Close()
+
+	// keys differing from the zero key at a single bit are all forks of the zero key's node
+	// which is added last so that it takes the root
+	values := make(map[string][]byte)
+	var keys [][]byte
+	for po := 0; po < 200; po++ {
+		key := make([]byte, 32)
+		key[po/8] = 1 << (7 - po%8)
+		keys = append(keys, key)
+	}
+	keys = append(keys, make([]byte, 32))
+	for i, key := range keys {
+		length := 64
+		switch i {
+		case len(keys) - 1:
+			length = 5000
+		case 10:
+			length = 10000
+		case 20:
+			length = persister.ChunkSize*persister.Branches + 100
+		}
+		value := make([]byte, length)
+		for j := range value {
+			value[j] = byte(i + j)
+		}
+		values[string(key)] = value
+		e, err := pot.NewSwarmEntry(key, value)
+		require.NoError(t, err)
+		require.NoError(t, idx.Add(ctx, e))
+	}
+	ref, err := idx.Save(ctx)
+	require.NoError(t, err)
+	root, _, err := elements.NewSwarmPotReference(elements.NewSingleOrder(256), ls, ref, newf).Load(ctx, ref)
+	require.NoError(t, err)
+	rootData, err := root.(*elements.SwarmNode).MarshalBinary()
+	require.NoError(t, err)
+	require.Greater(t, len(rootData), persister.ChunkSize)
+
+	for _, key := range keys {
+		proofs, err := proof.CreateForkPathProof(ctx, root, ls, key)
+		require.NoError(t, err)
+		require.NoError(t, proofs.Verify(ref))
+		value, err := proofs.VerifyValue(ref)
+		require.NoError(t, err)
+		assert.Equal(t, values[string(key)], value)
+		nodeHash := ref
+		if n := len(proofs.ForkRefProofs); n > 0 {
+			nodeHash = proofs.ForkRefProofs[n-1].ForkReferenceProof.ProveSegment
+		}
+		require.NoError(t, proof.ValidateEntryProof(nodeHash, proofs.EntryProof))
+	}
+
+	absentKey := make([]byte, 32)
+	absentKey[25] = 0x03
+	absence, err := proof.CreateAbsenceProof(ctx, root, ls, absentKey)
+	require.NoError(t, err)
+	require.NoError(t, absence.Verify(ref))
+
+	tests := []struct {
+		name   string
+		modify func(*proof.ForkPathProof)
+	}{
+		{
+			name:   "missing chunk proof",
+			modify: func(p *proof.ForkPathProof) { p.ForkRefProofs[0].ForkReferenceChunkProofs = nil },
+		},
+		{
+			name: "invalid chunk proof",
+			modify: func(p *proof.ForkPathProof) {
+				p.ForkRefProofs[0].ForkReferenceChunkProofs[0].ProofSegments[0] = bytes.Repeat([]byte{0xff}, 32)
+			},
+		},
+		{
+			name: "invalid chunk span",
+			modify: func(p *proof.ForkPathProof) {
+				p.ForkRefProofs[0].ForkReferenceChunkProofs[0].Span = binary.LittleEndian.AppendUint64(nil, 3*persister.ChunkSize)
+			},
+		},
+		{
+			name: "fork reference proven in another chunk",
+			modify: func(p *proof.ForkPathProof) {
+				p.ForkRefProofs[0].ForkReferenceProof = p.ForkRefProofs[0].BitVectorProof
+				p.ForkRefProofs[0].ForkReferenceChunkProofs = p.ForkRefProofs[0].BitVectorChunkProofs
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the fork at the highest PO is referenced in the second chunk of the root node
+			proofs, err := proof.CreateForkPathProof(ctx, root, ls, keys[len(keys)-2])
+			require.NoError(t, err)
+			require.NotEmpty(t, proofs.ForkRefProofs[0].ForkReferenceChunkProofs)
+			tt.modify(proofs)
+			assert.ErrorIs(t, proofs.Verify(ref), proof.ErrInvalidProof)
+		})
+	}
+
+	t.Run("huge chunk span", func(t *testing.T) {
+		// spans beyond the Swarm file trees proofs may have are rejected rather than located in a tree
+		// whose capacity overflows 64 bits
+		huge := binary.LittleEndian.AppendUint64(nil, 1<<62)
+		// the zero key is pinned to the root node, which is stored in multiple chunks
+		proofs, err := proof.CreateForkPathProof(ctx, root, ls, keys[len(keys)-1])
+		require.NoError(t, err)
+		require.NotEmpty(t, proofs.EntryProof.EntryChunkProofs)
+		proofs.EntryProof.EntryChunkProofs[0].Span = huge
+		withinDeadline(t, func() {
+			assert.ErrorIs(t, proofs.Verify(ref), proof.ErrInvalidProof)
+			data, err := proofs.MarshalBinary()
+			require.NoError(t, err)
+			decoded := new(proof.ForkPathProof)
+			if err := decoded.UnmarshalBinary(data); err == nil {
+				assert.ErrorIs(t, decoded.Verify(ref), proof.ErrInvalidProof)
+			}
+		})
+
+		absence, err := proof.CreateAbsenceProof(ctx, root, ls, absentKey)
+		require.NoError(t, err)
+		require.NotEmpty(t, absence.BitVectorChunkProofs)
+		absence.BitVectorChunkProofs[0].Span = huge
+		withinDeadline(t, func() {
+			assert.ErrorIs(t, absence.Verify(ref), proof.ErrInvalidProof)
+		})
+
+		rank, err := proof.CreateRankProof(ctx, root, ls, keys[len(keys)-2])
+		require.NoError(t, err)
+		require.NotEmpty(t, rank.ForkPathProof.ForkRefProofs[0].ForkReferenceChunkProofs)
+		rank.ForkPathProof.ForkRefProofs[0].ForkReferenceChunkProofs[0].Span = huge
+		withinDeadline(t, func() {
+			_, _, err := rank.Verify(ref)
+			assert.ErrorIs(t, err, proof.ErrInvalidProof)
+		})
+	})
+}
+
+// withinDeadline fails the test if f does not return in time instead of letting it hang
+func withinDeadline(t *testing.T, f func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f()
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("did not return in time")
+	}
+}
+
+// TestForkPathProofBalanced checks that sequential keys get short proofs in a balanced pot
+func TestForkPathProofBalanced(t *testing.T) {
+	ctx := context.Background()
+	ls := persister.NewInmemLoadSaver()
+	newf := func(key []byte) elements.Entry {
+		e, _ := pot.NewSwarmEntry(key, nil)
+		return e
+	}
+	idx, err := pot.New(elements.NewSwarmPot(elements.NewBalanced(256), ls, newf))
+	require.NoError(t, err)
+	defer idx.Close()
+
+	count := 1000
+	keys := make([][]byte, count)
+	for i := range keys {
+		keys[i] = make([]byte, 32)
+		binary.BigEndian.PutUint32(keys[i][28:], uint32(i))
+		e, err := pot.NewSwarmEntry(keys[i], []byte{byte(i)})
+		require.NoError(t, err)
+		require.NoError(t, idx.Add(ctx, e))
+	}
+	ref, err := idx.Save(ctx)
+	require.NoError(t, err)
+	root, _, err := elements.NewSwarmPotReference(elements.NewBalanced(256), ls, ref, newf).Load(ctx, ref)
+	require.NoError(t, err)
+
+	for i, key := range keys {
+		proofs, err := proof.CreateForkPathProof(ctx, root, ls, key)
+		require.NoError(t, err)
+		// the path to a key only branches off at its 1 bits
+		assert.LessOrEqual(t, len(proofs.ForkRefProofs), bits.OnesCount32(uint32(i)))
+		require.NoError(t, proofs.Verify(ref))
+	}
+}
+
 // createTestTrie creates a test trie with a specified number of levels
 // Returns the root node, and a slice of keys for testing
 func createTestTrie(t *testing.T, ls persister.LoadSaver, levels int) (elements.Node, [][]byte) {
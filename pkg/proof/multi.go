@@ -0,0 +1,463 @@
+package proof
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+
+	"github.com/ethersphere/proximity-order-trie/pkg/elements"
+	"github.com/ethersphere/proximity-order-trie/pkg/persister"
+	"golang.org/x/crypto/sha3"
+)
+
+// MultiProof proves multiple keys of the pot at once: the nodes on the paths to the keys are proven once
+// each with all the segments the paths need, and the sister hashes of the proven segments are shared
+// within the BMTs of the chunks of a node, so upper level nodes and siblings are not repeated for every key.
+type MultiProof struct {
+	// RootReference is the reference to the root node
+	RootReference []byte
+	// Keys are the proven keys
+	Keys [][]byte
+	// Nodes are the proofs of the nodes on the paths to the keys in the order they are first reached
+	Nodes []*NodeProof
+}
+
+// NodeProof proves segments of the data of a node at the given indexes.
+// ProofSegments only holds the sister hashes that cannot be calculated from the proven segments:
+// for each chunk of the node in depth first order, after the ones of its children, the sisters of the BMT
+// level by level from the bottom and in ascending order on each level.
+// The spans of the chunks follow from the span of the node as the Swarm file tree of the node data.
+type NodeProof struct {
+	// Span is the length of the node data
+	Span []byte
+	// Indexes are the indexes of the proven segments in ascending order
+	Indexes []int
+	// Segments are the proven segments
+	Segments [][]byte
+	// ProofSegments are the sister hashes needed to calculate the node hash
+	ProofSegments [][]byte
+}
+
+// CreateMultiProof generates a proof of all the keys in the pot with the given root node.
+// It gives an error wrapping ErrForkNotFound if any of the keys is not in the pot.
+func CreateMultiProof(ctx context.Context, rootNode elements.Node, ls persister.LoadSaver, keys [][]byte) (*MultiProof, error) {
+	if rootNode == nil {
+		return nil, fmt.Errorf("root node is nil")
+	}
+	if ls == nil {
+		return nil, fmt.Errorf("load saver is nil")
+	}
+	rootRef, rootData, err := rootNodeData(rootNode)
+	if err != nil {
+		return nil, err
+	}
+
+	// collect the nodes on the paths along with the indexes of the segments they need proven
+	type node struct {
+		data    []byte
+		indexes map[int]bool
+	}
+	nodes := make(map[string]*node)
+	var order []string
+	add := func(ref, data []byte) *node {
+		n, ok := nodes[string(ref)]
+		if !ok {
+			// the key and the bitvector are needed on every path
+			n = &node{data: data, indexes: map[int]bool{0: true, 1: true}}
+			nodes[string(ref)] = n
+			order = append(order, string(ref))
+		}
+		return n
+	}
+	for _, key := range keys {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("invalid key length: %d", len(key))
+		}
+		ref, n := rootRef, add(rootRef, rootData)
+		po := 0
+		for {
+			bitVector := n.data[32:64]
+			po = elements.PO(n.data[:32], key, po)
+			if po >= elements.MaxDepth {
+				n.indexes[entrySegmentIndexOf(bitVector)] = true
+				break
+			}
+			if !isBitSet(bitVector, po) {
+				return nil, fmt.Errorf("%w: key %x at node %x", ErrForkNotFound, key, ref)
+			}
+			index := 2 + countOnesUntil(bitVector, po)
+			n.indexes[index] = true
+			ref = n.data[index*32 : (index+1)*32]
+			if next, ok := nodes[string(ref)]; ok {
+				n = next
+				continue
+			}
+			data, err := ls.Load(ctx, ref)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load node with reference %x: %w", ref, err)
+			}
+			n = add(ref, data)
+		}
+	}
+
+	proof := &MultiProof{
+		RootReference: rootRef,
+		Keys:          keys,
+		Nodes:         make([]*NodeProof, len(order)),
+	}
+	for i, ref := range order {
+		n := nodes[ref]
+		var indexes []int
+		for j := 0; j < (len(n.data)+31)/32; j++ {
+			if n.indexes[j] {
+				indexes = append(indexes, j)
+			}
+		}
+		proof.Nodes[i] = newNodeProver(n.data).multiProof(indexes)
+	}
+	return proof, nil
+}
+
+// multiProof returns the proof of the segments at the given ascending indexes of the node data
+func (p *nodeProver) multiProof(indexes []int) *NodeProof {
+	proof := &NodeProof{
+		Span:     p.root.SpanBytes(),
+		Indexes:  indexes,
+		Segments: make([][]byte, len(indexes)),
+	}
+	for i, index := range indexes {
+		c, j, _ := p.locate(index)
+		proof.Segments[i] = p.chunkProver(c).Proof(j).ProveSegment
+	}
+	p.multiProofSegments(p.root, 0, indexes, &proof.ProofSegments)
+	return proof
+}
+
+// multiProofSegments appends the sister hashes needed to calculate the address of the chunk holding
+// the node data from the given offset from the segments at the given indexes under it
+func (p *nodeProver) multiProofSegments(c *persister.Chunk, offset uint64, indexes []int, sisters *[][]byte) {
+	var positions []int
+	if len(c.Children) == 0 {
+		for _, index := range indexes {
+			positions = append(positions, int((uint64(index)*32-offset)/32))
+		}
+	} else {
+		capacity := persister.ChildCapacity(c.Span)
+		for len(indexes) > 0 {
+			child := int((uint64(indexes[0])*32 - offset) / capacity)
+			childOffset := offset + uint64(child)*capacity
+			n := 0
+			for n < len(indexes) && uint64(indexes[n])*32 < childOffset+c.Children[child].Span {
+				n++
+			}
+			p.multiProofSegments(c.Children[child], childOffset, indexes[:n], sisters)
+			positions = append(positions, child)
+			indexes = indexes[n:]
+		}
+	}
+	prover := p.chunkProver(c)
+	for level := 0; len(positions) > 0 && level < 7; level++ {
+		var next []int
+		for i := 0; i < len(positions); i++ {
+			pos := positions[i]
+			if pos%2 == 0 && i+1 < len(positions) && positions[i+1] == pos+1 {
+				i++ // the sister is proven too
+			} else {
+				// the sister of the ancestor of a segment on this level is in its proof
+				*sisters = append(*sisters, prover.Proof(pos << level).ProofSegments[level])
+			}
+			next = append(next, pos/2)
+		}
+		positions = next
+	}
+}
+
+// Verify checks the multi proof against the root reference the same way as POTProofVerifier.assertMultiProof does:
+// the hash of each node is calculated from its proof, then the path of each key is followed from the root
+// through the proven fork references to the node holding the key, whose entry segment has to be proven.
+func (m *MultiProof) Verify(rootRef []byte) error {
+	if !bytes.Equal(m.RootReference, rootRef) {
+		return fmt.Errorf("%w: root reference mismatch", ErrInvalidProof)
+	}
+	nodes := make(map[string]*NodeProof)
+	for i, node := range m.Nodes {
+		nodeHash, err := node.hash()
+		if err != nil {
+			return fmt.Errorf("%w: invalid node proof at %d", err, i)
+		}
+		nodes[string(nodeHash)] = node
+	}
+	for _, key := range m.Keys {
+		if len(key) != 32 {
+			return fmt.Errorf("%w: invalid key length: %d", ErrInvalidProof, len(key))
+		}
+		if err := verifyMultiProofPath(nodes, rootRef, key); err != nil {
+			return fmt.Errorf("%w: key %x", err, key)
+		}
+	}
+	return nil
+}
+
+// verifyMultiProofPath follows the path of the key from the root through the proven nodes
+func verifyMultiProofPath(nodes map[string]*NodeProof, rootRef, key []byte) error {
+	nodeHash := rootRef
+	po := 0
+	for {
+		node, ok := nodes[string(nodeHash)]
+		if !ok {
+			return fmt.Errorf("%w: node %x is not in the proof", ErrInvalidProof, nodeHash)
+		}
+		nodeKey, err := node.segment(0)
+		if err != nil {
+			return err
+		}
+		bitVector, err := node.segment(1)
+		if err != nil {
+			return err
+		}
+		po = elements.PO(nodeKey, key, po)
+		if po >= elements.MaxDepth {
+			_, err := node.segment(entrySegmentIndexOf(bitVector))
+			return err
+		}
+		if !isBitSet(bitVector, po) {
+			return fmt.Errorf("%w: fork is not set in the parent's bitvector", ErrInvalidProof)
+		}
+		if nodeHash, err = node.segment(2 + countOnesUntil(bitVector, po)); err != nil {
+			return err
+		}
+	}
+}
+
+// segment returns the proven segment at the given index of the node data
+func (n *NodeProof) segment(index int) ([]byte, error) {
+	for i, j := range n.Indexes {
+		if j == index {
+			return n.Segments[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: segment %d is not in the proof", ErrInvalidProof, index)
+}
+
+// hash calculates the hash of the node from the proven segments and the sister hashes
+func (n *NodeProof) hash() ([]byte, error) {
+	if n == nil || len(n.Span) != 8 {
+		return nil, fmt.Errorf("%w: malformed node proof", ErrInvalidProof)
+	}
+	if len(n.Indexes) == 0 || len(n.Indexes) != len(n.Segments) {
+		return nil, fmt.Errorf("%w: invalid number of segments", ErrInvalidProof)
+	}
+	for i, index := range n.Indexes {
+		if index < 0 || i > 0 && index <= n.Indexes[i-1] {
+			return nil, fmt.Errorf("%w: indexes are not ascending", ErrInvalidProof)
+		}
+		if len(n.Segments[i]) != 32 {
+			return nil, fmt.Errorf("%w: invalid segment length: %d", ErrInvalidProof, len(n.Segments[i]))
+		}
+	}
+	span := binary.LittleEndian.Uint64(n.Span)
+	if !validSpan(span) {
+		return nil, fmt.Errorf("%w: node span %d is out of range", ErrInvalidProof, span)
+	}
+	v := &multiVerifier{node: n, sisters: n.ProofSegments, hasher: sha3.NewLegacyKeccak256()}
+	nodeHash, err := v.chunkAddress(span, 0)
+	if err != nil {
+		return nil, err
+	}
+	if v.next != len(n.Indexes) {
+		return nil, fmt.Errorf("%w: segment %d is out of the node", ErrInvalidProof, n.Indexes[v.next])
+	}
+	if len(v.sisters) != 0 {
+		return nil, fmt.Errorf("%w: too many proof segments", ErrInvalidProof)
+	}
+	return nodeHash, nil
+}
+
+// multiVerifier calculates the addresses of the chunks of a node from its proof
+// consuming the proven segments and the sister hashes in order
+type multiVerifier struct {
+	node    *NodeProof
+	next    int      // index of the next proven segment
+	sisters [][]byte // sister hashes left
+	hasher  hash.Hash
+}
+
+// chunkAddress calculates the address of the chunk with the given span holding the node data from the given offset
+func (v *multiVerifier) chunkAddress(span, offset uint64) ([]byte, error) {
+	var positions []int
+	var level [][]byte
+	indexes := v.node.Indexes
+	if span <= persister.ChunkSize {
+		for ; v.next < len(indexes) && uint64(indexes[v.next])*32 < offset+span; v.next++ {
+			positions = append(positions, int((uint64(indexes[v.next])*32-offset)/32))
+			level = append(level, v.node.Segments[v.next])
+		}
+	} else {
+		capacity := persister.ChildCapacity(span)
+		for v.next < len(indexes) && uint64(indexes[v.next])*32 < offset+span {
+			child := (uint64(indexes[v.next])*32 - offset) / capacity
+			address, err := v.chunkAddress(min(capacity, span-child*capacity), offset+child*capacity)
+			if err != nil {
+				return nil, err
+			}
+			positions = append(positions, int(child))
+			level = append(level, address)
+		}
+	}
+	if len(positions) == 0 {
+		return nil, fmt.Errorf("%w: no segments in the chunk", ErrInvalidProof)
+	}
+
+	for l := 0; l < 7; l++ {
+		var next [][]byte
+		var nextPositions []int
+		for i := 0; i < len(positions); i++ {
+			pos := positions[i]
+			var left, right []byte
+			switch {
+			case pos%2 == 0 && i+1 < len(positions) && positions[i+1] == pos+1:
+				left, right = level[i], level[i+1]
+				i++
+			case pos%2 == 0:
+				sister, err := v.sister()
+				if err != nil {
+					return nil, err
+				}
+				left, right = level[i], sister
+			default:
+				sister, err := v.sister()
+				if err != nil {
+					return nil, err
+				}
+				left, right = sister, level[i]
+			}
+			h, err := doHash(v.hasher, left, right)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, h)
+			nextPositions = append(nextPositions, pos/2)
+		}
+		level, positions = next, nextPositions
+	}
+	return doHash(v.hasher, binary.LittleEndian.AppendUint64(nil, span), level[0])
+}
+
+// sister takes the next sister hash
+func (v *multiVerifier) sister() ([]byte, error) {
+	if len(v.sisters) == 0 {
+		return nil, fmt.Errorf("%w: missing proof segments", ErrInvalidProof)
+	}
+	s := v.sisters[0]
+	if len(s) != 32 {
+		return nil, fmt.Errorf("%w: invalid proof segment length: %d", ErrInvalidProof, len(s))
+	}
+	v.sisters = v.sisters[1:]
+	return s, nil
+}
+
+// MarshalBinary encodes the multi proof compactly: the root reference and the keys are followed by
+// the nodes, each with its span, the number of proven segments, the indexes as differences
+// to the previous one, the segments, the number of sister hashes and the sister hashes.
+// Counts and indexes are unsigned varints, the span is 8 bytes little endian as in chunks.
+func (m *MultiProof) MarshalBinary() ([]byte, error) {
+	if len(m.RootReference) != 32 {
+		return nil, fmt.Errorf("invalid root reference length: %d", len(m.RootReference))
+	}
+	buf := append([]byte{}, m.RootReference...)
+	buf = binary.AppendUvarint(buf, uint64(len(m.Keys)))
+	for _, key := range m.Keys {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("invalid key length: %d", len(key))
+		}
+		buf = append(buf, key...)
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(m.Nodes)))
+	for _, n := range m.Nodes {
+		if len(n.Span) != 8 || len(n.Indexes) != len(n.Segments) {
+			return nil, fmt.Errorf("malformed node proof")
+		}
+		buf = append(buf, n.Span...)
+		buf = binary.AppendUvarint(buf, uint64(len(n.Indexes)))
+		prev := 0
+		for _, index := range n.Indexes {
+			if index < prev {
+				return nil, fmt.Errorf("indexes are not ascending")
+			}
+			buf = binary.AppendUvarint(buf, uint64(index-prev))
+			prev = index
+		}
+		for _, segment := range n.Segments {
+			if len(segment) != 32 {
+				return nil, fmt.Errorf("invalid segment length: %d", len(segment))
+			}
+			buf = append(buf, segment...)
+		}
+		buf = binary.AppendUvarint(buf, uint64(len(n.ProofSegments)))
+		for _, segment := range n.ProofSegments {
+			if len(segment) != 32 {
+				return nil, fmt.Errorf("invalid proof segment length: %d", len(segment))
+			}
+			buf = append(buf, segment...)
+		}
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary decodes a multi proof encoded by MarshalBinary
+func (m *MultiProof) UnmarshalBinary(buf []byte) error {
+	d := &decoder{buf: buf}
+	m.RootReference = d.bytes(32)
+	m.Keys = d.segments()
+	nodeCount := d.count()
+	m.Nodes = nil
+	for i := 0; i < nodeCount && d.err == nil; i++ {
+		n := &NodeProof{Span: d.bytes(8)}
+		indexCount := d.count()
+		prev := 0
+		for j := 0; j < indexCount && d.err == nil; j++ {
+			prev += d.count()
+			n.Indexes = append(n.Indexes, prev)
+		}
+		for j := 0; j < indexCount && d.err == nil; j++ {
+			n.Segments = append(n.Segments, d.bytes(32))
+		}
+		n.ProofSegments = d.segments()
+		m.Nodes = append(m.Nodes, n)
+	}
+	if err := d.close(); err != nil {
+		return fmt.Errorf("unmarshal multi proof: %w", err)
+	}
+	return nil
+}
+
+// JSON returns hexified JSON values used as smart contract validation parameter
+func (m *MultiProof) JSON() (string, error) {
+	nodes := make([]map[string]interface{}, len(m.Nodes))
+	for i, n := range m.Nodes {
+		if len(n.Span) != 8 {
+			return "", fmt.Errorf("invalid span length of node %d: %d", i, len(n.Span))
+		}
+		nodes[i] = map[string]interface{}{
+			"span":          binary.LittleEndian.Uint64(n.Span),
+			"indexes":       n.Indexes,
+			"segments":      hexSegments(n.Segments),
+			"proofSegments": hexSegments(n.ProofSegments),
+		}
+	}
+	proofsData := map[string]interface{}{
+		"rootReference": "0x" + hex.EncodeToString(m.RootReference),
+		"keys":          hexSegments(m.Keys),
+		"nodes":         nodes,
+	}
+
+	jsonProofsData, err := json.MarshalIndent(proofsData, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(jsonProofsData), nil
+}
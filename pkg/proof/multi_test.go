@@ -0,0 +1,257 @@
+package proof_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"testing"
+
+	"github.com/ethersphere/bee/v2/pkg/bmt"
+	"github.com/ethersphere/proximity-order-trie/pkg/elements"
+	"github.com/ethersphere/proximity-order-trie/pkg/persister"
+	"github.com/ethersphere/proximity-order-trie/pkg/proof"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMultiProof checks that multi proofs verify and are smaller than the single proofs of the same keys
+func TestMultiProof(t *testing.T) {
+	hashedKey := func(i int) []byte {
+		buf := make([]byte, 4)
+		binary.BigEndian.PutUint32(buf, uint32(i))
+		h := sha256.Sum256(buf)
+		return h[:]
+	}
+	sequentialKey := func(i int) []byte {
+		key := make([]byte, 32)
+		binary.BigEndian.PutUint32(key[28:], uint32(i))
+		return key
+	}
+	tests := []struct {
+		name  string
+		mode  elements.Mode
+		count int
+		key   func(int) []byte
+	}{
+		{
+			name:  "single order",
+			mode:  elements.NewSingleOrder(256),
+			count: 200,
+			key:   hashedKey,
+		},
+		{
+			name:  "balanced",
+			mode:  elements.NewBalanced(256),
+			count: 300,
+			key:   sequentialKey,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			root, ref, ls, keys := createIndex(t, tt.mode, tt.count, tt.key)
+			keys = keys[:50]
+
+			p, err := proof.CreateMultiProof(ctx, root, ls, keys)
+			require.NoError(t, err)
+			require.NoError(t, p.Verify(ref))
+
+			singleSegments := 0
+			for _, key := range keys {
+				single, err := proof.CreateForkPathProof(ctx, root, ls, key)
+				require.NoError(t, err)
+				singleSegments += forkPathSegmentCount(single)
+			}
+			encoded, err := p.MarshalBinary()
+			require.NoError(t, err)
+			assert.Less(t, len(encoded), singleSegments*32)
+
+			decoded := new(proof.MultiProof)
+			require.NoError(t, decoded.UnmarshalBinary(encoded))
+			assert.Equal(t, p, decoded)
+			require.NoError(t, decoded.Verify(ref))
+		})
+	}
+
+	t.Run("single key", func(t *testing.T) {
+		ctx := context.Background()
+		root, ref, ls, keys := createIndex(t, elements.NewSingleOrder(256), 1, hashedKey)
+		p, err := proof.CreateMultiProof(ctx, root, ls, keys)
+		require.NoError(t, err)
+		require.Len(t, p.Nodes, 1)
+		require.NoError(t, p.Verify(ref))
+	})
+
+	t.Run("absent key", func(t *testing.T) {
+		ctx := context.Background()
+		root, _, ls, keys := createIndex(t, elements.NewSingleOrder(256), 20, hashedKey)
+		_, err := proof.CreateMultiProof(ctx, root, ls, append(keys, hashedKey(20)))
+		assert.ErrorIs(t, err, proof.ErrForkNotFound)
+	})
+}
+
+// TestMultiProofMultiChunk checks multi proofs through nodes stored in multiple chunks
+func TestMultiProofMultiChunk(t *testing.T) {
+	ctx := context.Background()
+	root, ref, ls, keys := createMultiChunkIndex(t)
+
+	proven := [][]byte{keys[len(keys)-1], keys[0], keys[20], keys[150], keys[199]}
+	p, err := proof.CreateMultiProof(ctx, root, ls, proven)
+	require.NoError(t, err)
+	require.NoError(t, p.Verify(ref))
+	require.Greater(t, binary.LittleEndian.Uint64(p.Nodes[0].Span), uint64(persister.ChunkSize))
+
+	// the root proves segments in both of its chunks
+	rootProof := p.Nodes[0]
+	require.GreaterOrEqual(t, rootProof.Indexes[len(rootProof.Indexes)-1]*32, persister.ChunkSize)
+	rootProof.Indexes[len(rootProof.Indexes)-1]++
+	assert.ErrorIs(t, p.Verify(ref), proof.ErrInvalidProof)
+}
+
+// TestMultiProofTampered checks that altered multi proofs are rejected
+func TestMultiProofTampered(t *testing.T) {
+	ctx := context.Background()
+	root, ref, ls, keys := createIndex(t, elements.NewSingleOrder(256), 200, func(i int) []byte {
+		buf := make([]byte, 4)
+		binary.BigEndian.PutUint32(buf, uint32(i))
+		h := sha256.Sum256(buf)
+		return h[:]
+	})
+	keys = keys[:20]
+
+	tests := []struct {
+		name   string
+		modify func(*proof.MultiProof)
+	}{
+		{
+			name:   "altered segment",
+			modify: func(p *proof.MultiProof) { p.Nodes[1].Segments[0][0]++ },
+		},
+		{
+			name:   "altered proof segment",
+			modify: func(p *proof.MultiProof) { p.Nodes[0].ProofSegments[0][0]++ },
+		},
+		{
+			name: "missing proof segment",
+			modify: func(p *proof.MultiProof) {
+				p.Nodes[0].ProofSegments = p.Nodes[0].ProofSegments[1:]
+			},
+		},
+		{
+			name: "extra proof segment",
+			modify: func(p *proof.MultiProof) {
+				p.Nodes[0].ProofSegments = append(p.Nodes[0].ProofSegments, make([]byte, 32))
+			},
+		},
+		{
+			name: "unordered indexes",
+			modify: func(p *proof.MultiProof) {
+				n := p.Nodes[0]
+				n.Indexes[0], n.Indexes[1] = n.Indexes[1], n.Indexes[0]
+				n.Segments[0], n.Segments[1] = n.Segments[1], n.Segments[0]
+			},
+		},
+		{
+			name:   "missing node",
+			modify: func(p *proof.MultiProof) { p.Nodes = p.Nodes[:len(p.Nodes)-1] },
+		},
+		{
+			name:   "other key",
+			modify: func(p *proof.MultiProof) { p.Keys[0] = make([]byte, 32) },
+		},
+		{
+			name:   "invalid key length",
+			modify: func(p *proof.MultiProof) { p.Keys[0] = p.Keys[0][:31] },
+		},
+		{
+			name:   "invalid span",
+			modify: func(p *proof.MultiProof) { p.Nodes[0].Span = p.Nodes[0].Span[:4] },
+		},
+		{
+			name: "zero span",
+			modify: func(p *proof.MultiProof) {
+				p.Nodes[0].Span = make([]byte, 8)
+			},
+		},
+		{
+			// found by FuzzMultiProof: the tree of the span overflowed 64 bits
+			name: "huge span",
+			modify: func(p *proof.MultiProof) {
+				p.Nodes[0].Span = binary.LittleEndian.AppendUint64(nil, 0xffbe000000000161)
+			},
+		},
+		{
+			name:   "wrong root reference",
+			modify: func(p *proof.MultiProof) { p.RootReference = make([]byte, 32) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := append([][]byte{}, keys...)
+			p, err := proof.CreateMultiProof(ctx, root, ls, keys)
+			require.NoError(t, err)
+			require.Greater(t, len(p.Nodes), 1)
+			tt.modify(p)
+			withinDeadline(t, func() {
+				assert.ErrorIs(t, p.Verify(ref), proof.ErrInvalidProof)
+			})
+		})
+	}
+
+	t.Run("truncated encoding", func(t *testing.T) {
+		p, err := proof.CreateMultiProof(ctx, root, ls, keys)
+		require.NoError(t, err)
+		encoded, err := p.MarshalBinary()
+		require.NoError(t, err)
+		for _, n := range []int{0, 31, 33, len(encoded) - 1} {
+			assert.Error(t, new(proof.MultiProof).UnmarshalBinary(encoded[:n]))
+		}
+		assert.Error(t, new(proof.MultiProof).UnmarshalBinary(append(encoded, 0)))
+	})
+}
+
+// FuzzMultiProof checks that decoding and verifying arbitrary multi proofs returns
+func FuzzMultiProof(f *testing.F) {
+	ctx := context.Background()
+	root, ref, ls, keys := createIndex(f, elements.NewSingleOrder(256), 50, func(i int) []byte {
+		buf := make([]byte, 4)
+		binary.BigEndian.PutUint32(buf, uint32(i))
+		h := sha256.Sum256(buf)
+		return h[:]
+	})
+	p, err := proof.CreateMultiProof(ctx, root, ls, keys[:5])
+	require.NoError(f, err)
+	encoded, err := p.MarshalBinary()
+	require.NoError(f, err)
+	f.Add(encoded)
+	p.Nodes[0].Span = binary.LittleEndian.AppendUint64(nil, 0xffbe000000000161)
+	encoded, err = p.MarshalBinary()
+	require.NoError(f, err)
+	f.Add(encoded)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		decoded := new(proof.MultiProof)
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			return
+		}
+		_ = decoded.Verify(ref)
+	})
+}
+
+// forkPathSegmentCount returns the number of segments in the BMT proofs of a fork path proof
+func forkPathSegmentCount(p *proof.ForkPathProof) int {
+	count := func(proofs ...*bmt.Proof) int {
+		n := 0
+		for _, proof := range proofs {
+			n += 1 + len(proof.ProofSegments)
+		}
+		return n
+	}
+	n := 0
+	for _, f := range p.ForkRefProofs {
+		n += count(f.BitVectorProof, f.ForkReferenceProof)
+		n += count(f.BitVectorChunkProofs...) + count(f.ForkReferenceChunkProofs...)
+	}
+	e := p.EntryProof
+	return n + count(e.EntryProof, e.BitVectorProof) + count(e.BitVectorChunkProofs...) + count(e.EntryChunkProofs...)
+}
@@ -2,6 +2,8 @@ package proof
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
 
 	"github.com/ethersphere/bee/v2/pkg/bmt"
@@ -9,6 +11,13 @@ import (
 	"golang.org/x/crypto/sha3"
 )
 
+var (
+	// ErrTargetReached is returned when the node is the one holding the target key
+	ErrTargetReached = errors.New("parent key and target key are the same")
+	// ErrForkNotFound is returned when the node has no fork for the target key, i.e., the key is not in the pot
+	ErrForkNotFound = errors.New("fork not found")
+)
+
 // BMTProver handles inclusion proofs for entries in the proximity-order-trie
 type BMTProver struct {
 	*bmt.Prover
@@ -24,6 +33,51 @@ func NewBMTProver() *BMTProver {
 	}
 }
 
+// SegmentsProof contains the proof data for consecutive segments of a chunk.
+// ProofSegments only holds the sister hashes that cannot be calculated from the proven segments,
+// level by level from the bottom of the BMT with the left sister before the right one on each level.
+type SegmentsProof struct {
+	// Index is the index of the first proven segment
+	Index int
+	// Segments are the proven segments
+	Segments [][]byte
+	// ProofSegments are the sister hashes needed to calculate the BMT root
+	ProofSegments [][]byte
+	// Span is the span of the chunk
+	Span []byte
+	// ChunkProofs are the proofs of the chunk references up to the root chunk of a node stored in multiple chunks
+	ChunkProofs []*bmt.Proof
+}
+
+// SegmentsProof returns the proof of the segments with indexes in [from, to)
+// the hash of the written data has to be calculated before
+func (p *BMTProver) SegmentsProof(from, to int) SegmentsProof {
+	segments := make([][]byte, 0, to-from)
+	for i := from; i < to; i++ {
+		segments = append(segments, p.Proof(i).ProveSegment)
+	}
+	// the sisters of the first and the last segment's ancestors outside of the range
+	first, last := p.Proof(from), p.Proof(to-1)
+	var sisters [][]byte
+	lo, hi := from, to-1
+	for level := range first.ProofSegments {
+		if lo%2 == 1 {
+			sisters = append(sisters, first.ProofSegments[level])
+		}
+		if hi%2 == 0 {
+			sisters = append(sisters, last.ProofSegments[level])
+		}
+		lo >>= 1
+		hi >>= 1
+	}
+	return SegmentsProof{
+		Index:         from,
+		Segments:      segments,
+		ProofSegments: sisters,
+		Span:          first.Span,
+	}
+}
+
 // ForkRefProof contains the proof data for a fork node
 type ForkRefProof struct {
 	// BitVectorProof contains the proof for the bitvector representing the fork structure
@@ -32,6 +86,10 @@ type ForkRefProof struct {
 	ForkReferenceProof *bmt.Proof
 	// ForkPO is the proximity order of the specific fork
 	ForkPO int
+	// BitVectorChunkProofs are the chunk proofs of the bitvector for nodes stored in multiple chunks
+	BitVectorChunkProofs []*bmt.Proof
+	// ForkReferenceChunkProofs are the chunk proofs of the fork reference for nodes stored in multiple chunks
+	ForkReferenceChunkProofs []*bmt.Proof
 }
 
 // CreateForkNodeProof generates a proof for a fork node, including both the forkmap bitvector proof
@@ -47,7 +105,7 @@ func CreateForkNodeProof(parentData, targetKey []byte) (*ForkRefProof, error) {
 
 	parentKey := parentData[:32]
 	if bytes.Equal(parentKey, targetKey) {
-		return nil, fmt.Errorf("parent key and target key are the same")
+		return nil, ErrTargetReached
 	}
 
 	bitVector := parentData[32:64]
@@ -73,7 +131,7 @@ func CreateForkNodeProof(parentData, targetKey []byte) (*ForkRefProof, error) {
 	}
 	// check specificForkPO is in the bitvector
 	if bitVector[forkPO/8]&(1<<(7-forkPO%8)) == 0 {
-		return nil, fmt.Errorf("specific fork PO %d is not in the bitvector", forkPO)
+		return nil, fmt.Errorf("%w: specific fork PO %d is not in the bitvector", ErrForkNotFound, forkPO)
 	}
 	// count how many forks are before the specificForkPO
 	forkCount := 0
@@ -83,19 +141,16 @@ func CreateForkNodeProof(parentData, targetKey []byte) (*ForkRefProof, error) {
 		}
 	}
 
-	prover := NewBMTProver()
-	// Use the bitvector as the data to prove
-	prover.SetHeaderInt64(int64(len(parentData)))
-	prover.Write(parentData)
-	_, _ = prover.Hash(nil) // necessary to fill up bmt
-
-	bitVectorProof := prover.Proof(1)
-	forkReferenceProof := prover.Proof(forkCount + 2) // +2 because of the key and the bitvector
+	prover := newNodeProver(parentData)
+	bitVectorProof, bitVectorChunkProofs := prover.proof(1)
+	forkReferenceProof, forkReferenceChunkProofs := prover.proof(forkCount + 2) // +2 because of the key and the bitvector
 
 	return &ForkRefProof{
-		BitVectorProof:     &bitVectorProof,
-		ForkReferenceProof: &forkReferenceProof,
-		ForkPO:             forkPO,
+		BitVectorProof:           bitVectorProof,
+		ForkReferenceProof:       forkReferenceProof,
+		ForkPO:                   forkPO,
+		BitVectorChunkProofs:     bitVectorChunkProofs,
+		ForkReferenceChunkProofs: forkReferenceChunkProofs,
 	}, nil
 }
 
@@ -105,12 +160,35 @@ type EntryProof struct {
 	EntryProof *bmt.Proof
 	// BitVectorProof is the BMT proof for the bitvector and the node's key
 	BitVectorProof *bmt.Proof
+	// ValueProofs are the BMT proofs for all the segments of the entry value, one for each chunk holding them
+	ValueProofs []*SegmentsProof
+	// BitVectorChunkProofs are the chunk proofs of the bitvector for nodes stored in multiple chunks
+	BitVectorChunkProofs []*bmt.Proof
+	// EntryChunkProofs are the chunk proofs of the entry for nodes stored in multiple chunks
+	EntryChunkProofs []*bmt.Proof
+}
+
+// Value returns the entry value rebuilt from the segments of the value proofs
+// the span of each chunk tells where the value ends within its last segment
+func (e *EntryProof) Value() []byte {
+	var value []byte
+	for _, proof := range e.ValueProofs {
+		if proof == nil || len(proof.Span) != 8 {
+			return nil
+		}
+		segments := bytes.Join(proof.Segments, nil)
+		length := int(binary.LittleEndian.Uint64(proof.Span)) - proof.Index*32
+		if length < 0 {
+			return nil
+		}
+		value = append(value, segments[:min(length, len(segments))]...)
+	}
+	return value
 }
 
 // CreateEntryProof generates a proof for an entry value within a node
 // The nodeData should be the binary representation of the node containing the entry
 func CreateEntryProof(nodeData []byte) (*EntryProof, error) {
-	// TODO: proof for more than one segment entry
 	dl := len(nodeData)
 	if dl == 0 {
 		return nil, fmt.Errorf("empty node data")
@@ -137,18 +215,18 @@ func CreateEntryProof(nodeData []byte) (*EntryProof, error) {
 
 	entrySegmentIndex := entryOffset / 32
 
-	prover := NewBMTProver()
-	prover.SetHeaderInt64(int64(len(nodeData)))
-	prover.Write(nodeData)
-	_ = prover.Sum(nil) // necessary to fill up bmt field of prover
+	prover := newNodeProver(nodeData)
 	// prove bitMap for calculating entrySegementIndex
 	// along with the element's full key
-	bitVectorProof := prover.Proof(1)
-	entryProof := prover.Proof(entrySegmentIndex)
+	bitVectorProof, bitVectorChunkProofs := prover.proof(1)
+	entryProof, entryChunkProofs := prover.proof(entrySegmentIndex)
 
 	return &EntryProof{
-		EntryProof:     &entryProof,
-		BitVectorProof: &bitVectorProof,
+		EntryProof:           entryProof,
+		BitVectorProof:       bitVectorProof,
+		ValueProofs:          prover.segmentsProofs(entrySegmentIndex, (dl+31)/32), // the value takes all the segments until the end of the node
+		BitVectorChunkProofs: bitVectorChunkProofs,
+		EntryChunkProofs:     entryChunkProofs,
 	}, nil
 }
 
@@ -163,11 +241,15 @@ func ValidateEntryProof(nodeHash []byte, proof *EntryProof) error {
 		return fmt.Errorf("invalid node hash length: %d, expected 32", len(nodeHash))
 	}
 
-	hashcalc1, err := Verify(*proof.BitVectorProof)
+	if proof.BitVectorProof == nil || len(proof.BitVectorProof.ProveSegment) != 32 || proof.EntryProof == nil {
+		return fmt.Errorf("incomplete entry proof")
+	}
+
+	hashcalc1, err := chunkPathHash(*proof.BitVectorProof, proof.BitVectorChunkProofs)
 	if err != nil {
 		return fmt.Errorf("bitvector proof verification failed: %w", err)
 	}
-	hashcalc2, err := Verify(*proof.EntryProof)
+	hashcalc2, err := chunkPathHash(*proof.EntryProof, proof.EntryChunkProofs)
 	if err != nil {
 		return fmt.Errorf("entry proof verification failed: %w", err)
 	}
@@ -179,5 +261,45 @@ func ValidateEntryProof(nodeHash []byte, proof *EntryProof) error {
 		return fmt.Errorf("calculated proof hashes and the nodeHash do not match")
 	}
 
+	if len(proof.ValueProofs) > 0 {
+		entrySegmentIndex := entrySegmentIndexOf(proof.BitVectorProof.ProveSegment)
+		span := nodeSpan(proof.EntryProof.Span, proof.EntryChunkProofs)
+		if err := verifyValue(nodeHash, entrySegmentIndex, span, proof.ValueProofs); err != nil {
+			return fmt.Errorf("value proof verification failed: %w", err)
+		}
+	}
+
 	return nil
 }
+
+// chunkPathHash returns the node hash obtained from the proof of a segment and its chunk proofs
+// taking the indexes of the proofs as they are
+func chunkPathHash(proof bmt.Proof, chunkProofs []*bmt.Proof) ([]byte, error) {
+	hash, err := Verify(proof)
+	if err != nil {
+		return nil, err
+	}
+	for _, chunkProof := range chunkProofs {
+		if _, err := chunkProofSpan(chunkProof); err != nil {
+			return nil, err
+		}
+		p := *chunkProof
+		p.ProveSegment = hash
+		if hash, err = Verify(p); err != nil {
+			return nil, err
+		}
+	}
+	return hash, nil
+}
+
+// entrySegmentIndexOf returns the index of the first segment of the entry in a node with the given bitvector
+func entrySegmentIndexOf(bitVector []byte) int {
+	forkCount := countOnesUntil(bitVector, elements.MaxDepth)
+	forkDescendantsByteLength := forkCount * 4
+	entrySegmentIndex := (64 + forkCount*32 + forkDescendantsByteLength) / 32
+	// padding after fork descendants' counts
+	if forkDescendantsByteLength%32 != 0 {
+		entrySegmentIndex++
+	}
+	return entrySegmentIndex
+}
@@ -1,6 +1,7 @@
 package proof_test
 
 import (
+	"bytes"
 	"context"
 	"testing"
 
@@ -90,6 +91,37 @@ func TestCreateEntryProof(t *testing.T) {
 	}
 }
 
+// TestSegmentsProof tests proofs of consecutive segments against the BMT hash of the chunk
+func TestSegmentsProof(t *testing.T) {
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	prover := proof.NewBMTProver()
+	prover.SetHeaderInt64(int64(len(data)))
+	_, _ = prover.Write(data)
+	chunkHash, err := prover.Hash(nil) // fills up the bmt with the zero segments after the data
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for from := 0; from < 128; from += 5 {
+		for to := from + 1; to <= 128; to += 3 {
+			p := prover.SegmentsProof(from, to)
+			hash, err := proof.VerifySegments(p)
+			if err != nil {
+				t.Fatalf("VerifySegments() [%d, %d) unexpected error: %v", from, to, err)
+			}
+			if !bytes.Equal(hash, chunkHash) {
+				t.Fatalf("VerifySegments() [%d, %d) hash mismatch", from, to)
+			}
+			if len(p.ProofSegments) > 2*7 {
+				t.Errorf("SegmentsProof() [%d, %d) got %d proof segments", from, to, len(p.ProofSegments))
+			}
+		}
+	}
+}
+
 // createNodeWithZeroEntrySize creates a node with a structure that will result in
 // a entry offset is out of bounds error case
 func createNodeWithZeroEntrySize() []byte {
@@ -0,0 +1,237 @@
+package proof
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ethersphere/bee/v2/pkg/bmt"
+	"github.com/ethersphere/proximity-order-trie/pkg/elements"
+	"github.com/ethersphere/proximity-order-trie/pkg/persister"
+)
+
+// Nodes store the number of entries under each of their forks (the descendant count) as a 4 byte
+// big endian integer following the fork references, so the counts are proven the same way as values:
+// with proofs of the consecutive segments holding them.
+
+// SizeProof proves the number of entries in the pot: the root node holds one entry
+// and its forks hold the rest as given by their descendant counts
+type SizeProof struct {
+	// RootReference is the reference to the root node
+	RootReference []byte
+	// BitVectorProof is the BMT proof for the bitvector and the key of the root node
+	BitVectorProof *bmt.Proof
+	// BitVectorChunkProofs are the chunk proofs of the bitvector if the root node is stored in multiple chunks
+	BitVectorChunkProofs []*bmt.Proof
+	// ForkSizeProofs are the proofs of the segments holding the descendant counts of the forks of the root node
+	ForkSizeProofs []*SegmentsProof
+}
+
+// CreateSizeProof generates a proof of the number of entries in the pot with the given root node
+func CreateSizeProof(rootNode elements.Node) (*SizeProof, error) {
+	if rootNode == nil {
+		return nil, fmt.Errorf("root node is nil")
+	}
+	rootRef, rootData, err := rootNodeData(rootNode)
+	if err != nil {
+		return nil, err
+	}
+	prover := newNodeProver(rootData)
+	bitVectorProof, bitVectorChunkProofs := prover.proof(1)
+	return &SizeProof{
+		RootReference:        rootRef,
+		BitVectorProof:       bitVectorProof,
+		BitVectorChunkProofs: bitVectorChunkProofs,
+		ForkSizeProofs:       prover.forkSizesProofs(rootData),
+	}, nil
+}
+
+// Verify checks the size proof against the root reference the same way as POTProofVerifier.assertSizeProof does
+// and returns the number of entries in the pot
+func (s *SizeProof) Verify(rootRef []byte) (int, error) {
+	if !bytes.Equal(s.RootReference, rootRef) {
+		return 0, fmt.Errorf("%w: root reference mismatch", ErrInvalidProof)
+	}
+	if !wellFormed(s.BitVectorProof) {
+		return 0, fmt.Errorf("%w: malformed bit vector proof", ErrInvalidProof)
+	}
+	if err := verifyAt(rootRef, *s.BitVectorProof, 1, s.BitVectorChunkProofs); err != nil {
+		return 0, fmt.Errorf("%w: invalid bit vector proof of the root", ErrInvalidProof)
+	}
+	sizes, err := verifyForkSizes(rootRef, s.BitVectorProof.ProveSegment, s.ForkSizeProofs)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid fork size proof of the root", err)
+	}
+	size := 1
+	for _, n := range sizes {
+		size += n
+	}
+	return size, nil
+}
+
+// JSON returns hexified JSON values used as smart contract validation parameter
+func (s *SizeProof) JSON() (string, error) {
+	proofsData := map[string]interface{}{
+		"rootReference":  "0x" + hex.EncodeToString(s.RootReference),
+		"bitVectorProof": bmtProofJSON(s.BitVectorProof, s.BitVectorChunkProofs),
+		"forkSizeProofs": segmentsProofsJSON(s.ForkSizeProofs),
+	}
+
+	jsonProofsData, err := json.MarshalIndent(proofsData, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(jsonProofsData), nil
+}
+
+// RankProof proves the rank of a key in the pot, i.e., the number of entries with lower keys,
+// along with the number of entries in the pot: the descendant counts of the forks of the nodes
+// on the path to the key tell how many entries branch off the path below and above the key
+type RankProof struct {
+	// ForkPathProof is the proof of the path to the target key
+	ForkPathProof *ForkPathProof
+	// ForkSizeProofs are the proofs of the descendant counts of the forks of each node on the path, the root first
+	ForkSizeProofs [][]*SegmentsProof
+}
+
+// CreateRankProof generates a proof of the rank of the target key in the pot with the given root node
+func CreateRankProof(ctx context.Context, rootNode elements.Node, ls persister.LoadSaver, targetKey []byte) (*RankProof, error) {
+	path, nodesData, err := forkPath(ctx, rootNode, ls, targetKey)
+	if err != nil {
+		return nil, err
+	}
+	entryProof, err := CreateEntryProof(nodesData[len(nodesData)-1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create entry proof: %w", err)
+	}
+	path.EntryProof = entryProof
+
+	forkSizeProofs := make([][]*SegmentsProof, len(nodesData))
+	for i, nodeData := range nodesData {
+		forkSizeProofs[i] = newNodeProver(nodeData).forkSizesProofs(nodeData)
+	}
+	return &RankProof{
+		ForkPathProof:  path,
+		ForkSizeProofs: forkSizeProofs,
+	}, nil
+}
+
+// Verify checks the rank proof against the root reference the same way as POTProofVerifier.assertRankProof does
+// and returns the rank of the target key and the number of entries in the pot
+func (r *RankProof) Verify(rootRef []byte) (rank, size int, err error) {
+	f := r.ForkPathProof
+	if f == nil {
+		return 0, 0, fmt.Errorf("%w: missing fork path proof", ErrInvalidProof)
+	}
+	if err := f.Verify(rootRef); err != nil {
+		return 0, 0, err
+	}
+	if len(r.ForkSizeProofs) != len(f.ForkRefProofs)+1 {
+		return 0, 0, fmt.Errorf("%w: invalid number of fork size proofs: %d", ErrInvalidProof, len(r.ForkSizeProofs))
+	}
+
+	nodeHash := rootRef
+	at, po := -1, 0 // the root node is viewed with all its forks
+	for i, proofs := range r.ForkSizeProofs {
+		bitVectorProof := f.EntryProof.BitVectorProof
+		if i < len(f.ForkRefProofs) {
+			bitVectorProof = f.ForkRefProofs[i].BitVectorProof
+			po = elements.PO(bitVectorProof.ProofSegments[0], f.TargetKey, po)
+		} else {
+			po = elements.MaxDepth
+		}
+		sizes, err := verifyForkSizes(nodeHash, bitVectorProof.ProveSegment, proofs)
+		if err != nil {
+			return 0, 0, fmt.Errorf("%w: invalid fork size proof at node %d", err, i)
+		}
+		if i == 0 {
+			size = 1
+			for _, n := range sizes {
+				size += n
+			}
+		}
+		rank += lowerCount(f.TargetKey, bitVectorProof.ProveSegment, sizes, at, po)
+		if i < len(f.ForkRefProofs) {
+			nodeHash = f.ForkRefProofs[i].ForkReferenceProof.ProveSegment
+		}
+		at = po
+	}
+	return rank, size, nil
+}
+
+// JSON returns hexified JSON values used as smart contract validation parameter
+func (r *RankProof) JSON() (string, error) {
+	forkSizeProofs := make([][]segmentsProofJSON, len(r.ForkSizeProofs))
+	for i, proofs := range r.ForkSizeProofs {
+		forkSizeProofs[i] = segmentsProofsJSON(proofs)
+	}
+	proofsData := map[string]interface{}{
+		"forkPathProof":  r.ForkPathProof,
+		"forkSizeProofs": forkSizeProofs,
+	}
+
+	jsonProofsData, err := json.MarshalIndent(proofsData, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(jsonProofsData), nil
+}
+
+// forkSizesProofs returns the proofs of the segments holding the descendant counts of the forks of the node
+// nodes without forks have none
+func (p *nodeProver) forkSizesProofs(nodeData []byte) []*SegmentsProof {
+	from, to := forkSizesRange(countOnesUntil(nodeData[32:64], elements.MaxDepth))
+	return p.segmentsProofs(from, to)
+}
+
+// forkSizesRange returns the indexes [from, to) of the segments holding the descendant counts
+// of the forks of a node with the given number of forks: they follow the key, the bitvector and the fork references
+func forkSizesRange(forkCount int) (int, int) {
+	from := 2 + forkCount
+	return from, from + (forkCount*4+31)/32
+}
+
+// verifyForkSizes checks that the proofs cover the descendant counts of the forks of a node with the given bitvector
+// and resolve to the node hash, then returns the counts in the order of the forks
+func verifyForkSizes(nodeHash, bitVector []byte, proofs []*SegmentsProof) ([]int, error) {
+	forkCount := countOnesUntil(bitVector, elements.MaxDepth)
+	from, to := forkSizesRange(forkCount)
+	if err := verifyRange(nodeHash, from, to, proofs); err != nil {
+		return nil, err
+	}
+	var data []byte
+	for _, proof := range proofs {
+		data = append(data, bytes.Join(proof.Segments, nil)...)
+	}
+	sizes := make([]int, forkCount)
+	for i := range sizes {
+		sizes[i] = int(binary.BigEndian.Uint32(data[i*4:]))
+	}
+	return sizes, nil
+}
+
+// lowerCount counts the entries with keys lower than the target key under a node on the path to it,
+// viewed from the fork at PO at of its parent, where po is the PO of the node's key and the target key.
+// The entry of the node and its forks after po share the first po bits with the target key and are lower
+// if the target key has its bit at po set, the forks before po are lower if the target key has their bit set.
+func lowerCount(targetKey, bitVector []byte, sizes []int, at, po int) int {
+	count := 0
+	if isBitSet(targetKey, po) {
+		count++
+	}
+	i := 0
+	for j := 0; j < elements.MaxDepth; j++ {
+		if !isBitSet(bitVector, j) {
+			continue
+		}
+		// forks up to at are not under the node as viewed from its parent, the fork at po is on the path
+		if j > at && j != po && isBitSet(targetKey, min(j, po)) {
+			count += sizes[i]
+		}
+		i++
+	}
+	return count
+}
@@ -0,0 +1,204 @@
+package proof_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+	"testing"
+
+	pot "github.com/ethersphere/proximity-order-trie"
+	"github.com/ethersphere/proximity-order-trie/pkg/elements"
+	"github.com/ethersphere/proximity-order-trie/pkg/persister"
+	"github.com/ethersphere/proximity-order-trie/pkg/proof"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSizeProof checks size and rank proofs on persisted indexes of both modes
+func TestSizeProof(t *testing.T) {
+	hashedKey := func(i int) []byte {
+		buf := make([]byte, 4)
+		binary.BigEndian.PutUint32(buf, uint32(i))
+		h := sha256.Sum256(buf)
+		return h[:]
+	}
+	sequentialKey := func(i int) []byte {
+		key := make([]byte, 32)
+		binary.BigEndian.PutUint32(key[28:], uint32(i))
+		return key
+	}
+	tests := []struct {
+		name  string
+		mode  elements.Mode
+		count int
+		key   func(int) []byte
+	}{
+		{
+			name:  "single order",
+			mode:  elements.NewSingleOrder(256),
+			count: 200,
+			key:   hashedKey,
+		},
+		{
+			name:  "balanced",
+			mode:  elements.NewBalanced(256),
+			count: 300,
+			key:   sequentialKey,
+		},
+		{
+			name:  "single entry",
+			mode:  elements.NewSingleOrder(256),
+			count: 1,
+			key:   hashedKey,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			root, ref, ls, keys := createIndex(t, tt.mode, tt.count, tt.key)
+
+			sizeProof, err := proof.CreateSizeProof(root)
+			require.NoError(t, err)
+			size, err := sizeProof.Verify(ref)
+			require.NoError(t, err)
+			assert.Equal(t, tt.count, size)
+
+			sorted := make([][]byte, len(keys))
+			copy(sorted, keys)
+			sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i], sorted[j]) < 0 })
+			for rank, key := range sorted {
+				rankProof, err := proof.CreateRankProof(ctx, root, ls, key)
+				require.NoError(t, err)
+				assert.Len(t, rankProof.ForkSizeProofs, len(rankProof.ForkPathProof.ForkRefProofs)+1)
+				gotRank, gotSize, err := rankProof.Verify(ref)
+				require.NoError(t, err)
+				assert.Equal(t, rank, gotRank)
+				assert.Equal(t, tt.count, gotSize)
+			}
+		})
+	}
+}
+
+// TestSizeProofTampered checks that size and rank proofs with altered fork sizes are rejected
+func TestSizeProofTampered(t *testing.T) {
+	ctx := context.Background()
+	root, ref, ls, keys := createIndex(t, elements.NewSingleOrder(256), 200, func(i int) []byte {
+		buf := make([]byte, 4)
+		binary.BigEndian.PutUint32(buf, uint32(i))
+		h := sha256.Sum256(buf)
+		return h[:]
+	})
+
+	t.Run("size proof", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			modify func(*proof.SizeProof)
+		}{
+			{
+				name:   "invalid fork size",
+				modify: func(p *proof.SizeProof) { p.ForkSizeProofs[0].Segments[0][3]++ },
+			},
+			{
+				name:   "missing fork size proof",
+				modify: func(p *proof.SizeProof) { p.ForkSizeProofs = nil },
+			},
+			{
+				name: "missing fork size segment",
+				modify: func(p *proof.SizeProof) {
+					p.ForkSizeProofs[0].Segments = p.ForkSizeProofs[0].Segments[:len(p.ForkSizeProofs[0].Segments)-1]
+				},
+			},
+			{
+				name:   "invalid bit vector",
+				modify: func(p *proof.SizeProof) { p.BitVectorProof.ProveSegment = make([]byte, 32) },
+			},
+			{
+				name:   "wrong root reference",
+				modify: func(p *proof.SizeProof) { p.RootReference = make([]byte, 32) },
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				p, err := proof.CreateSizeProof(root)
+				require.NoError(t, err)
+				tt.modify(p)
+				_, err = p.Verify(ref)
+				assert.ErrorIs(t, err, proof.ErrInvalidProof)
+			})
+		}
+	})
+
+	t.Run("rank proof", func(t *testing.T) {
+		// the first key is deep in the single order pot so its path has multiple nodes
+		key := keys[0]
+		tests := []struct {
+			name   string
+			modify func(*proof.RankProof)
+		}{
+			{
+				name: "invalid fork size below the root",
+				modify: func(p *proof.RankProof) {
+					p.ForkSizeProofs[1][0].Segments[0][3]++
+				},
+			},
+			{
+				name:   "missing node",
+				modify: func(p *proof.RankProof) { p.ForkSizeProofs = p.ForkSizeProofs[1:] },
+			},
+			{
+				name: "swapped nodes",
+				modify: func(p *proof.RankProof) {
+					p.ForkSizeProofs[0], p.ForkSizeProofs[1] = p.ForkSizeProofs[1], p.ForkSizeProofs[0]
+				},
+			},
+			{
+				name:   "invalid fork path",
+				modify: func(p *proof.RankProof) { p.ForkPathProof.TargetKey = keys[1] },
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				p, err := proof.CreateRankProof(ctx, root, ls, key)
+				require.NoError(t, err)
+				require.Greater(t, len(p.ForkSizeProofs), 1)
+				tt.modify(p)
+				_, _, err = p.Verify(ref)
+				assert.ErrorIs(t, err, proof.ErrInvalidProof)
+			})
+		}
+	})
+
+	t.Run("absent key", func(t *testing.T) {
+		_, err := proof.CreateRankProof(ctx, root, ls, make([]byte, 32))
+		assert.ErrorIs(t, err, proof.ErrForkNotFound)
+	})
+}
+
+// createIndex persists an index with count entries and returns its loaded root along with the keys
+func createIndex(t testing.TB, mode elements.Mode, count int, key func(int) []byte) (elements.Node, []byte, persister.LoadSaver, [][]byte) {
+	t.Helper()
+	ctx := context.Background()
+	ls := persister.NewInmemLoadSaver()
+	newf := func(key []byte) elements.Entry {
+		e, _ := pot.NewSwarmEntry(key, nil)
+		return e
+	}
+	idx, err := pot.New(elements.NewSwarmPot(mode, ls, newf))
+	require.NoError(t, err)
+	defer idx.Close()
+
+	keys := make([][]byte, count)
+	for i := range keys {
+		keys[i] = key(i)
+		e, err := pot.NewSwarmEntry(keys[i], []byte{byte(i)})
+		require.NoError(t, err)
+		require.NoError(t, idx.Add(ctx, e))
+	}
+	ref, err := idx.Save(ctx)
+	require.NoError(t, err)
+	root, _, err := elements.NewSwarmPotReference(mode, ls, ref, newf).Load(ctx, ref)
+	require.NoError(t, err)
+	return root, ref, ls, keys
+}
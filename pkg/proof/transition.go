@@ -0,0 +1,309 @@
+package proof
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/ethersphere/proximity-order-trie/pkg/elements"
+	"github.com/ethersphere/proximity-order-trie/pkg/persister"
+)
+
+// TransitionProof proves that updating the entry at a key of the pot with the old root reference
+// gives the pot with the new root reference. It holds the nodes the update reads, the nodes on the path
+// to the key and the forks the mode inspects, so that the update can be redone on them without the rest
+// of the pot. Only the nodes whose entries the update moves to new nodes are given whole, of the others
+// only the segments holding the key, the bitvector, the fork references and the descendant counts are given.
+// The nodes are in the node format of SwarmNode and the segments are proven as SegmentsProofs with chunk proofs,
+// the proof itself has no serialised form nor contract counterpart, it is verified in Go with the mode of the pot.
+type TransitionProof struct {
+	// OldRootReference is the reference to the root node before the update, nil for an empty pot
+	OldRootReference []byte
+	// NewRootReference is the reference to the root node after the update
+	NewRootReference []byte
+	// Key is the key of the updated entry
+	Key []byte
+	// Value is the serialised entry put at the key
+	Value []byte
+	// Delete tells if the entry at the key is deleted instead
+	Delete bool
+	// Nodes are the nodes of the old pot the update reads in the order they are read
+	Nodes []*TransitionNode
+}
+
+// TransitionNode is a node of the old pot read by an update: either the whole node data
+// or the header of the node, i.e., the segments before its entry, with the proofs of these segments
+type TransitionNode struct {
+	// Data is the node data if the update needs the entry of the node, nil otherwise
+	Data []byte
+	// Reference is the reference to the node if only its header is given
+	Reference []byte
+	// HeaderProofs are the proofs of the header segments if only the header is given, one for each chunk holding them
+	HeaderProofs []*SegmentsProof
+}
+
+// UpdateWithProof updates the entry at the key of the persisted pot with the given root as SwarmPot.Update does
+// and returns the new root along with the proof of the transition. A nil entry deletes the entry at the key.
+// The nodes the update reads are recorded as it unpacks them, so the root and its nodes must be saved.
+// The new nodes are saved and the new root is committed to the mode.
+func UpdateWithProof(ctx context.Context, pm *elements.SwarmPot, root elements.Node, k []byte, e *elements.Entry) (elements.Node, *TransitionProof, error) {
+	p := &TransitionProof{
+		Key:    k,
+		Delete: e == nil,
+	}
+	if e != nil {
+		value, err := (*e).MarshalBinary()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to serialise entry: %w", err)
+		}
+		p.Value = value
+	}
+	rec := &recorder{SwarmPot: pm, recorded: make(map[string]bool)}
+	if !elements.Empty(root) {
+		if err := rec.record(root); err != nil {
+			return nil, nil, err
+		}
+		p.OldRootReference = root.(*elements.SwarmNode).Reference()
+	}
+	update, err := elements.Update(ctx, pm.New(), elements.NewAt(0, root), k, e, rec)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to update: %w", err)
+	}
+
+	// the entries pinned to new nodes and the one at the key, which the update compares, are needed whole
+	needed := map[string]bool{string(k): true}
+	if update != nil {
+		pinned(update, needed)
+	}
+	for _, n := range rec.nodes {
+		tn, err := transitionNode(n, needed[string(elements.KeyOf(n))])
+		if err != nil {
+			return nil, nil, err
+		}
+		p.Nodes = append(p.Nodes, tn)
+	}
+
+	if update == nil {
+		// no change
+		p.NewRootReference = p.OldRootReference
+		return nil, p, nil
+	}
+	if err := pm.Commit(ctx, update); err != nil {
+		return nil, nil, err
+	}
+	p.NewRootReference = update.(*elements.SwarmNode).Reference()
+	return update, p, nil
+}
+
+// pinned collects the keys of the entries pinned to the new nodes of the updated pot, the ones not saved yet
+func pinned(n elements.Node, keys map[string]bool) {
+	if n.(*elements.SwarmNode).Reference() != nil {
+		return
+	}
+	if !elements.Empty(n) {
+		keys[string(elements.KeyOf(n))] = true
+	}
+	_ = n.Iterate(0, func(cn elements.CNode) (bool, error) {
+		pinned(cn.Node, keys)
+		return false, nil
+	})
+}
+
+// transitionNode gives the node whole or only its header along with the proofs of the header segments
+func transitionNode(n *elements.SwarmNode, whole bool) (*TransitionNode, error) {
+	data, err := n.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	if whole {
+		return &TransitionNode{Data: data}, nil
+	}
+	return &TransitionNode{
+		Reference:    n.Reference(),
+		HeaderProofs: newNodeProver(data).segmentsProofs(0, headerLength(data[32:64])),
+	}, nil
+}
+
+// headerLength returns the number of segments before the entry of a node with the given bitvector
+func headerLength(bitVector []byte) int {
+	forks := countOnesUntil(bitVector, elements.MaxDepth)
+	return 2 + forks + (forks*4+31)/32
+}
+
+// Verify redoes the update on the nodes of the proof the same way as UpdateWithProof does
+// and checks that it gives the new root reference. The nodes are only reachable by their reference,
+// so the update can only read nodes of the old pot. The mode must be the one of the pot.
+func (t *TransitionProof) Verify(mode elements.Mode) error {
+	if len(t.Key)*8 != mode.Depth() {
+		return fmt.Errorf("%w: invalid key length: %d", ErrInvalidProof, len(t.Key))
+	}
+	nodes := make(nodeSet)
+	partial := make(map[string]bool) // keys of the nodes given without their entries
+	for _, n := range t.Nodes {
+		if n == nil {
+			return fmt.Errorf("%w: missing node", ErrInvalidProof)
+		}
+		if n.HeaderProofs == nil {
+			nodes[string(persister.Split(n.Data).Address)] = n.Data
+			continue
+		}
+		header, err := n.header()
+		if err != nil {
+			return err
+		}
+		nodes[string(n.Reference)] = header
+		partial[string(header[:32])] = true
+	}
+	newf := func(key []byte) elements.Entry {
+		return &rawEntry{key: key, partial: partial[string(key)]}
+	}
+	newRootRef, err := t.apply(context.Background(), mode, nodes, newf)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidProof, err)
+	}
+	if !bytes.Equal(newRootRef, t.NewRootReference) {
+		return fmt.Errorf("%w: new root reference mismatch", ErrInvalidProof)
+	}
+	return nil
+}
+
+// header checks the proofs of the header segments of the node against its reference and returns the header
+func (n *TransitionNode) header() ([]byte, error) {
+	var header []byte
+	for _, proof := range n.HeaderProofs {
+		if proof == nil {
+			return nil, fmt.Errorf("%w: malformed header proof", ErrInvalidProof)
+		}
+		for _, segment := range proof.Segments {
+			header = append(header, segment...)
+		}
+	}
+	if len(header) < 64 {
+		return nil, fmt.Errorf("%w: header of node %x is too short", ErrInvalidProof, n.Reference)
+	}
+	if err := verifyRange(n.Reference, 0, headerLength(header[32:64]), n.HeaderProofs); err != nil {
+		return nil, fmt.Errorf("invalid header of node %x: %w", n.Reference, err)
+	}
+	return header, nil
+}
+
+// apply updates the entry at the key of the pot with the old root reference
+// loading and saving nodes with the LoadSaver and returns the new root reference
+func (t *TransitionProof) apply(ctx context.Context, mode elements.Mode, ls persister.LoadSaver, newf func([]byte) elements.Entry) ([]byte, error) {
+	pm := elements.NewSwarmPot(mode, ls, newf)
+	root := pm.New()
+	if len(t.OldRootReference) > 0 {
+		var err error
+		if root, _, err = pm.Load(ctx, t.OldRootReference); err != nil {
+			return nil, err
+		}
+	}
+	var entry *elements.Entry
+	if !t.Delete {
+		var e elements.Entry = &rawEntry{key: t.Key, value: t.Value}
+		entry = &e
+	}
+	update, err := pm.Update(ctx, root, t.Key, entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update: %w", err)
+	}
+	if update == nil {
+		// no change
+		return t.OldRootReference, nil
+	}
+	return update.(*elements.SwarmNode).Reference(), nil
+}
+
+// recorder is the mode of a persisted pot recording the nodes an update unpacks
+// packing is deferred to the commit of the new root
+type recorder struct {
+	*elements.SwarmPot
+	recorded map[string]bool
+	nodes    []*elements.SwarmNode
+}
+
+// Unpack unpacks the node and records it the first time it is unpacked
+func (r *recorder) Unpack(ctx context.Context, n elements.Node) error {
+	if err := r.SwarmPot.Unpack(ctx, n); err != nil {
+		return err
+	}
+	if n == nil {
+		return nil
+	}
+	return r.record(n)
+}
+
+// Pack NOOP
+func (r *recorder) Pack(context.Context, elements.Node) error {
+	return nil
+}
+
+// record records a node of the old pot
+func (r *recorder) record(n elements.Node) error {
+	sn := n.(*elements.SwarmNode)
+	ref := sn.Reference()
+	if ref == nil {
+		return fmt.Errorf("node %x is not saved", elements.KeyOf(n))
+	}
+	if !r.recorded[string(ref)] {
+		r.recorded[string(ref)] = true
+		r.nodes = append(r.nodes, sn)
+	}
+	return nil
+}
+
+// nodeSet is a LoadSaver serving the nodes of a proof by their reference
+// saving only calculates the reference
+type nodeSet map[string][]byte
+
+// Load returns the data of the node with the given reference if it is in the set
+func (s nodeSet) Load(_ context.Context, ref []byte) ([]byte, error) {
+	data, ok := s[string(ref)]
+	if !ok {
+		return nil, fmt.Errorf("node %x is not in the proof", ref)
+	}
+	return data, nil
+}
+
+// Save returns the reference of the data
+func (s nodeSet) Save(_ context.Context, data []byte) ([]byte, error) {
+	return persister.Split(data).Address, nil
+}
+
+// rawEntry is an entry holding its serialised form so that nodes are rebuilt byte by byte
+// whatever the entry type of the pot is
+type rawEntry struct {
+	key     []byte
+	value   []byte
+	partial bool // the node of the entry is given without it
+}
+
+var _ elements.Entry = (*rawEntry)(nil)
+
+func (e *rawEntry) Key() []byte {
+	return e.key
+}
+
+func (e *rawEntry) Equal(v elements.Entry) bool {
+	ev, ok := v.(*rawEntry)
+	if !ok || e.partial || ev.partial {
+		return false
+	}
+	return bytes.Equal(e.value, ev.value)
+}
+
+func (e *rawEntry) MarshalBinary() ([]byte, error) {
+	if e.partial {
+		return nil, fmt.Errorf("entry at key %x is not in the proof", e.key)
+	}
+	return e.value, nil
+}
+
+func (e *rawEntry) UnmarshalBinary(v []byte) error {
+	e.value = v
+	return nil
+}
+
+func (e *rawEntry) String() string {
+	return fmt.Sprintf("key: %x; val: %x", e.key, e.value)
+}
@@ -0,0 +1,212 @@
+package proof_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"testing"
+
+	pot "github.com/ethersphere/proximity-order-trie"
+	"github.com/ethersphere/proximity-order-trie/pkg/elements"
+	"github.com/ethersphere/proximity-order-trie/pkg/persister"
+	"github.com/ethersphere/proximity-order-trie/pkg/proof"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTransitionProof checks that transition proofs give the root reference the index gets by the same update
+func TestTransitionProof(t *testing.T) {
+	key := func(i int) []byte {
+		buf := make([]byte, 4)
+		binary.BigEndian.PutUint32(buf, uint32(i))
+		h := sha256.Sum256(buf)
+		return h[:]
+	}
+	newf := func(key []byte) elements.Entry {
+		e, _ := pot.NewSwarmEntry(key, nil)
+		return e
+	}
+	count := 200
+	// update updates the entry at the key of the pot with the root reference and returns the proof of the transition
+	update := func(t *testing.T, mode elements.Mode, ls persister.LoadSaver, ref, k []byte, entry *elements.Entry) *proof.TransitionProof {
+		t.Helper()
+		ctx := context.Background()
+		pm := elements.NewSwarmPot(mode, ls, newf)
+		root := pm.New()
+		if ref != nil {
+			var err error
+			root, _, err = pm.Load(ctx, ref)
+			require.NoError(t, err)
+		}
+		_, p, err := proof.UpdateWithProof(ctx, pm, root, k, entry)
+		require.NoError(t, err)
+		// the new root is committed to the mode
+		if !bytes.Equal(p.NewRootReference, p.OldRootReference) {
+			newRef, err := pm.Save(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, p.NewRootReference, newRef)
+		}
+		return p
+	}
+	updates := []struct {
+		name  string
+		key   []byte
+		value []byte // nil deletes the entry
+	}{
+		{name: "insert", key: key(count), value: []byte("new")},
+		{name: "update", key: key(10), value: []byte("changed")},
+		{name: "no change", key: key(20), value: []byte{20}},
+		{name: "delete", key: key(30)},
+		{name: "delete absent", key: key(count + 1)},
+		{name: "delete root entry", key: key(count - 1)},
+	}
+	for _, mode := range []elements.Mode{elements.NewSingleOrder(256), elements.NewBalanced(256)} {
+		for _, u := range updates {
+			t.Run(u.name, func(t *testing.T) {
+				ctx := context.Background()
+				_, ref, ls, _ := createIndex(t, mode, count, key)
+
+				var entry *elements.Entry
+				if u.value != nil {
+					var e elements.Entry
+					e, _ = pot.NewSwarmEntry(u.key, u.value)
+					entry = &e
+				}
+				p := update(t, mode, ls, ref, u.key, entry)
+				assert.Less(t, len(p.Nodes), count/4)
+				require.NoError(t, p.Verify(mode))
+
+				// the index updated independently gets to the same root
+				idx, err := pot.NewReference(ctx, elements.NewSwarmPot(mode, ls, newf), ref)
+				require.NoError(t, err)
+				defer idx.Close()
+				require.NoError(t, idx.Update(ctx, u.key, entry))
+				newRef, err := idx.Save(ctx)
+				require.NoError(t, err)
+				assert.Equal(t, newRef, p.NewRootReference)
+			})
+		}
+	}
+
+	t.Run("empty pot", func(t *testing.T) {
+		mode := elements.NewSingleOrder(256)
+		ls := persister.NewInmemLoadSaver()
+		var e elements.Entry
+		e, _ = pot.NewSwarmEntry(key(0), []byte{0})
+		p := update(t, mode, ls, nil, key(0), &e)
+		assert.Empty(t, p.Nodes)
+		require.NoError(t, p.Verify(mode))
+	})
+
+	t.Run("headers", func(t *testing.T) {
+		ctx := context.Background()
+		mode := elements.NewSingleOrder(256)
+		ls := persister.NewInmemLoadSaver()
+		pm := elements.NewSwarmPot(mode, ls, newf)
+		var root elements.Node = pm.New()
+		for i := 0; i < count; i++ {
+			var e elements.Entry
+			e, _ = pot.NewSwarmEntry(key(i), bytes.Repeat([]byte{byte(i)}, 1000))
+			u, err := pm.Update(ctx, root, key(i), &e)
+			require.NoError(t, err)
+			root = u
+		}
+		_, err := pm.Save(ctx)
+		require.NoError(t, err)
+
+		var e elements.Entry
+		e, _ = pot.NewSwarmEntry(key(count), []byte("new"))
+		_, p, err := proof.UpdateWithProof(ctx, pm, root, key(count), &e)
+		require.NoError(t, err)
+		require.NoError(t, p.Verify(mode))
+		// the nodes whose entries stay where they are only come with their headers
+		var proofSize, dataSize int
+		headers := 0
+		for _, n := range p.Nodes {
+			if n.Data != nil {
+				proofSize += len(n.Data)
+				dataSize += len(n.Data)
+				continue
+			}
+			headers++
+			data, err := ls.Load(ctx, n.Reference)
+			require.NoError(t, err)
+			dataSize += len(data)
+			for _, hp := range n.HeaderProofs {
+				proofSize += 32 * (len(hp.Segments) + len(hp.ProofSegments))
+			}
+		}
+		assert.Greater(t, headers, 0)
+		assert.Less(t, proofSize, dataSize/2)
+
+		t.Run("tampered header", func(t *testing.T) {
+			for _, n := range p.Nodes {
+				if n.Data == nil {
+					n.HeaderProofs[0].ProofSegments[0] = make([]byte, 32)
+					break
+				}
+			}
+			assert.ErrorIs(t, p.Verify(mode), proof.ErrInvalidProof)
+		})
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		mode := elements.NewSingleOrder(256)
+		_, ref, ls, _ := createIndex(t, mode, count, key)
+		create := func() *proof.TransitionProof {
+			var e elements.Entry
+			e, _ = pot.NewSwarmEntry(key(count), []byte("new"))
+			p := update(t, mode, ls, ref, key(count), &e)
+			require.Greater(t, len(p.Nodes), 1)
+			return p
+		}
+		tests := []struct {
+			name   string
+			modify func(*proof.TransitionProof)
+		}{
+			{
+				name:   "other value",
+				modify: func(p *proof.TransitionProof) { p.Value = []byte("other") },
+			},
+			{
+				name:   "other key",
+				modify: func(p *proof.TransitionProof) { p.Key = key(count + 1) },
+			},
+			{
+				name:   "delete",
+				modify: func(p *proof.TransitionProof) { p.Delete = true },
+			},
+			{
+				name:   "missing node",
+				modify: func(p *proof.TransitionProof) { p.Nodes = p.Nodes[:len(p.Nodes)-1] },
+			},
+			{
+				name: "altered node",
+				modify: func(p *proof.TransitionProof) {
+					if n := p.Nodes[1]; n.Data != nil {
+						n.Data[len(n.Data)-1]++
+					} else {
+						n.HeaderProofs[0].Segments[0][0]++
+					}
+				},
+			},
+
+			{
+				name:   "other old root",
+				modify: func(p *proof.TransitionProof) { p.OldRootReference = p.NewRootReference },
+			},
+			{
+				name:   "invalid key length",
+				modify: func(p *proof.TransitionProof) { p.Key = p.Key[:31] },
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				p := create()
+				tt.modify(p)
+				assert.ErrorIs(t, p.Verify(mode), proof.ErrInvalidProof)
+			})
+		}
+	})
+}
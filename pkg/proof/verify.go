@@ -1,12 +1,21 @@
 package proof
 
 import (
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"hash"
 
 	"github.com/ethersphere/bee/v2/pkg/bmt"
+	"github.com/ethersphere/proximity-order-trie/pkg/elements"
 	"golang.org/x/crypto/sha3"
 )
 
+var (
+	// ErrInvalidProof is returned when a proof does not verify
+	ErrInvalidProof = errors.New("invalid proof")
+)
+
 // Verify returns the bmt hash obtained from the proof which can then be checked against
 // the BMT hash of the chunk
 func Verify(proof bmt.Proof) (root []byte, err error) {
@@ -28,6 +37,70 @@ func Verify(proof bmt.Proof) (root []byte, err error) {
 	return doHash(hasher, proof.Span, root)
 }
 
+// VerifySegments returns the bmt hash obtained from the proof of consecutive segments
+// which can then be checked against the BMT hash of the chunk
+func VerifySegments(proof SegmentsProof) (root []byte, err error) {
+	if len(proof.Segments) == 0 {
+		return nil, fmt.Errorf("%w: no segments", ErrInvalidProof)
+	}
+	if proof.Index < 0 || proof.Index+len(proof.Segments) > 128 {
+		return nil, fmt.Errorf("%w: segments out of the chunk", ErrInvalidProof)
+	}
+	hasher := sha3.NewLegacyKeccak256()
+	level := make([][]byte, len(proof.Segments))
+	copy(level, proof.Segments)
+	sisters := proof.ProofSegments
+	sister := func() ([]byte, error) {
+		if len(sisters) == 0 {
+			return nil, fmt.Errorf("%w: missing proof segments", ErrInvalidProof)
+		}
+		s := sisters[0]
+		sisters = sisters[1:]
+		return s, nil
+	}
+	lo := proof.Index
+	for width := 128; width > 1; width >>= 1 {
+		var next [][]byte
+		i := 0
+		if lo%2 == 1 {
+			left, err := sister()
+			if err != nil {
+				return nil, err
+			}
+			h, err := doHash(hasher, left, level[0])
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, h)
+			i = 1
+		}
+		for ; i+1 < len(level); i += 2 {
+			h, err := doHash(hasher, level[i], level[i+1])
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, h)
+		}
+		if i < len(level) {
+			right, err := sister()
+			if err != nil {
+				return nil, err
+			}
+			h, err := doHash(hasher, level[i], right)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, h)
+		}
+		level = next
+		lo >>= 1
+	}
+	if len(sisters) != 0 {
+		return nil, fmt.Errorf("%w: too many proof segments", ErrInvalidProof)
+	}
+	return doHash(hasher, proof.Span, level[0])
+}
+
 // calculates Hash of the data
 func doHash(h hash.Hash, data ...[]byte) ([]byte, error) {
 	h.Reset()
@@ -38,3 +111,125 @@ func doHash(h hash.Hash, data ...[]byte) ([]byte, error) {
 	}
 	return h.Sum(nil), nil
 }
+
+// verifyForkRefProofs checks the fork reference proofs of a path from the root reference towards
+// the target key the same way as POTProofVerifier.assertForkPathProof does and returns the hash of the
+// last node on the path along with the PO of the target key at the last fork
+func verifyForkRefProofs(rootRef, targetKey []byte, proofs []*ForkRefProof) (nodeHash []byte, po int, err error) {
+	nodeHash = rootRef
+	for i, proof := range proofs {
+		if proof == nil || !wellFormed(proof.BitVectorProof) || !wellFormed(proof.ForkReferenceProof) {
+			return nil, 0, fmt.Errorf("%w: malformed fork reference proof at %d", ErrInvalidProof, i)
+		}
+		nodeKey := proof.BitVectorProof.ProofSegments[0]
+		bitVector := proof.BitVectorProof.ProveSegment
+		po = elements.PO(nodeKey, targetKey, po)
+		if !isBitSet(bitVector, po) {
+			return nil, 0, fmt.Errorf("%w: fork is not set in the parent's bitvector", ErrInvalidProof)
+		}
+		forkRefSegmentIndex := 2 + countOnesUntil(bitVector, po) // forks before
+		if err := verifyAt(nodeHash, *proof.BitVectorProof, 1, proof.BitVectorChunkProofs); err != nil {
+			return nil, 0, fmt.Errorf("%w: invalid bit vector proof at fork %d", ErrInvalidProof, i)
+		}
+		if err := verifyAt(nodeHash, *proof.ForkReferenceProof, forkRefSegmentIndex, proof.ForkReferenceChunkProofs); err != nil {
+			return nil, 0, fmt.Errorf("%w: invalid fork reference proof at fork %d", ErrInvalidProof, i)
+		}
+		nodeHash = proof.ForkReferenceProof.ProveSegment
+	}
+	return nodeHash, po, nil
+}
+
+// verifyAt checks that the proof of the segment at the given index of the node data resolves to the node hash
+// through the chunk proofs of nodes stored in multiple chunks
+func verifyAt(nodeHash []byte, proof bmt.Proof, index int, chunkProofs []*bmt.Proof) error {
+	i, indexes, err := locate(index, proof.Span, chunkProofs)
+	if err != nil {
+		return err
+	}
+	proof.Index = i
+	hash, err := Verify(proof)
+	if err != nil {
+		return err
+	}
+	return verifyChunkPath(nodeHash, hash, chunkProofs, indexes)
+}
+
+// verifyValue checks that the value proofs cover the segments from the entry segment until the end of the node
+// and resolve to the node hash
+func verifyValue(nodeHash []byte, entrySegmentIndex int, span []byte, proofs []*SegmentsProof) error {
+	if len(span) != 8 {
+		return fmt.Errorf("%w: invalid span length: %d", ErrInvalidProof, len(span))
+	}
+	end := int(binary.LittleEndian.Uint64(span)+31) / 32
+	if entrySegmentIndex >= end {
+		return fmt.Errorf("%w: value is out of the node", ErrInvalidProof)
+	}
+	return verifyRange(nodeHash, entrySegmentIndex, end, proofs)
+}
+
+// verifyRange checks that the segments proofs cover the segments with indexes in [from, to) of the node data
+// one after the other and resolve to the node hash
+func verifyRange(nodeHash []byte, from, to int, proofs []*SegmentsProof) error {
+	index := from
+	for _, proof := range proofs {
+		if proof == nil || len(proof.Span) != 8 {
+			return fmt.Errorf("%w: malformed segments proof", ErrInvalidProof)
+		}
+		for _, segment := range proof.Segments {
+			if len(segment) != 32 {
+				return fmt.Errorf("%w: invalid segment length: %d", ErrInvalidProof, len(segment))
+			}
+		}
+		i, indexes, err := locate(index, proof.Span, proof.ChunkProofs)
+		if err != nil {
+			return err
+		}
+		if proof.Index != i {
+			return fmt.Errorf("%w: segments proof does not start at segment %d", ErrInvalidProof, index)
+		}
+		hash, err := VerifySegments(*proof)
+		if err != nil {
+			return err
+		}
+		if err := verifyChunkPath(nodeHash, hash, proof.ChunkProofs, indexes); err != nil {
+			return err
+		}
+		index += len(proof.Segments)
+	}
+	if index != to {
+		return fmt.Errorf("%w: invalid number of segments: %d", ErrInvalidProof, index-from)
+	}
+	return nil
+}
+
+// wellFormed checks that a BMT proof has the segments of a chunk
+func wellFormed(proof *bmt.Proof) bool {
+	if proof == nil || len(proof.ProveSegment) != 32 || len(proof.Span) != 8 || len(proof.ProofSegments) != 7 {
+		return false
+	}
+	for _, segment := range proof.ProofSegments {
+		if len(segment) != 32 {
+			return false
+		}
+	}
+	return true
+}
+
+// isBitSet checks if the bit at the given index is set in the bitvector
+func isBitSet(bitVector []byte, index int) bool {
+	if index >= elements.MaxDepth {
+		return false
+	}
+	return bitVector[index/8]&(1<<(7-index%8)) != 0
+}
+
+// countOnesUntil counts the set bits in the bitvector before the given index
+func countOnesUntil(bitVector []byte, index int) int {
+	count := 0
+	for i := 0; i < index && i < elements.MaxDepth; i++ {
+		if isBitSet(bitVector, i) {
+			count++
+		}
+	}
+	return count
+}
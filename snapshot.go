@@ -0,0 +1,109 @@
+package pot
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethersphere/proximity-order-trie/pkg/elements"
+	"github.com/ethersphere/proximity-order-trie/pkg/persister"
+)
+
+// Snapshot is a read-only view of an Index pinned to the root it had when the snapshot was taken
+// updates never change existing nodes, so later writes to the Index are not visible through it
+type Snapshot struct {
+	mode elements.Mode // mode of the index
+	root elements.Node // root of the pot at the time of the snapshot
+}
+
+// Snapshot returns a read-only handle to the current state of the pot
+func (idx *Index) Snapshot() *Snapshot {
+	return &Snapshot{mode: idx.mode, root: <-idx.read}
+}
+
+// snapshot is Snapshot respecting the cancellation of the context
+func (idx *Index) snapshot(ctx context.Context) (*Snapshot, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case root := <-idx.read:
+		return &Snapshot{mode: idx.mode, root: root}, nil
+	}
+}
+
+// Find retrieves the entry at the given key or gives elements.ErrNotFound
+func (s *Snapshot) Find(ctx context.Context, k []byte) (elements.Entry, error) {
+	defer elements.Traverse(s.mode)()
+	return elements.Find(ctx, s.root, k, s.mode)
+}
+
+// Iterate wraps the underlying pot's iterator
+func (s *Snapshot) Iterate(ctx context.Context, p, k []byte, f func(elements.Entry) (stop bool, err error)) error {
+	defer elements.Traverse(s.mode)()
+	return elements.Iterate(ctx, elements.NewAt(-1, s.root), p, k, s.mode, f)
+}
+
+// Range calls f on the entries with keys in [start, end) in ascending order of keys
+// until f returns true or an error. A nil start or end leaves the range unbounded on that side.
+func (s *Snapshot) Range(ctx context.Context, start, end []byte, f func(elements.Entry) (stop bool, err error)) error {
+	defer elements.Traverse(s.mode)()
+	return elements.Range(ctx, elements.NewAt(-1, s.root), start, end, s.mode, f)
+}
+
+// Nearest returns the k entries closest to the given key along with their proximity order to it
+func (s *Snapshot) Nearest(ctx context.Context, key []byte, k int) ([]elements.Neighbour, error) {
+	return s.NearestWithin(ctx, key, k, 0)
+}
+
+// NearestWithin is Nearest with a maximum distance: entries with proximity order less than po
+// to the key are left out without loading the forks holding them
+func (s *Snapshot) NearestWithin(ctx context.Context, key []byte, k, po int) ([]elements.Neighbour, error) {
+	defer elements.Traverse(s.mode)()
+	return elements.Nearest(ctx, elements.NewAt(-1, s.root), key, k, po, s.mode)
+}
+
+// Diff calls f on the changes that turn the pot of the snapshot into the pot of the other snapshot
+// in ascending order of keys until f returns true or an error. The Root of the changes is the reference
+// of the other snapshot if it is persisted. Subtries shared by the two pots are not unpacked.
+func (s *Snapshot) Diff(ctx context.Context, other *Snapshot, f func(Change) (stop bool, err error)) error {
+	// the traversals of the two pots are registered apart as they hold nodes of both
+	defer elements.Traverse(s.mode)()
+	defer elements.Traverse(other.mode)()
+	var root []byte
+	if tn, ok := other.root.(persister.TreeNode); ok {
+		root = tn.Reference()
+	}
+	return elements.Diff(ctx, elements.NewAt(-1, s.root), elements.NewAt(-1, other.root), s.mode, other.mode, func(from, to elements.Entry) (bool, error) {
+		c := Change{Old: from, New: to, Root: root}
+		if to != nil {
+			c.Key = to.Key()
+		} else {
+			c.Key = from.Key()
+		}
+		return f(c)
+	})
+}
+
+// Size returns the size (number of entries) of the pot
+func (s *Snapshot) Size() int {
+	if s.root == nil {
+		return 0
+	}
+	return s.root.Size()
+}
+
+// Save persists the pot as of the snapshot and returns the reference of its root
+// for modes that do not persist nodes the reference is nil
+func (s *Snapshot) Save(ctx context.Context) ([]byte, error) {
+	if elements.Empty(s.root) {
+		return nil, fmt.Errorf("root node is nil")
+	}
+	defer elements.Traverse(s.mode)()
+	if err := s.mode.Pack(ctx, s.root); err != nil {
+		return nil, fmt.Errorf("snapshot save: %w", err)
+	}
+	tn, ok := s.root.(persister.TreeNode)
+	if !ok {
+		return nil, nil
+	}
+	return tn.Reference(), nil
+}
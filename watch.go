@@ -0,0 +1,175 @@
+package pot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/ethersphere/proximity-order-trie/pkg/elements"
+	"github.com/ethersphere/proximity-order-trie/pkg/persister"
+)
+
+// Change is an event describing an update committed to the index
+type Change struct {
+	Key  []byte         // key of the entry changed
+	Old  elements.Entry // entry before the update, nil if added
+	New  elements.Entry // entry after the update, nil if deleted
+	Root []byte         // reference of the new root if the pot is persisted
+}
+
+// commit is a new root along with the changes that led to it
+type commit struct {
+	root    elements.Node
+	changes []Change
+}
+
+// change records the update of the entry at key k to e on the root
+// it returns false if the update does not change the entry
+func change(ctx context.Context, root elements.Node, k []byte, e *elements.Entry, mode elements.Mode) (Change, bool, error) {
+	c := Change{Key: k}
+	old, err := elements.Find(ctx, root, k, mode)
+	if err == nil {
+		c.Old = old
+	} else if !errors.Is(err, elements.ErrNotFound) {
+		return c, false, err
+	}
+	if e != nil {
+		c.New = *e
+	}
+	if c.Old == nil && c.New == nil || c.Old != nil && c.New != nil && c.Old.Equal(c.New) {
+		return c, false, nil
+	}
+	return c, true, nil
+}
+
+// watchQueueSize is the number of changes queued for a watcher beyond which it is dropped
+const watchQueueSize = 1024
+
+// watcher queues the changes for a subscriber so that muxProcess never waits for it
+type watcher struct {
+	prefix  []byte
+	out     chan Change   // changes delivered to the subscriber
+	notify  chan struct{} // signals pending changes
+	drop    chan struct{} // closed when the subscriber falls behind by more than watchQueueSize changes
+	mu      sync.Mutex
+	pending []Change
+}
+
+// push queues the changes with keys matching the prefix of the watcher
+// or drops the watcher if its queue is full
+func (w *watcher) push(cs []Change) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	select {
+	case <-w.drop:
+		return
+	default:
+	}
+	for _, c := range cs {
+		if !bytes.HasPrefix(c.Key, w.prefix) {
+			continue
+		}
+		if len(w.pending) == watchQueueSize {
+			w.pending = nil
+			close(w.drop)
+			return
+		}
+		w.pending = append(w.pending, c)
+	}
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+// run delivers the queued changes until the context is cancelled, the index is closed
+// or the watcher is dropped, and then unsubscribes it
+func (w *watcher) run(ctx context.Context, quit chan struct{}, unwatch chan *watcher) {
+	defer close(w.out)
+	defer func() {
+		select {
+		case unwatch <- w:
+		case <-quit:
+		}
+	}()
+	for {
+		w.mu.Lock()
+		pending := len(w.pending) > 0
+		var c Change
+		if pending {
+			c = w.pending[0]
+		}
+		w.mu.Unlock()
+
+		out, notify := w.out, w.notify
+		if pending {
+			notify = nil
+		} else {
+			out = nil
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-quit:
+			return
+		case <-w.drop:
+			return
+		case <-notify:
+		case out <- c:
+			w.mu.Lock()
+			if len(w.pending) > 0 { // the queue is emptied if the watcher is dropped meanwhile
+				w.pending = w.pending[1:]
+			}
+			w.mu.Unlock()
+		}
+	}
+}
+
+// Watch returns a channel of the changes committed to entries with keys matching the prefix
+// prefix matching is the same as for Iterate. The channel is closed when the context is cancelled,
+// the index is closed or the subscriber falls behind by more than watchQueueSize changes.
+// Changes of an update in progress when Watch is called may not be delivered.
+func (idx *Index) Watch(ctx context.Context, prefix []byte) (<-chan Change, error) {
+	w := &watcher{
+		prefix: prefix,
+		out:    make(chan Change),
+		notify: make(chan struct{}, 1),
+		drop:   make(chan struct{}),
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-idx.quit:
+		return nil, ErrClosed
+	case idx.watch <- w:
+	}
+	go w.run(ctx, idx.quit, idx.unwatch)
+	return w.out, nil
+}
+
+// notify dispatches the changes of a commit to the active watchers
+func notify(watchers []*watcher, c commit) []*watcher {
+	if len(c.changes) == 0 {
+		return watchers
+	}
+	if tn, ok := c.root.(persister.TreeNode); ok {
+		for i := range c.changes {
+			c.changes[i].Root = tn.Reference()
+		}
+	}
+	for _, w := range watchers {
+		w.push(c.changes)
+	}
+	return watchers
+}
+
+// unwatch removes a watcher whose subscription ended
+func unwatch(watchers []*watcher, w *watcher) []*watcher {
+	for i := range watchers {
+		if watchers[i] == w {
+			return append(watchers[:i], watchers[i+1:]...)
+		}
+	}
+	return watchers
+}